@@ -0,0 +1,145 @@
+package dictionary
+
+import (
+	"errors"
+	group_dictionary "group/base/dictionary"
+	"strconv"
+	"strings"
+)
+
+/*
+Dictionary encoding of key column on the wire.
+
+Client sends key of low cardinality column (as example os) as dense code instead of the string. When key is met
+first time client sends definition line `#dict,<code>,<key>` before the record, so dictionary is built on the fly
+and does not need separate round trip. Code in the record is prefixed by `#`, so server can still accept
+records of clients which do not encode keys.
+
+Server does not decode codes back to strings. Dictionary of connection is block dictionary (see
+golang/group/base/dictionary): record is returned with code of its key, rows are aggregated by codes in Sums
+(addition by index of array) and codes of connection are remapped to codes of one dictionary (or decoded) only when
+aggregates of connections are merged.
+*/
+
+const (
+	definitionPrefix = "#dict,"
+	codePrefix       = "#"
+)
+
+var ErrUnknownCode = errors.New("unknown dictionary code")
+
+type Encoder struct {
+	column int
+	codes  map[string]int
+}
+
+func NewEncoder(column int) *Encoder {
+	return &Encoder{column: column, codes: make(map[string]int)}
+}
+
+// Encode returns lines to send for record - definition of key if key is met first time and record with code of key
+func (encoder *Encoder) Encode(record []string) []string {
+	key := record[encoder.column]
+	code, ok := encoder.codes[key]
+	lines := make([]string, 0, 2)
+	if !ok {
+		code = len(encoder.codes)
+		encoder.codes[key] = code
+		lines = append(lines, definitionPrefix+strconv.Itoa(code)+","+key)
+	}
+
+	encoded := make([]string, len(record))
+	copy(encoded, record)
+	encoded[encoder.column] = codePrefix + strconv.Itoa(code)
+	return append(lines, strings.Join(encoded, ","))
+}
+
+type Decoder struct {
+	column     int
+	dictionary *group_dictionary.Dictionary[uint32]
+	// codes of client to codes of dictionary
+	remote []uint32
+}
+
+func NewDecoder(column int) *Decoder {
+	return &Decoder{column: column, dictionary: group_dictionary.New[uint32]()}
+}
+
+// Dictionary returns dictionary of codes returned by Decode
+func (decoder *Decoder) Dictionary() *group_dictionary.Dictionary[uint32] {
+	return decoder.dictionary
+}
+
+// Decode returns record of line and code of its key, key column of record is left as is.
+// Returns false if line is definition of key.
+func (decoder *Decoder) Decode(line string) ([]string, uint32, bool, error) {
+	if strings.HasPrefix(line, definitionPrefix) {
+		definition := strings.SplitN(line[len(definitionPrefix):], ",", 2)
+		if len(definition) != 2 {
+			return nil, 0, false, errors.New("malformed dictionary definition: " + line)
+		}
+		remote, err := strconv.Atoi(definition[0])
+		if err != nil {
+			return nil, 0, false, err
+		}
+		code, err := decoder.encode(definition[1])
+		if err != nil {
+			return nil, 0, false, err
+		}
+		for len(decoder.remote) <= remote {
+			decoder.remote = append(decoder.remote, 0)
+		}
+		decoder.remote[remote] = code
+		return nil, 0, false, nil
+	}
+
+	record := strings.Split(line, ",")
+	if decoder.column >= len(record) {
+		return nil, 0, false, errors.New("malformed record: " + line)
+	}
+	if !strings.HasPrefix(record[decoder.column], codePrefix) {
+		// key is not encoded by client
+		code, err := decoder.encode(record[decoder.column])
+		return record, code, err == nil, err
+	}
+	remote, err := strconv.Atoi(record[decoder.column][len(codePrefix):])
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if remote < 0 || remote >= len(decoder.remote) {
+		return nil, 0, false, ErrUnknownCode
+	}
+	return record, decoder.remote[remote], true, nil
+}
+
+func (decoder *Decoder) encode(key string) (uint32, error) {
+	code, ok := decoder.dictionary.Encode(key)
+	if !ok {
+		return 0, group_dictionary.ErrFull
+	}
+	return code, nil
+}
+
+// Sums is lookup table of sums by codes of dictionary - aggregation of dictionary encoded key is addition by index
+type Sums struct {
+	Values []int
+	Counts []int
+}
+
+func (sums *Sums) Add(code uint32, value int) {
+	for uint32(len(sums.Values)) <= code {
+		sums.Values = append(sums.Values, 0)
+		sums.Counts = append(sums.Counts, 0)
+	}
+	sums.Values[code] += value
+	sums.Counts[code]++
+}
+
+// Range calls fn for every code with rows
+func (sums *Sums) Range(fn func(code uint32, value int)) {
+	for code, count := range sums.Counts {
+		if count > 0 {
+			fn(uint32(code), sums.Values[code])
+		}
+	}
+}
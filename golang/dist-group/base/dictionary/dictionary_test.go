@@ -0,0 +1,103 @@
+package dictionary
+
+import (
+	"dist-group/base"
+	group_dictionary "group/base/dictionary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	records := base.Data("../data/phones_data.csv")[1:]
+	encoder := NewEncoder(3)
+	decoder := NewDecoder(3)
+
+	encodedBytes, rawBytes, definitions := 0, 0, 0
+	var decoded [][]string
+	for _, record := range records {
+		rawBytes += len(strings.Join(record, ",")) + 1
+		for _, line := range encoder.Encode(record) {
+			encodedBytes += len(line) + 1
+			result, code, isRecord, err := decoder.Decode(line)
+			require.NoError(t, err)
+			if !isRecord {
+				definitions++
+				continue
+			}
+			result[3] = decoder.Dictionary().Decode(code)
+			decoded = append(decoded, result)
+		}
+	}
+
+	require.Equal(t, len(records), len(decoded))
+	for idx := range records {
+		require.Equal(t, strings.Join(records[idx], ","), strings.Join(decoded[idx], ","))
+	}
+	// one definition per distinct os
+	distinct := make(map[string]bool)
+	for _, record := range records {
+		distinct[record[3]] = true
+	}
+	require.Equal(t, len(distinct), definitions)
+	require.Equal(t, len(distinct), decoder.Dictionary().Size())
+	require.Less(t, encodedBytes, rawBytes)
+}
+
+func TestDecodeNotEncodedRecord(t *testing.T) {
+	decoder := NewDecoder(1)
+	record, code, isRecord, err := decoder.Decode("1,Android,100")
+	require.NoError(t, err)
+	require.True(t, isRecord)
+	require.Equal(t, []string{"1", "Android", "100"}, record)
+	require.Equal(t, "Android", decoder.Dictionary().Decode(code))
+
+	// key defined by client gets the same code as not encoded key
+	_, _, _, err = decoder.Decode("#dict,0,Android")
+	require.NoError(t, err)
+	_, encoded, _, err := decoder.Decode("2,#0,100")
+	require.NoError(t, err)
+	require.Equal(t, code, encoded)
+
+	_, _, _, err = decoder.Decode("1,#7,100")
+	require.ErrorIs(t, err, ErrUnknownCode)
+}
+
+func TestSumsOfConnectionsMergedByRemapping(t *testing.T) {
+	expected := make(map[string]int)
+	decoders := []*Decoder{NewDecoder(0), NewDecoder(0)}
+	sums := make([]Sums, len(decoders))
+	for idx, decoder := range decoders {
+		// connections meet keys in different order, so codes of the same key differ
+		encoder := NewEncoder(0)
+		for row := 0; row < 100; row++ {
+			key := []string{"android", "ios", "windows", ""}[(row+idx)%4]
+			for _, line := range encoder.Encode([]string{key, "1"}) {
+				_, code, isRecord, err := decoder.Decode(line)
+				require.NoError(t, err)
+				if isRecord {
+					sums[idx].Add(code, row)
+				}
+			}
+			expected[key] += row
+		}
+	}
+
+	global := group_dictionary.New[uint32]()
+	result := Sums{}
+	for idx, decoder := range decoders {
+		remapping, ok := global.Merge(decoder.Dictionary())
+		require.True(t, ok)
+		sums[idx].Range(func(code uint32, value int) {
+			result.Add(remapping[code], value)
+		})
+	}
+
+	actual := make(map[string]int)
+	result.Range(func(code uint32, value int) {
+		actual[global.Decode(code)] = value
+	})
+	require.Equal(t, expected, actual)
+	require.Equal(t, []int{2, 2, 2, 2}, result.Counts)
+}
@@ -1,6 +1,10 @@
 package hashmap
 
-import v1 "dist-group/base/hashmap/open_addressing/linear_probing/v1"
+import (
+	v1 "dist-group/base/hashmap/open_addressing/linear_probing/v1"
+	"math"
+	"sort"
+)
 
 const BitsForBucket = 8
 const NumBuckets = 1 << BitsForBucket
@@ -15,3 +19,105 @@ func GetBucket(key string) int {
 	bucket := getBucketFromHash(int(hash))
 	return bucket
 }
+
+/*
+Skew-aware bucket assignment.
+
+With plain GetBucket all rows of a key go to one bucket, so a key holding most of the rows (Android in
+phones_data.csv) makes one bucket (and node or thread serving it) do most of the work. Heavy hitters are detected on
+sample of keys and rows of heavy hitter are salted over several buckets following its home bucket.
+Partial aggregates of salted key must be combined by key in the end (as example when buckets are merged).
+*/
+
+type HeavyHitter struct {
+	Key string
+	// Share of the key in sample
+	Share float64
+	// Salts is number of buckets rows of the key are spread over
+	Salts int
+}
+
+type SkewAwareBuckets struct {
+	Sampled      int
+	HeavyHitters []HeavyHitter
+	// Skew is load of the hottest bucket relative to average load of non-empty buckets without salting
+	Skew float64
+	// SaltedSkew is estimated load of the hottest bucket relative to average load of non-empty buckets with salting
+	SaltedSkew float64
+
+	salts map[string]int
+}
+
+// DetectSkew finds heavy hitters in sample of keys, key is heavy hitter if its share in sample is above threshold
+// (0 means fair share of one non-empty bucket)
+func DetectSkew(sample []string, threshold float64) *SkewAwareBuckets {
+	buckets := &SkewAwareBuckets{Sampled: len(sample), Skew: 1, SaltedSkew: 1, salts: map[string]int{}}
+	if len(sample) == 0 {
+		return buckets
+	}
+
+	counts := make(map[string]int)
+	for _, key := range sample {
+		counts[key]++
+	}
+	load := make(map[int]float64)
+	for key := range counts {
+		load[GetBucket(key)] = 0
+	}
+	// number of buckets can not be more than number of keys in sample
+	fairShare := 1 / float64(len(load))
+	if threshold <= 0 {
+		threshold = fairShare
+	}
+
+	saltedLoad := make(map[int]float64)
+	for key, count := range counts {
+		share := float64(count) / float64(len(sample))
+		home := GetBucket(key)
+		load[home] += share
+		if share <= threshold {
+			saltedLoad[home] += share
+			continue
+		}
+		salts := int(math.Ceil(share / fairShare))
+		if salts > NumBuckets {
+			salts = NumBuckets
+		}
+		buckets.HeavyHitters = append(buckets.HeavyHitters, HeavyHitter{Key: key, Share: share, Salts: salts})
+		buckets.salts[key] = salts
+		for salt := 0; salt < salts; salt++ {
+			saltedLoad[(home+salt)&MaxBucket] += share / float64(salts)
+		}
+	}
+
+	sort.Slice(buckets.HeavyHitters, func(i, j int) bool {
+		if buckets.HeavyHitters[i].Share != buckets.HeavyHitters[j].Share {
+			return buckets.HeavyHitters[i].Share > buckets.HeavyHitters[j].Share
+		}
+		return buckets.HeavyHitters[i].Key < buckets.HeavyHitters[j].Key
+	})
+
+	buckets.Skew = relativeMaxLoad(load)
+	buckets.SaltedSkew = relativeMaxLoad(saltedLoad)
+	return buckets
+}
+
+func relativeMaxLoad(load map[int]float64) float64 {
+	result, total := 0.0, 0.0
+	for _, value := range load {
+		result = math.Max(result, value)
+		total += value
+	}
+	return result * float64(len(load)) / total
+}
+
+// GetBucket returns bucket of row with key - heavy hitter rows are spread over buckets following home bucket
+// by salt (as example row number), other keys stay in home bucket
+func (buckets *SkewAwareBuckets) GetBucket(key string, salt int) int {
+	home := GetBucket(key)
+	salts, ok := buckets.salts[key]
+	if !ok {
+		return home
+	}
+	return (home + salt%salts) & MaxBucket
+}
@@ -0,0 +1,45 @@
+package hashmap
+
+import (
+	"dist-group/base"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBucketIsDeterministic(t *testing.T) {
+	for _, key := range []string{"iOS", "Android", "Windows Phone"} {
+		bucket := GetBucket(key)
+		require.GreaterOrEqual(t, bucket, 0)
+		require.Less(t, bucket, NumBuckets)
+		require.Equal(t, bucket, GetBucket(string([]byte(key))))
+	}
+}
+
+func TestSkewAwareBuckets(t *testing.T) {
+	records := base.Data("../data/phones_data.csv")
+	sample := make([]string, 0, len(records))
+	for _, record := range records[1:] {
+		if record[3] != "" {
+			sample = append(sample, record[3])
+		}
+	}
+
+	buckets := DetectSkew(sample, 0)
+	require.Equal(t, len(sample), buckets.Sampled)
+	require.NotEmpty(t, buckets.HeavyHitters)
+	require.Equal(t, "Android", buckets.HeavyHitters[0].Key)
+	require.Greater(t, buckets.HeavyHitters[0].Salts, 1)
+	require.Less(t, buckets.SaltedSkew, buckets.Skew)
+
+	// rows of heavy hitter are spread over buckets following home one, other keys stay in home bucket
+	home := GetBucket("Android")
+	used := map[int]bool{}
+	for row := 0; row < 100; row++ {
+		bucket := buckets.GetBucket("Android", row)
+		require.Equal(t, (home+row%buckets.HeavyHitters[0].Salts)&MaxBucket, bucket)
+		used[bucket] = true
+	}
+	require.Len(t, used, buckets.HeavyHitters[0].Salts)
+	require.Equal(t, GetBucket("iOS"), buckets.GetBucket("iOS", 7))
+}
@@ -1,9 +1,5 @@
 package v1
 
-import (
-	"unsafe"
-)
-
 // Basic murmur finalizer - https://gist.github.com/dnbaker/0fc1d4edbbdb24069eb063dc2559e4f5
 func murmurFinalizerHash64(hash uint64) uint64 {
 	hash ^= hash >> 33
@@ -60,11 +56,19 @@ func linearProbing(cell uint64) uint64 {
 
 func HashStringKey(key string) uint64 {
 	// very simple hash from string:
-	// get uint64 value as start pointer of string in memory
+	// fold string by uint64 words (tail word padded with zeros) and mix every word with murmur finalizer,
+	// hash is seeded with length of string, so zero padding does not make keys of different length equal,
+	// hash must be deterministic since bucket of key is computed on clients and servers independently
 	// will work only for non-empty strings
-	data := []byte(key)
-	toHash := *(*uint64)(unsafe.Pointer(&data[0]))
-	return murmurFinalizerHash64(toHash)
+	hash := uint64(len(key))
+	for i := 0; i < len(key); i += 8 {
+		var word uint64
+		for j := i; j < len(key) && j < i+8; j++ {
+			word |= uint64(key[j]) << (8 * (j - i))
+		}
+		hash = murmurFinalizerHash64(hash ^ word)
+	}
+	return hash
 }
 
 func (hashMap *HashTableWithLinearProbing) resize(capacity int) {
@@ -43,6 +43,23 @@ func (hashMap *TwoLevelHashMap) Put(key string, value int) {
 	hashMap.Buckets[bucket].Put(key, value)
 }
 
+// AddToBucket adds value to aggregate of key in given bucket instead of bucket of key hash, so rows of the same
+// key may be spread over several buckets (as salted heavy hitters), partial aggregates must be combined by key then
+func (hashMap *TwoLevelHashMap) AddToBucket(bucket int, key string, value int) {
+	if key == "" {
+		return
+	}
+
+	bucket &= MaxBucket
+	if hashMap.Buckets[bucket] == nil {
+		hashMap.Buckets[bucket] = new(v1.HashTableWithLinearProbing).New()
+	}
+	if cell := hashMap.Buckets[bucket].Get(key); cell != nil {
+		value += cell.Value
+	}
+	hashMap.Buckets[bucket].Put(key, value)
+}
+
 func (hashMap *TwoLevelHashMap) Get(key string) *v1.Cell {
 	if key == "" {
 		return nil
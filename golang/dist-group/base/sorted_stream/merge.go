@@ -0,0 +1,221 @@
+package sorted_stream
+
+import (
+	"bufio"
+	"container/heap"
+	"dist-group/base"
+	"dist-group/base/dictionary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+Streaming k-way merge of sorted streams of data nodes.
+
+Every data node sends its rows sorted by key (ascending order of os). Server initiator does not collect rows, it keeps
+only the current group of every stream in min heap of k groups:
+- group with the smallest key is taken from the heap and the next group of its stream is pushed to the heap;
+- groups of the same key come one after another, so aggregate of key is emitted as soon as next key is taken.
+Memory is O(k) of number of streams, groups are emitted in ascending order of key.
+
+Os may be sent dictionary encoded (see dist-group/base/dictionary). Stream sums consecutive rows of the same code
+by comparing integers, key of group is decoded and compared with keys of other streams only once per group.
+Every stream checks that keys of its groups do not decrease, stream violating sort order stops the merge with OrderError.
+*/
+
+const (
+	// columns is number of columns in record of phones data
+	columns  = 14
+	osColumn = 3
+)
+
+var (
+	ErrOutOfOrder      = errors.New("stream is out of sort order")
+	ErrMalformedRecord = errors.New("malformed record")
+)
+
+// OrderError is returned when key of stream is less than previous key of the same stream
+type OrderError struct {
+	Stream   string
+	Row      int
+	Previous string
+	Key      string
+}
+
+func (err *OrderError) Error() string {
+	return fmt.Sprintf("stream %s is out of sort order at row %d: %q after %q", err.Stream, err.Row, err.Key, err.Previous)
+}
+
+func (err *OrderError) Is(target error) bool {
+	return target == ErrOutOfOrder
+}
+
+// Stream reads rows sorted by os line by line
+type Stream struct {
+	Name      string
+	scanner   *bufio.Scanner
+	onCommand func(message string)
+	decoder   *dictionary.Decoder
+	previous  string
+	rows      int
+	groups    int
+	// the first row of the next group read ahead
+	next     base.GroupByOsPhone
+	nextCode uint32
+	hasNext  bool
+	nextErr  error
+}
+
+// NewStream returns stream of reader, lines starting with '/' are commands passed to onCommand (if set)
+func NewStream(name string, reader io.Reader, onCommand func(message string)) *Stream {
+	return &Stream{Name: name, scanner: bufio.NewScanner(reader), onCommand: onCommand, decoder: dictionary.NewDecoder(osColumn)}
+}
+
+// Rows returns number of rows read from stream
+func (stream *Stream) Rows() int {
+	return stream.rows
+}
+
+// Next returns sum of popularity of the next group of rows of the same os, false in the end of stream
+func (stream *Stream) Next() (base.GroupByOsPhone, bool, error) {
+	if !stream.hasNext {
+		if stream.nextErr == nil {
+			stream.hasNext, stream.nextErr = stream.read()
+		}
+		if !stream.hasNext {
+			return base.GroupByOsPhone{}, false, stream.nextErr
+		}
+	}
+
+	row := stream.rows
+	code, group := stream.nextCode, stream.next
+	stream.rows++
+	for {
+		stream.hasNext, stream.nextErr = stream.read()
+		if !stream.hasNext || stream.nextCode != code {
+			break
+		}
+		group.Popularity += stream.next.Popularity
+		stream.rows++
+	}
+
+	group.Os = stream.decoder.Dictionary().Decode(code)
+	if stream.groups > 0 && group.Os < stream.previous {
+		return base.GroupByOsPhone{}, false, &OrderError{Stream: stream.Name, Row: row, Previous: stream.previous, Key: group.Os}
+	}
+	stream.previous = group.Os
+	stream.groups++
+	return group, true, nil
+}
+
+// read reads the next row to stream.next and its code to stream.nextCode
+func (stream *Stream) read() (bool, error) {
+	for stream.scanner.Scan() {
+		line := stream.scanner.Text()
+		if line == "" {
+			continue
+		}
+		if line[0] == '/' {
+			if stream.onCommand != nil {
+				stream.onCommand(line)
+			}
+			continue
+		}
+
+		record, code, isRecord, err := stream.decoder.Decode(line)
+		if err != nil {
+			return false, fmt.Errorf("%w of stream %s: %w", ErrMalformedRecord, stream.Name, err)
+		}
+		if !isRecord {
+			continue
+		}
+		if len(record) != columns {
+			return false, fmt.Errorf("%w of stream %s: %s", ErrMalformedRecord, stream.Name, line)
+		}
+		stream.next = base.GroupByOsPhone{Popularity: base.MapPhone(record).Popularity}
+		stream.nextCode = code
+		return true, nil
+	}
+	return false, stream.scanner.Err()
+}
+
+type head struct {
+	group  base.GroupByOsPhone
+	stream int
+}
+
+type heads []head
+
+func (h heads) Len() int {
+	return len(h)
+}
+
+func (h heads) Less(i, j int) bool {
+	if h[i].group.Os != h[j].group.Os {
+		return h[i].group.Os < h[j].group.Os
+	}
+	return h[i].stream < h[j].stream
+}
+
+func (h heads) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *heads) Push(x any) {
+	*h = append(*h, x.(head))
+}
+
+func (h *heads) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// Merge merges sorted streams and emits sum of popularity of every os to fn in ascending order of os,
+// rows without os are skipped
+func Merge(streams []*Stream, fn func(os string, popularity int)) error {
+	merged := make(heads, 0, len(streams))
+	for idx, stream := range streams {
+		group, ok, err := stream.Next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			merged = append(merged, head{group: group, stream: idx})
+		}
+	}
+	heap.Init(&merged)
+
+	var currentOs string
+	var currentPopularity int
+	started := false
+	emit := func() {
+		if started && currentOs != "" {
+			fn(currentOs, currentPopularity)
+		}
+	}
+	for merged.Len() > 0 {
+		top := merged[0]
+		if !started || top.group.Os != currentOs {
+			// next group begins
+			emit()
+			currentOs, currentPopularity, started = top.group.Os, 0, true
+		}
+		currentPopularity += top.group.Popularity
+
+		group, ok, err := streams[top.stream].Next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			merged[0].group = group
+			heap.Fix(&merged, 0)
+		} else {
+			heap.Pop(&merged)
+		}
+	}
+	emit()
+	return nil
+}
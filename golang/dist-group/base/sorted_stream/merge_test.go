@@ -0,0 +1,160 @@
+package sorted_stream
+
+import (
+	"dist-group/base"
+	"dist-group/base/dictionary"
+	"errors"
+	"group/base/parallel_sort"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// partitionData splits phones data to k streams, every stream is sorted by os
+func partitionData(k int) ([]string, map[string]int) {
+	records := base.Data("../data/phones_data.csv")[1:]
+	expected := make(map[string]int)
+	partitions := make([][]base.Phone, k)
+	for idx, record := range records {
+		phone := base.MapPhone(record)
+		if phone.Os != "" {
+			expected[phone.Os] += phone.Popularity
+		}
+		partitions[idx%k] = append(partitions[idx%k], phone)
+	}
+
+	streams := make([]string, k)
+	for idx, phones := range partitions {
+		parallel_sort.RadixSortStrings(phones, func(phone base.Phone) string {
+			return phone.Os
+		}, parallel_sort.DefaultOptions())
+		lines := make([]string, 0, len(phones))
+		for _, phone := range phones {
+			lines = append(lines, base.MapRecord(phone))
+		}
+		streams[idx] = strings.Join(lines, "\n") + "\n"
+	}
+	return streams, expected
+}
+
+func makeStreams(data []string) []*Stream {
+	streams := make([]*Stream, len(data))
+	for idx, lines := range data {
+		streams[idx] = NewStream("client-"+string(rune('a'+idx)), strings.NewReader(lines), nil)
+	}
+	return streams
+}
+
+func TestMerge(t *testing.T) {
+	for _, k := range []int{1, 2, 4, 7} {
+		data, expected := partitionData(k)
+
+		keys := make([]string, 0)
+		actual := make(map[string]int)
+		require.NoError(t, Merge(makeStreams(data), func(os string, popularity int) {
+			keys = append(keys, os)
+			actual[os] = popularity
+		}))
+		require.True(t, sort.StringsAreSorted(keys))
+		require.Len(t, keys, len(actual))
+		require.Equal(t, expected, actual)
+	}
+}
+
+func TestMergeEncodedStreams(t *testing.T) {
+	data, expected := partitionData(3)
+	for idx, lines := range data {
+		// os is sent dictionary encoded as ordered_merge client does
+		encoder := dictionary.NewEncoder(3)
+		encoded := make([]string, 0)
+		for _, line := range strings.Split(strings.TrimSuffix(lines, "\n"), "\n") {
+			encoded = append(encoded, encoder.Encode(strings.Split(line, ","))...)
+		}
+		data[idx] = strings.Join(encoded, "\n") + "\n"
+	}
+
+	streams := makeStreams(data)
+	actual := make(map[string]int)
+	require.NoError(t, Merge(streams, func(os string, popularity int) {
+		actual[os] = popularity
+	}))
+	require.Equal(t, expected, actual)
+	rows := 0
+	for _, stream := range streams {
+		rows += stream.Rows()
+	}
+	require.Equal(t, len(base.Data("../data/phones_data.csv"))-1, rows)
+}
+
+func TestMergeEmptyStreams(t *testing.T) {
+	data, expected := partitionData(2)
+	data = append(data, "", "\n")
+
+	actual := make(map[string]int)
+	require.NoError(t, Merge(makeStreams(data), func(os string, popularity int) {
+		actual[os] = popularity
+	}))
+	require.Equal(t, expected, actual)
+
+	require.NoError(t, Merge(nil, func(os string, popularity int) {
+		require.Fail(t, "no groups without streams")
+	}))
+}
+
+func TestMergeOutOfOrder(t *testing.T) {
+	data, _ := partitionData(3)
+	// swap first and last rows of the second stream
+	lines := strings.Split(strings.TrimSuffix(data[1], "\n"), "\n")
+	lines[0], lines[len(lines)-1] = lines[len(lines)-1], lines[0]
+	data[1] = strings.Join(lines, "\n")
+
+	err := Merge(makeStreams(data), func(os string, popularity int) {})
+	require.ErrorIs(t, err, ErrOutOfOrder)
+	var orderError *OrderError
+	require.True(t, errors.As(err, &orderError))
+	require.Equal(t, "client-b", orderError.Stream)
+	require.Equal(t, 1, orderError.Row)
+	require.Less(t, orderError.Key, orderError.Previous)
+}
+
+func TestStreamSumsRowsOfTheSameCode(t *testing.T) {
+	lines := []string{
+		"#dict,0,android", "1,b,m,#0,10,0,0,0,1,0,0,0,1-2020,0", "2,b,m,#0,5,0,0,0,1,0,0,0,1-2020,0",
+		"#dict,1,ios", "3,b,m,#1,7,0,0,0,1,0,0,0,1-2020,0",
+	}
+	stream := NewStream("client", strings.NewReader(strings.Join(lines, "\n")), nil)
+
+	group, ok, err := stream.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, base.GroupByOsPhone{Os: "android", Popularity: 15}, group)
+	group, ok, err = stream.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, base.GroupByOsPhone{Os: "ios", Popularity: 7}, group)
+	_, ok, err = stream.Next()
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, 3, stream.Rows())
+}
+
+func TestStream(t *testing.T) {
+	commands := make([]string, 0)
+	stream := NewStream("client", strings.NewReader("/status\n1,b,m,android,10,0,0,0,1,0,0,0,1-2020,0\nbroken\n"),
+		func(message string) {
+			commands = append(commands, message)
+		})
+
+	group, ok, err := stream.Next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "android", group.Os)
+	require.Equal(t, 10, group.Popularity)
+	require.Equal(t, []string{"/status"}, commands)
+
+	_, _, err = stream.Next()
+	require.ErrorIs(t, err, ErrMalformedRecord)
+	require.Equal(t, 1, stream.Rows())
+}
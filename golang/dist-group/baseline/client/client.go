@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"dist-group/base"
+	"dist-group/base/dictionary"
 	"flag"
 	"log"
 	"net"
@@ -16,6 +18,8 @@ var host = flag.String("host", "localhost", "The hostname or IP to connect to; d
 var port = flag.Int("port", 8001, "The port to connect to; defaults to 8001.")
 var filePath = flag.String("file", "/Users/alex.gaas/Desktop/go/dist-group/base/data/phones_data.csv", "File we send for aggregation on the server initiator.")
 
+const osColumn = 3
+
 func main() {
 	// use all cores on your machine
 	runtime.GOMAXPROCS(runtime.NumCPU())
@@ -40,45 +44,32 @@ func main() {
 	// read commands from server
 	go readConnection(conn)
 
-	file, fileErr := os.Open(*filePath)
-	if fileErr != nil {
-		log.Fatalln(fileErr)
-	}
-	defer func(file *os.File) {
-		_ = file.Close()
-	}(file)
-
-	reader := bufio.NewReader(file)
+	records := base.Data(*filePath)
 
-	isHeader := false
+	// os is sent dictionary encoded - as code instead of string
+	encoder := dictionary.NewEncoder(osColumn)
 
 	log.Printf("Sending data to %s...\n", dest)
 
-	for {
-		// simply read by EOL
-		text, _ := reader.ReadBytes('\n')
+	for idx, record := range records {
 		// pass first line, that's header
-		if !isHeader {
-			isHeader = true
+		if idx == 0 {
 			continue
 		}
 
-		// set deadline
-		_ = conn.SetWriteDeadline(time.Now().Add(1 * time.Second))
+		for _, line := range encoder.Encode(record) {
+			// set deadline
+			_ = conn.SetWriteDeadline(time.Now().Add(1 * time.Second))
 
-		// write data to aggregate to server line by line
-		_, err := conn.Write(text)
-		if err != nil {
-			log.Println("Error writing to stream.")
-			break
-		}
-
-		// when whole been read leave
-		if len(text) == 0 {
-			log.Println("Reached EOF on server connection.")
-			break
+			// write data to aggregate to server line by line
+			_, err := conn.Write([]byte(line + "\n"))
+			if err != nil {
+				log.Println("Error writing to stream.")
+				return
+			}
 		}
 	}
+	log.Println("Reached EOF on server connection.")
 }
 
 func readConnection(conn net.Conn) {
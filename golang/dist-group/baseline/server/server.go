@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"dist-group/base"
+	"dist-group/base/dictionary"
 	v1 "dist-group/base/hashmap/open_addressing/linear_probing/v1"
 	v2 "dist-group/base/hashmap/open_addressing/linear_probing/v2"
 	"flag"
@@ -17,6 +18,8 @@ import (
 var addr = flag.String("addr", "", "The address to listen to; default is \"\" (all interfaces).")
 var ports = flag.String("ports", "8001, 8002, 8003, 8004", "Ports to listen on; defaults are [8001, 8002, 8003, 8004].")
 
+const osColumn = 3
+
 func main() {
 	// use all cores on your machine
 	runtime.GOMAXPROCS(runtime.NumCPU())
@@ -93,6 +96,9 @@ func handleConnection(conn net.Conn, globalHashMap *v2.HashTableWithLinearProbin
 	fmt.Println("Client connected from " + remoteAddr)
 
 	scanner := bufio.NewScanner(conn)
+	// every client has its own dictionary of os codes, rows are aggregated by codes
+	decoder := dictionary.NewDecoder(osColumn)
+	sums := dictionary.Sums{}
 
 	for {
 		ok := scanner.Scan()
@@ -101,38 +107,52 @@ func handleConnection(conn net.Conn, globalHashMap *v2.HashTableWithLinearProbin
 			break
 		}
 
-		handleMessage(scanner.Text(), conn, globalHashMap, localHashMap)
+		handleMessage(scanner.Text(), conn, decoder, &sums)
 	}
 
 	fmt.Println("Client at " + remoteAddr + " disconnected.")
 
+	// keys are decoded once per group of connection
+	osDictionary := decoder.Dictionary()
+	sums.Range(func(code uint32, popularity int) {
+		if key := osDictionary.Decode(code); key != "" {
+			put(key, popularity, globalHashMap, localHashMap)
+		}
+	})
+
 	done <- struct{}{}
 }
 
-func handleMessage(message string, conn net.Conn, globalHashMap *v2.HashTableWithLinearProbing, localHashMap *v1.HashTableWithLinearProbing) {
+func handleMessage(message string, conn net.Conn, decoder *dictionary.Decoder, sums *dictionary.Sums) {
 	// for debugging purpose
 	// fmt.Println("> " + message)
 
 	onExit(message, conn)
 
-	phone := base.MapPhone(strings.Split(message, ","))
-	if phone.Os == "" {
+	record, code, isRecord, err := decoder.Decode(message)
+	if err != nil {
+		fmt.Printf("Could not decode message: %s\n", err)
 		return
 	}
+	if isRecord {
+		sums.Add(code, base.MapPhone(record).Popularity)
+	}
+}
 
-	if localHashMap.ContainsKey(phone.Os) {
-		localHashMap.Put(phone.Os, localHashMap.Get(phone.Os).Value+phone.Popularity)
+func put(key string, popularity int, globalHashMap *v2.HashTableWithLinearProbing, localHashMap *v1.HashTableWithLinearProbing) {
+	if localHashMap.ContainsKey(key) {
+		localHashMap.Put(key, localHashMap.Get(key).Value+popularity)
 	} else {
-		globalPopularity := phone.Popularity
-		if globalHashMap.Get(phone.Os) != nil {
-			globalPopularity += globalHashMap.Get(phone.Os).Value
+		globalPopularity := popularity
+		if globalHashMap.Get(key) != nil {
+			globalPopularity += globalHashMap.Get(key).Value
 		}
-		if globalHashMap.Put(phone.Os, globalPopularity) == v2.BreakerOpened {
-			localPopularity := phone.Popularity
-			if localHashMap.Get(phone.Os) != nil {
-				localPopularity += localHashMap.Get(phone.Os).Value
+		if globalHashMap.Put(key, globalPopularity) == v2.BreakerOpened {
+			localPopularity := popularity
+			if localHashMap.Get(key) != nil {
+				localPopularity += localHashMap.Get(key).Value
 			}
-			localHashMap.Put(phone.Os, localPopularity)
+			localHashMap.Put(key, localPopularity)
 		}
 	}
 }
@@ -4,8 +4,12 @@ go 1.20
 
 require (
 	github.com/stretchr/testify v1.8.4
+	group v0.0.0
 )
 
+// group module shares its base packages (as parallel_sort) instead of copies of them
+replace group => ../group
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
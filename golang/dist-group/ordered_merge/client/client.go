@@ -3,22 +3,27 @@ package main
 import (
 	"bufio"
 	"dist-group/base"
+	"dist-group/base/dictionary"
 	"flag"
 	"fmt"
+	"group/base/parallel_sort"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var host = flag.String("host", "localhost", "The hostname or IP to connect to; defaults to \"localhost\".")
 var port = flag.Int("port", 8001, "The port to connect to; defaults to 8001.")
+var parallelism = flag.Int("parallelism", 0, "Maximum number of goroutines sorting data; default is 0 (all cores).")
 var filePath = flag.String("file", "/Users/alex.gaas/Desktop/go/dist-group/base/data/phones_data.csv", "File we send for aggregation on the server initiator.")
 
+const osColumn = 3
+
 func main() {
 	flag.Parse()
 
@@ -52,9 +57,10 @@ func main() {
 
 	// SORT data locally on the data node
 	// We actually do not need to sort out here, that just will help to provide better runtime on server-initiator
-	sort.Slice(phones, func(i, j int) bool {
-		return phones[i].Os > phones[j].Os
-	})
+	// data nodes and server-initiator sort in the same ascending order of os
+	parallel_sort.RadixSortStrings(phones, func(phone base.Phone) string {
+		return phone.Os
+	}, parallel_sort.Options{Parallelism: *parallelism})
 
 	// add temp file for sorted data
 	tmpFile, err := os.CreateTemp("", fmt.Sprintf("%s-", filepath.Base(os.Args[0])))
@@ -69,12 +75,15 @@ func main() {
 		}
 
 	}(tmpFile)
+	// os is sent dictionary encoded - as code instead of string, codes are given in sort order
+	encoder := dictionary.NewEncoder(osColumn)
 	for _, phone := range phones {
-		message := base.MapRecord(phone)
-		_, err := tmpFile.WriteString(message + "\n")
-		if err != nil {
-			log.Println("Error writing to temp file.")
-			return
+		for _, line := range encoder.Encode(strings.Split(base.MapRecord(phone), ",")) {
+			_, err := tmpFile.WriteString(line + "\n")
+			if err != nil {
+				log.Println("Error writing to temp file.")
+				return
+			}
 		}
 	}
 
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"dist-group/base"
+	"dist-group/base/dictionary"
 	"dist-group/base/hashmap"
 	"flag"
 	"fmt"
@@ -21,6 +22,11 @@ var host = flag.String("host", "localhost", "The hostname or IP to connect to; d
 var port = flag.Int("port", 8001, "The port to connect to; defaults to 8001.")
 var filePath = flag.String("file", "/Users/alex.gaas/Desktop/go/dist-group/base/data/phones_data.csv", "File we send for aggregation on the server initiator.")
 
+const (
+	osColumn   = 3
+	sampleSize = 1024
+)
+
 func main() {
 	// use all cores on your machine
 	runtime.GOMAXPROCS(runtime.NumCPU())
@@ -46,19 +52,28 @@ func main() {
 	go readConnection(conn)
 
 	records := base.Data(*filePath)
+
+	// detect heavy hitters on sample of keys, so rows of hot key are spread over several buckets
+	buckets := hashmap.DetectSkew(sampleKeys(records), 0)
+	log.Printf("Sampled %d keys, skew %.2f, skew with salting %.2f", buckets.Sampled, buckets.Skew, buckets.SaltedSkew)
+	for _, heavyHitter := range buckets.HeavyHitters {
+		log.Printf("Heavy hitter %s with share %.2f salted over %d buckets",
+			heavyHitter.Key, heavyHitter.Share, heavyHitter.Salts)
+	}
+
 	//var phones []base.Phone
 	for idx, record := range records {
 		// pass csv caption
 		if idx == 0 {
 			continue
 		}
-		key := record[3]
+		key := record[osColumn]
 		if key == "" {
 			continue
 		}
 
-		// simple hash to make bucket as `hash: key -> bucket_num`
-		bucketId := hashmap.GetBucket(key)
+		// simple hash to make bucket as `hash: key -> bucket_num`, salted by row number for heavy hitters
+		bucketId := buckets.GetBucket(key, idx)
 
 		// mark bucket number in records
 		record[13] = strconv.Itoa(bucketId)
@@ -85,11 +100,20 @@ func main() {
 		}
 
 	}(tmpFile)
-	for _, record := range records {
-		_, err := tmpFile.WriteString(strings.Join(record, ",") + "\n")
-		if err != nil {
-			log.Println("Error writing to temp file.")
-			return
+	// os is sent dictionary encoded - as code instead of string
+	encoder := dictionary.NewEncoder(osColumn)
+	for idx, record := range records {
+		lines := []string{strings.Join(record, ",")}
+		// csv caption is sent as is
+		if idx > 0 {
+			lines = encoder.Encode(record)
+		}
+		for _, line := range lines {
+			_, err := tmpFile.WriteString(line + "\n")
+			if err != nil {
+				log.Println("Error writing to temp file.")
+				return
+			}
 		}
 	}
 
@@ -130,6 +154,22 @@ func main() {
 	}
 }
 
+// sampleKeys takes keys of records with equal stride
+func sampleKeys(records [][]string) []string {
+	stride := len(records) / sampleSize
+	if stride == 0 {
+		stride = 1
+	}
+	sample := make([]string, 0, sampleSize)
+	// pass csv caption
+	for idx := 1; idx < len(records); idx += stride {
+		if key := records[idx][osColumn]; key != "" {
+			sample = append(sample, key)
+		}
+	}
+	return sample
+}
+
 func readConnection(conn net.Conn) {
 	for {
 		scanner := bufio.NewScanner(conn)
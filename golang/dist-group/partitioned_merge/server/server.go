@@ -3,10 +3,12 @@ package main
 import (
 	"bufio"
 	"dist-group/base"
+	"dist-group/base/dictionary"
 	v1 "dist-group/base/hashmap/open_addressing/linear_probing/v1"
 	"dist-group/base/hashmap/two_level"
 	"flag"
 	"fmt"
+	group_dictionary "group/base/dictionary"
 	"log"
 	"net"
 	"os"
@@ -17,6 +19,20 @@ import (
 var addr = flag.String("addr", "", "The address to listen to; default is \"\" (all interfaces).")
 var ports = flag.String("ports", "8001,8002,8003,8004", "Ports to listen on; defaults are [8001, 8002, 8003, 8004].")
 
+const osColumn = 3
+
+// block is rows of one client, os of row is code of dictionary of the client
+type block struct {
+	dictionary *group_dictionary.Dictionary[uint32]
+	rows       []row
+}
+
+type row struct {
+	code       uint32
+	bucketId   int
+	popularity int
+}
+
 func main() {
 	// use all cores on your machine
 	runtime.GOMAXPROCS(runtime.NumCPU())
@@ -25,7 +41,7 @@ func main() {
 
 	fmt.Println("Starting server...")
 
-	recordsResult := make(chan []base.Phone)
+	blockResult := make(chan block)
 
 	numbJobs := len(strings.Split(*ports, ","))
 	for i := 0; i < numbJobs; i++ {
@@ -42,7 +58,7 @@ func main() {
 				}
 			}(listener)
 
-			records := make([]base.Phone, 0)
+			records := block{}
 
 			done := make(chan struct{})
 
@@ -58,16 +74,16 @@ func main() {
 				select {
 				case <-done:
 					// all data been read from channel
-					recordsResult <- records
+					blockResult <- records
 					break
 				}
 			}
 		}(src)
 	}
 
-	dataBlocks := make([][]base.Phone, 0)
+	dataBlocks := make([]block, 0)
 	for i := 0; i < numbJobs; i++ {
-		dataBlocks = append(dataBlocks, <-recordsResult)
+		dataBlocks = append(dataBlocks, <-blockResult)
 	}
 
 	// parallel aggregate phase
@@ -76,18 +92,19 @@ func main() {
 	for _, block := range dataBlocks {
 		blockToRead := block
 		go func() {
-			twoLevelHashTable := new(two_level.TwoLevelHashMap).New()
-			for _, phone := range blockToRead {
-				if phone.Os == "" {
-					continue
-				}
+			// rows are aggregated by codes, rows go to bucket assigned by client, so rows of heavy hitter
+			// salted over several buckets are merged by several goroutines
+			sums := make([]dictionary.Sums, two_level.NumBuckets)
+			for _, row := range blockToRead.rows {
+				sums[row.bucketId&two_level.MaxBucket].Add(row.code, row.popularity)
+			}
 
-				popularity := phone.Popularity
-				hashTableCell := twoLevelHashTable.Get(phone.Os)
-				if hashTableCell != nil {
-					popularity += hashTableCell.Value
-				}
-				twoLevelHashTable.Put(phone.Os, popularity)
+			// keys are decoded once per group of bucket
+			twoLevelHashTable := new(two_level.TwoLevelHashMap).New()
+			for bucketId := range sums {
+				sums[bucketId].Range(func(code uint32, popularity int) {
+					twoLevelHashTable.AddToBucket(bucketId, blockToRead.dictionary.Decode(code), popularity)
+				})
 			}
 			hashTableAsResult <- *twoLevelHashTable
 		}()
@@ -155,25 +172,41 @@ func main() {
 		*/
 	}
 
+	// final combine phase - salted heavy hitter has partial aggregate in every bucket it was salted over
+	combined := new(v1.HashTableWithLinearProbing).New()
 	for _, hashMap := range twoLevelHashTableOut.Buckets {
 		if hashMap == nil {
 			continue
 		}
 
 		for _, cell := range hashMap.Cells {
-			if cell.Key != "" {
-				log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
+			if cell.Key == "" {
+				continue
+			}
+			value := cell.Value
+			if combinedCell := combined.Get(cell.Key); combinedCell != nil {
+				value += combinedCell.Value
 			}
+			combined.Put(cell.Key, value)
+		}
+	}
+
+	for _, cell := range combined.Cells {
+		if cell.Key != "" {
+			log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
 		}
 	}
 	log.Println()
 }
 
-func handleConnection(conn net.Conn, records *[]base.Phone, done chan struct{}) {
+func handleConnection(conn net.Conn, records *block, done chan struct{}) {
 	remoteAddr := conn.RemoteAddr().String()
 	fmt.Println("Client connected from " + remoteAddr)
 
 	scanner := bufio.NewScanner(conn)
+	// every client has its own dictionary of os codes
+	decoder := dictionary.NewDecoder(osColumn)
+	records.dictionary = decoder.Dictionary()
 
 	for {
 		ok := scanner.Scan()
@@ -182,7 +215,7 @@ func handleConnection(conn net.Conn, records *[]base.Phone, done chan struct{})
 			break
 		}
 
-		handleMessage(scanner.Text(), conn, records)
+		handleMessage(scanner.Text(), conn, decoder, records)
 	}
 
 	fmt.Println("Client at " + remoteAddr + " disconnected.")
@@ -190,12 +223,20 @@ func handleConnection(conn net.Conn, records *[]base.Phone, done chan struct{})
 	done <- struct{}{}
 }
 
-func handleMessage(message string, conn net.Conn, records *[]base.Phone) {
+func handleMessage(message string, conn net.Conn, decoder *dictionary.Decoder, records *block) {
 	// for debugging purpose
 	// fmt.Println("> " + message)
-	*records = append(*records, base.MapPhone(strings.Split(message, ",")))
-
 	onExit(message, conn)
+
+	record, code, isRecord, err := decoder.Decode(message)
+	if err != nil {
+		fmt.Printf("Could not decode message: %s\n", err)
+		return
+	}
+	if isRecord {
+		phone := base.MapPhone(record)
+		records.rows = append(records.rows, row{code: code, bucketId: phone.BucketId, popularity: phone.Popularity})
+	}
 }
 
 func onExit(message string, conn net.Conn) {
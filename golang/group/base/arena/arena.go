@@ -0,0 +1,109 @@
+package arena
+
+import "group/base/memory"
+
+/*
+Arena keeps bytes of many small keys in large chunks (slabs) instead of one Go string per key.
+Chunks have no pointers inside, so garbage collector does not scan them, and reference to key (Ref) is plain numbers,
+so table cells referencing keys have no pointers as well. Keys are copied to arena, so table does not keep alive
+rows (or whole columnar batches) keys were taken from. There is no free of single key - whole arena is released at once
+when aggregation ends.
+*/
+
+const DefaultChunkSize = 64 << 10
+
+// Ref is reference of key in arena
+type Ref struct {
+	Chunk  uint32
+	Offset uint32
+	Length uint32
+}
+
+type Arena struct {
+	chunks    [][]byte
+	chunkSize int
+	size      int
+
+	// tracker accounts memory of chunks, err keeps first error of accounting
+	tracker *memory.Tracker
+	err     error
+}
+
+func (arena *Arena) New() *Arena {
+	return arena.NewWithChunkSize(DefaultChunkSize)
+}
+
+func (arena *Arena) NewWithChunkSize(chunkSize int) *Arena {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return &Arena{chunkSize: chunkSize}
+}
+
+// NewWithTracker makes arena reporting memory of its chunks to tracker
+func (arena *Arena) NewWithTracker(chunkSize int, tracker *memory.Tracker) *Arena {
+	result := arena.NewWithChunkSize(chunkSize)
+	result.tracker = tracker
+	return result
+}
+
+// Err returns first error of memory accounting of chunks, arena is released by Free
+func (arena *Arena) Err() error {
+	return arena.err
+}
+
+// Append copies key to arena and returns reference of it, key longer than chunk gets its own chunk
+func (arena *Arena) Append(key string) Ref {
+	last := len(arena.chunks) - 1
+	if last < 0 || len(arena.chunks[last])+len(key) > cap(arena.chunks[last]) {
+		capacity := arena.chunkSize
+		if len(key) > capacity {
+			capacity = len(key)
+		}
+		arena.chunks = append(arena.chunks, make([]byte, 0, capacity))
+		if err := arena.tracker.Alloc(int64(capacity)); err != nil && arena.err == nil {
+			arena.err = err
+		}
+		last++
+	}
+	offset := len(arena.chunks[last])
+	arena.chunks[last] = append(arena.chunks[last], key...)
+	arena.size += len(key)
+	return Ref{Chunk: uint32(last), Offset: uint32(offset), Length: uint32(len(key))}
+}
+
+// Bytes returns bytes of key, they are valid until arena is freed
+func (arena *Arena) Bytes(ref Ref) []byte {
+	return arena.chunks[ref.Chunk][ref.Offset : ref.Offset+ref.Length]
+}
+
+// String returns copy of key
+func (arena *Arena) String(ref Ref) string {
+	return string(arena.Bytes(ref))
+}
+
+// Equal compares key of ref with key without allocation
+func (arena *Arena) Equal(ref Ref, key string) bool {
+	return int(ref.Length) == len(key) && string(arena.Bytes(ref)) == key
+}
+
+// Size returns number of bytes of all keys
+func (arena *Arena) Size() int {
+	return arena.size
+}
+
+// Allocated returns number of bytes allocated by chunks
+func (arena *Arena) Allocated() int {
+	allocated := 0
+	for _, chunk := range arena.chunks {
+		allocated += cap(chunk)
+	}
+	return allocated
+}
+
+// Free releases all chunks at once, all references become invalid
+func (arena *Arena) Free() {
+	arena.tracker.Free(int64(arena.Allocated()))
+	arena.chunks = nil
+	arena.size = 0
+}
@@ -0,0 +1,43 @@
+package arena
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArena(t *testing.T) {
+	arena := new(Arena).NewWithChunkSize(16)
+	android := arena.Append("Android")
+	ios := arena.Append("iOS")
+	// does not fit the first chunk
+	windows := arena.Append("Windows Phone")
+	long := arena.Append(strings.Repeat("x", 40))
+
+	require.Equal(t, "Android", arena.String(android))
+	require.Equal(t, "iOS", arena.String(ios))
+	require.Equal(t, "Windows Phone", arena.String(windows))
+	require.Equal(t, strings.Repeat("x", 40), arena.String(long))
+	require.Equal(t, uint32(0), ios.Chunk)
+	require.Equal(t, uint32(1), windows.Chunk)
+	require.Equal(t, uint32(2), long.Chunk)
+
+	require.True(t, arena.Equal(android, "Android"))
+	require.False(t, arena.Equal(android, "Androi"))
+	require.False(t, arena.Equal(ios, "iOs"))
+
+	require.Equal(t, 7+3+13+40, arena.Size())
+	require.Equal(t, 16+16+40, arena.Allocated())
+
+	arena.Free()
+	require.Equal(t, 0, arena.Size())
+	require.Equal(t, 0, arena.Allocated())
+}
+
+func TestEmptyKey(t *testing.T) {
+	arena := new(Arena).New()
+	ref := arena.Append("")
+	require.Equal(t, "", arena.String(ref))
+	require.True(t, arena.Equal(ref, ""))
+}
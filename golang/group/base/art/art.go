@@ -0,0 +1,473 @@
+package art
+
+import (
+	"group/base/memory"
+	"strings"
+	"unsafe"
+)
+
+/*
+Adaptive radix tree - https://db.in.tum.de/~leis/papers/ART.pdf
+
+Radix tree over bytes of key: inner node maps next byte of key to child. Size of inner node adapts to number of
+children, so sparse nodes do not waste 256 pointers:
+- node4 and node16 keep sorted bytes of children and children in the same order (scan of up to 16 bytes);
+- node48 keeps index of child per byte (256 bytes) and up to 48 children;
+- node256 keeps child per byte.
+Node grows to the next kind when it is full. Chain of nodes with single child is collapsed to prefix of the node
+(path compression), leaf keeps the whole key and aggregate of the key. Key which ends at inner node (prefix of other
+keys as example "Android" and "Android 10") is kept as terminal leaf of the node.
+
+Children of every node are in order of bytes, so in-order walk gives keys in ascending order and keys of prefix are
+one subtree. Keys are never removed from aggregation, so there is no delete.
+
+Memory tracker (if set) accounts nodes and leaves with their slices of children, bytes of keys and prefixes are not
+accounted since they are shared with keys of input rows. Grown node frees memory of the node it replaces.
+*/
+
+const (
+	leafKind uint8 = iota
+	node4Kind
+	node16Kind
+	node48Kind
+	node256Kind
+)
+
+type node struct {
+	kind uint8
+	// count is number of children of inner node
+	count int
+	// prefix is compressed path of inner node
+	prefix string
+	// keys are bytes of children for node4 and node16, index of child + 1 per byte for node48
+	keys     []byte
+	children []*node
+	// terminal is leaf of key ending at inner node
+	terminal *node
+
+	// key and value of leaf
+	key   string
+	value int
+}
+
+type Stats struct {
+	Leaves  int
+	Node4   int
+	Node16  int
+	Node48  int
+	Node256 int
+}
+
+type Tree struct {
+	root    *node
+	size    int
+	account *memory.Account
+}
+
+func (tree *Tree) New() *Tree {
+	return &Tree{}
+}
+
+// NewWithTracker makes tree reporting memory of its nodes to tracker
+func (tree *Tree) NewWithTracker(tracker *memory.Tracker) *Tree {
+	return &Tree{account: new(memory.Account).New(tracker)}
+}
+
+var _ memory.Accounted = (*Tree)(nil)
+
+func (tree *Tree) Err() error {
+	return tree.account.Err()
+}
+
+func (tree *Tree) Release() {
+	tree.account.Release()
+}
+
+// nodeBytes returns memory of node of kind with its bytes of children and pointers to children
+func nodeBytes(kind uint8) int64 {
+	keys, children := 0, 0
+	switch kind {
+	case node4Kind:
+		keys, children = 4, 4
+	case node16Kind:
+		keys, children = 16, 16
+	case node48Kind:
+		keys, children = 256, 48
+	case node256Kind:
+		children = 256
+	}
+	return int64(unsafe.Sizeof(node{})) + int64(keys) + int64(children)*int64(unsafe.Sizeof((*node)(nil)))
+}
+
+func (tree *Tree) Size() int {
+	return tree.size
+}
+
+func (tree *Tree) newLeaf(key string, value int) *node {
+	tree.account.Alloc(nodeBytes(leafKind))
+	return &node{kind: leafKind, key: key, value: value}
+}
+
+func (tree *Tree) newNode4(prefix string) *node {
+	tree.account.Alloc(nodeBytes(node4Kind))
+	return &node{kind: node4Kind, prefix: prefix, keys: make([]byte, 4), children: make([]*node, 4)}
+}
+
+func (tree *Tree) Get(key string) (int, bool) {
+	current := tree.root
+	depth := 0
+	for current != nil {
+		if current.kind == leafKind {
+			if current.key == key {
+				return current.value, true
+			}
+			return 0, false
+		}
+		if !strings.HasPrefix(key[depth:], current.prefix) {
+			return 0, false
+		}
+		depth += len(current.prefix)
+		if depth == len(key) {
+			if current.terminal != nil {
+				return current.terminal.value, true
+			}
+			return 0, false
+		}
+		child := current.findChild(key[depth])
+		if child == nil {
+			return 0, false
+		}
+		current = *child
+		depth++
+	}
+	return 0, false
+}
+
+func (tree *Tree) ContainsKey(key string) bool {
+	_, ok := tree.Get(key)
+	return ok
+}
+
+// Put sets value of key
+func (tree *Tree) Put(key string, value int) {
+	if tree.insert(&tree.root, key, 0, value, false) {
+		tree.size++
+	}
+}
+
+// Add adds value to aggregate of key
+func (tree *Tree) Add(key string, value int) {
+	if tree.insert(&tree.root, key, 0, value, true) {
+		tree.size++
+	}
+}
+
+// insert updates key in subtree of ref (bytes of key up to depth are matched already), returns true for new key
+func (tree *Tree) insert(ref **node, key string, depth int, value int, add bool) bool {
+	for {
+		current := *ref
+		if current == nil {
+			*ref = tree.newLeaf(key, value)
+			return true
+		}
+
+		if current.kind == leafKind {
+			if current.key == key {
+				current.update(value, add)
+				return false
+			}
+			// replace leaf by node4 with common prefix of both keys
+			common := commonPrefix(current.key[depth:], key[depth:])
+			inner := tree.newNode4(key[depth : depth+common])
+			inner.addLeaf(current, depth+common)
+			inner.addLeaf(tree.newLeaf(key, value), depth+common)
+			*ref = inner
+			return true
+		}
+
+		if common := commonPrefix(current.prefix, key[depth:]); common < len(current.prefix) {
+			// key leaves prefix of node - new node4 gets common part of prefix, node keeps the rest
+			inner := tree.newNode4(current.prefix[:common])
+			inner.addChild(current.prefix[common], current)
+			current.prefix = current.prefix[common+1:]
+			inner.addLeaf(tree.newLeaf(key, value), depth+common)
+			*ref = inner
+			return true
+		}
+
+		depth += len(current.prefix)
+		if depth == len(key) {
+			if current.terminal != nil {
+				current.terminal.update(value, add)
+				return false
+			}
+			current.terminal = tree.newLeaf(key, value)
+			return true
+		}
+		child := current.findChild(key[depth])
+		if child == nil {
+			if grown := current.grow(); grown != current {
+				tree.account.Alloc(nodeBytes(grown.kind))
+				tree.account.Free(nodeBytes(current.kind))
+				*ref = grown
+			}
+			(*ref).addChild(key[depth], tree.newLeaf(key, value))
+			return true
+		}
+		ref = child
+		depth++
+	}
+}
+
+func (leaf *node) update(value int, add bool) {
+	if add {
+		value += leaf.value
+	}
+	leaf.value = value
+}
+
+func commonPrefix(first string, second string) int {
+	length := len(first)
+	if len(second) < length {
+		length = len(second)
+	}
+	for idx := 0; idx < length; idx++ {
+		if first[idx] != second[idx] {
+			return idx
+		}
+	}
+	return length
+}
+
+// addLeaf adds leaf to new inner node, bytes of leaf key up to depth are prefix of node
+func (inner *node) addLeaf(leaf *node, depth int) {
+	if depth == len(leaf.key) {
+		inner.terminal = leaf
+		return
+	}
+	inner.addChild(leaf.key[depth], leaf)
+}
+
+// findChild returns reference to child of byte, nil if there is no child
+func (inner *node) findChild(b byte) **node {
+	switch inner.kind {
+	case node4Kind, node16Kind:
+		for idx := 0; idx < inner.count; idx++ {
+			if inner.keys[idx] == b {
+				return &inner.children[idx]
+			}
+		}
+	case node48Kind:
+		if idx := inner.keys[b]; idx != 0 {
+			return &inner.children[idx-1]
+		}
+	case node256Kind:
+		if inner.children[b] != nil {
+			return &inner.children[b]
+		}
+	}
+	return nil
+}
+
+// addChild adds child of new byte to node which is not full
+func (inner *node) addChild(b byte, child *node) {
+	switch inner.kind {
+	case node4Kind, node16Kind:
+		idx := 0
+		for idx < inner.count && inner.keys[idx] < b {
+			idx++
+		}
+		copy(inner.keys[idx+1:inner.count+1], inner.keys[idx:inner.count])
+		copy(inner.children[idx+1:inner.count+1], inner.children[idx:inner.count])
+		inner.keys[idx] = b
+		inner.children[idx] = child
+	case node48Kind:
+		// children are never removed, so the next free slot is count
+		inner.children[inner.count] = child
+		inner.keys[b] = byte(inner.count + 1)
+	case node256Kind:
+		inner.children[b] = child
+	}
+	inner.count++
+}
+
+// grow returns node of the next kind with the same children if node is full, otherwise node itself
+func (inner *node) grow() *node {
+	if inner.count < len(inner.children) {
+		return inner
+	}
+	grown := &node{prefix: inner.prefix, terminal: inner.terminal, count: inner.count}
+	switch inner.kind {
+	case node4Kind:
+		grown.kind = node16Kind
+		grown.keys = make([]byte, 16)
+		grown.children = make([]*node, 16)
+		copy(grown.keys, inner.keys)
+		copy(grown.children, inner.children)
+	case node16Kind:
+		grown.kind = node48Kind
+		grown.keys = make([]byte, 256)
+		grown.children = make([]*node, 48)
+		for idx := 0; idx < inner.count; idx++ {
+			grown.keys[inner.keys[idx]] = byte(idx + 1)
+			grown.children[idx] = inner.children[idx]
+		}
+	case node48Kind:
+		grown.kind = node256Kind
+		grown.children = make([]*node, 256)
+		for b, idx := range inner.keys {
+			if idx != 0 {
+				grown.children[b] = inner.children[idx-1]
+			}
+		}
+	}
+	return grown
+}
+
+// forEachChild calls fn for children in ascending order of bytes until fn returns false
+func (inner *node) forEachChild(fn func(b byte, child *node) bool) bool {
+	switch inner.kind {
+	case node4Kind, node16Kind:
+		for idx := 0; idx < inner.count; idx++ {
+			if !fn(inner.keys[idx], inner.children[idx]) {
+				return false
+			}
+		}
+	case node48Kind:
+		for b, idx := range inner.keys {
+			if idx != 0 && !fn(byte(b), inner.children[idx-1]) {
+				return false
+			}
+		}
+	case node256Kind:
+		for b, child := range inner.children {
+			if child != nil && !fn(byte(b), child) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Range calls fn for every key in ascending order
+func (tree *Tree) Range(fn func(key string, value int)) {
+	if tree.root != nil {
+		tree.root.walk(fn)
+	}
+}
+
+func (current *node) walk(fn func(key string, value int)) {
+	if current.kind == leafKind {
+		fn(current.key, current.value)
+		return
+	}
+	if current.terminal != nil {
+		fn(current.terminal.key, current.terminal.value)
+	}
+	current.forEachChild(func(_ byte, child *node) bool {
+		child.walk(fn)
+		return true
+	})
+}
+
+// RangePrefix calls fn for every key starting with prefix in ascending order
+func (tree *Tree) RangePrefix(prefix string, fn func(key string, value int)) {
+	current := tree.root
+	depth := 0
+	for current != nil {
+		if current.kind == leafKind {
+			if strings.HasPrefix(current.key, prefix) {
+				fn(current.key, current.value)
+			}
+			return
+		}
+		rest := prefix[depth:]
+		if len(rest) <= len(current.prefix) {
+			// all keys of subtree start with prefix or none of them
+			if strings.HasPrefix(current.prefix, rest) {
+				current.walk(fn)
+			}
+			return
+		}
+		if !strings.HasPrefix(rest, current.prefix) {
+			return
+		}
+		depth += len(current.prefix)
+		child := current.findChild(prefix[depth])
+		if child == nil {
+			return
+		}
+		current = *child
+		depth++
+	}
+}
+
+// RangeBetween calls fn for every key in [from, to) in ascending order
+func (tree *Tree) RangeBetween(from string, to string, fn func(key string, value int)) {
+	if tree.root != nil {
+		tree.root.ascend(make([]byte, 0, 64), from, to, fn)
+	}
+}
+
+// ascend walks subtree of node with path (bytes of keys above node), returns false when key `to` is reached
+func (current *node) ascend(path []byte, from string, to string, fn func(key string, value int)) bool {
+	visit := func(leaf *node) bool {
+		if leaf.key >= to {
+			return false
+		}
+		if leaf.key >= from {
+			fn(leaf.key, leaf.value)
+		}
+		return true
+	}
+	if current.kind == leafKind {
+		return visit(current)
+	}
+
+	path = append(path, current.prefix...)
+	// all keys of subtree start with path
+	if string(path) >= to {
+		return false
+	}
+	if string(path) < from && !strings.HasPrefix(from, string(path)) {
+		return true
+	}
+	if current.terminal != nil && !visit(current.terminal) {
+		return false
+	}
+	return current.forEachChild(func(b byte, child *node) bool {
+		return child.ascend(append(path, b), from, to, fn)
+	})
+}
+
+// Stats returns number of nodes of every kind
+func (tree *Tree) Stats() Stats {
+	var stats Stats
+	if tree.root != nil {
+		tree.root.stats(&stats)
+	}
+	return stats
+}
+
+func (current *node) stats(stats *Stats) {
+	switch current.kind {
+	case leafKind:
+		stats.Leaves++
+		return
+	case node4Kind:
+		stats.Node4++
+	case node16Kind:
+		stats.Node16++
+	case node48Kind:
+		stats.Node48++
+	case node256Kind:
+		stats.Node256++
+	}
+	if current.terminal != nil {
+		stats.Leaves++
+	}
+	current.forEachChild(func(_ byte, child *node) bool {
+		child.stats(stats)
+		return true
+	})
+}
@@ -0,0 +1,163 @@
+package art
+
+import (
+	"group/base/memory"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// randomKey returns key of small alphabet, so keys share prefixes and are prefixes of each other
+func randomKey(random *rand.Rand) string {
+	alphabets := []string{"ab", "abcdefgh", "abcdefghijklmnopqrstuvwxyz0123456789"}
+	alphabet := alphabets[random.Intn(len(alphabets))]
+	key := make([]byte, random.Intn(6))
+	for idx := range key {
+		key[idx] = alphabet[random.Intn(len(alphabet))]
+	}
+	return string(key)
+}
+
+func collect(fn func(fn func(key string, value int))) ([]string, map[string]int) {
+	keys := make([]string, 0)
+	values := make(map[string]int)
+	fn(func(key string, value int) {
+		keys = append(keys, key)
+		values[key] = value
+	})
+	return keys, values
+}
+
+func TestTree(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	tree := new(Tree).New()
+	expected := make(map[string]int)
+	for op := 0; op < 30000; op++ {
+		key := randomKey(random)
+		if random.Intn(4) == 0 {
+			tree.Put(key, op)
+			expected[key] = op
+		} else {
+			tree.Add(key, op)
+			expected[key] += op
+		}
+	}
+	require.Equal(t, len(expected), tree.Size())
+
+	keys := make([]string, 0, len(expected))
+	for key, value := range expected {
+		actual, ok := tree.Get(key)
+		require.True(t, ok, "key %q", key)
+		require.Equal(t, value, actual, "key %q", key)
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, missing := range []string{"abcdefg", "zzz", "ab0", "\xff"} {
+		if _, ok := expected[missing]; !ok {
+			require.False(t, tree.ContainsKey(missing))
+		}
+	}
+
+	ranged, values := collect(tree.Range)
+	require.Equal(t, keys, ranged)
+	require.Equal(t, expected, values)
+
+	for _, prefix := range []string{"", "a", "ab", "abc", "b2", "hhh", "zz", "abab"} {
+		actual, _ := collect(func(fn func(key string, value int)) {
+			tree.RangePrefix(prefix, fn)
+		})
+		filtered := make([]string, 0)
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				filtered = append(filtered, key)
+			}
+		}
+		require.Equal(t, filtered, actual, "prefix %q", prefix)
+	}
+
+	for _, bounds := range [][2]string{{"", "b"}, {"ab", "ac"}, {"abc", "abcd"}, {"b", "b"}, {"c1", "z"}, {"a", "\xff"}} {
+		actual, _ := collect(func(fn func(key string, value int)) {
+			tree.RangeBetween(bounds[0], bounds[1], fn)
+		})
+		filtered := make([]string, 0)
+		for _, key := range keys {
+			if key >= bounds[0] && key < bounds[1] {
+				filtered = append(filtered, key)
+			}
+		}
+		require.Equal(t, filtered, actual, "range %q", bounds)
+	}
+}
+
+func TestTreeNodeGrowth(t *testing.T) {
+	for _, test := range []struct {
+		children int
+		stats    Stats
+	}{
+		{children: 1, stats: Stats{Leaves: 1}},
+		{children: 4, stats: Stats{Leaves: 4, Node4: 1}},
+		{children: 16, stats: Stats{Leaves: 16, Node16: 1}},
+		{children: 48, stats: Stats{Leaves: 48, Node48: 1}},
+		{children: 256, stats: Stats{Leaves: 256, Node256: 1}},
+	} {
+		tree := new(Tree).New()
+		for b := 0; b < test.children; b++ {
+			tree.Add("Android"+string([]byte{byte(b)}), b)
+		}
+		require.Equal(t, test.stats, tree.Stats(), "%d children", test.children)
+		for b := 0; b < test.children; b++ {
+			value, ok := tree.Get("Android" + string([]byte{byte(b)}))
+			require.True(t, ok)
+			require.Equal(t, b, value)
+		}
+		// prefix is compressed to the only inner node
+		if test.children > 1 {
+			require.Equal(t, "Android", tree.root.prefix)
+		}
+	}
+}
+
+func TestTreePrefixKeys(t *testing.T) {
+	tree := new(Tree).New()
+	for idx, key := range []string{"Android 10", "Android", "Android 9", "iOS", "Android 10 Go", "", "An"} {
+		tree.Add(key, idx)
+	}
+	keys, _ := collect(tree.Range)
+	require.Equal(t, []string{"", "An", "Android", "Android 10", "Android 10 Go", "Android 9", "iOS"}, keys)
+
+	keys, _ = collect(func(fn func(key string, value int)) {
+		tree.RangePrefix("Android", fn)
+	})
+	require.Equal(t, []string{"Android", "Android 10", "Android 10 Go", "Android 9"}, keys)
+	value, ok := tree.Get("")
+	require.True(t, ok)
+	require.Equal(t, 5, value)
+	require.Equal(t, Stats{Leaves: 7, Node4: 5}, tree.Stats())
+	require.Equal(t, 7, tree.Size())
+}
+
+func TestTreeMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("tree", 0)
+	tree := new(Tree).NewWithTracker(tracker)
+	random := rand.New(rand.NewSource(1))
+	for idx := 0; idx < 5000; idx++ {
+		tree.Add(randomKey(random), idx)
+	}
+	// grown nodes are replaced, so memory is memory of nodes of the tree
+	stats := tree.Stats()
+	require.Equal(t, int64(stats.Leaves)*nodeBytes(leafKind)+int64(stats.Node4)*nodeBytes(node4Kind)+
+		int64(stats.Node16)*nodeBytes(node16Kind)+int64(stats.Node48)*nodeBytes(node48Kind)+
+		int64(stats.Node256)*nodeBytes(node256Kind), tracker.Used())
+	require.NoError(t, tree.Err())
+	tree.Release()
+	require.Zero(t, tracker.Used())
+
+	limited := new(Tree).NewWithTracker(new(memory.Tracker).New("tree", 10*nodeBytes(leafKind)))
+	for b := 0; b < 20; b++ {
+		limited.Add(string([]byte{byte(b)}), b)
+	}
+	require.ErrorIs(t, limited.Err(), memory.ErrMemoryLimitExceeded)
+}
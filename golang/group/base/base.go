@@ -3,9 +3,12 @@ package base
 import (
 	"bufio"
 	"encoding/csv"
+	"group/base/batch"
 	"log"
+	"math/rand"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Phone struct {
@@ -64,8 +67,21 @@ func MapPhone(record []string) Phone {
 	}
 }
 
+func (phone Phone) SellersAmount() int {
+	return phone.sellersAmount
+}
+
+// ReleaseYear returns year of release date in `month-year` format, 0 if release date is unknown
+func (phone Phone) ReleaseYear() int {
+	year, _ := strconv.Atoi(phone.releaseDate[strings.LastIndexByte(phone.releaseDate, '-')+1:])
+	return year
+}
+
+// DataPath is path of phones data relative to directory of driver package
+const DataPath = "../../base/test/phones_data.csv"
+
 func Data() [][]string {
-	file, fileErr := os.Open("../../base/test/phones_data.csv")
+	file, fileErr := os.Open(DataPath)
 	if fileErr != nil {
 		log.Fatalln(fileErr)
 	}
@@ -84,3 +100,69 @@ func Data() [][]string {
 
 	return results
 }
+
+// SyntheticData generates records (with csv caption) in the same layout as phones_data.csv,
+// values of `os` column are spread uniformly over cardinality distinct keys
+func SyntheticData(rows int, cardinality int) [][]string {
+	random := rand.New(rand.NewSource(int64(rows*31 + cardinality)))
+
+	results := make([][]string, 0, rows+1)
+	results = append(results, []string{"", "brand_name", "model_name", "os", "popularity", "best_price",
+		"lowest_price", "highest_price", "sellers_amount", "screen_size", "memory_size", "battery_size",
+		"release_date", "bucket_id"})
+	for i := 0; i < rows; i++ {
+		key := random.Intn(cardinality)
+		results = append(results, []string{strconv.Itoa(i), "brand-" + strconv.Itoa(key%64),
+			"model-" + strconv.Itoa(i), "os-" + strconv.Itoa(key), strconv.Itoa(random.Intn(1000)),
+			"0.0", "0.0", "0.0", "1", "0.0", "0.0", "0.0", "1-2020", "0"})
+	}
+	return results
+}
+
+// SumPopularityByOs sums popularity by os over records (with csv caption), reference result of group by in tests
+func SumPopularityByOs(records [][]string) map[string]int {
+	groups := make(map[string]int)
+	for _, record := range records[1:] {
+		phone := MapPhone(record)
+		if phone.Os != "" {
+			groups[phone.Os] += phone.Popularity
+		}
+	}
+	return groups
+}
+
+// columns of phones data
+const (
+	IdColumn = iota
+	BrandNameColumn
+	ModelNameColumn
+	OsColumn
+	PopularityColumn
+	BestPriceColumn
+	LowestPriceColumn
+	HighestPriceColumn
+	SellersAmountColumn
+	ScreenSizeColumn
+	MemorySizeColumn
+	BatterySizeColumn
+	ReleaseDateColumn
+	BucketIdColumn
+)
+
+// PhonesSchema is schema of phones data for columnar batches
+var PhonesSchema = batch.Schema{
+	{Name: "id", Type: batch.Int64},
+	{Name: "brand_name", Type: batch.String},
+	{Name: "model_name", Type: batch.String},
+	{Name: "os", Type: batch.String},
+	{Name: "popularity", Type: batch.Int64},
+	{Name: "best_price", Type: batch.Float64},
+	{Name: "lowest_price", Type: batch.Float64},
+	{Name: "highest_price", Type: batch.Float64},
+	{Name: "sellers_amount", Type: batch.Int64},
+	{Name: "screen_size", Type: batch.Float64},
+	{Name: "memory_size", Type: batch.Float64},
+	{Name: "battery_size", Type: batch.Float64},
+	{Name: "release_date", Type: batch.String},
+	{Name: "bucket_id", Type: batch.Int64},
+}
@@ -0,0 +1,220 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"group/base/memory"
+	"strings"
+	"unsafe"
+)
+
+/*
+Batch is columnar in-memory format - every column of batch is vector of values of one type:
+- int64 and float64 columns are plain vectors;
+- string column keeps all values in one string with offsets of values, so value is substring without allocation;
+- every column has validity bitmap (empty csv field is null), nil bitmap means all values are valid.
+Numbers are parsed once on decoding, aggregation reads columns directly instead of re-parsing rows.
+Batch made with tracker reports buffers of its columns (values, offsets, string data and bitmaps) to tracker.
+*/
+
+type Type int
+
+const (
+	Int64 Type = iota
+	Float64
+	String
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int64:
+		return "int64"
+	case Float64:
+		return "float64"
+	case String:
+		return "string"
+	}
+	return fmt.Sprintf("Type(%d)", int(t))
+}
+
+type Field struct {
+	Name string
+	Type Type
+}
+
+type Schema []Field
+
+// Index returns index of field by name, -1 if there is no such field
+func (schema Schema) Index(name string) int {
+	for idx, field := range schema {
+		if field.Name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+var ErrTypeMismatch = errors.New("batch: column type mismatch")
+
+// Bitmap is validity bitmap, bit is set for valid value
+type Bitmap []uint64
+
+func NewBitmap(length int) Bitmap {
+	return make(Bitmap, (length+63)/64)
+}
+
+func (bitmap Bitmap) Set(idx int) {
+	bitmap[idx/64] |= 1 << (idx % 64)
+}
+
+func (bitmap Bitmap) bytes() int64 {
+	return int64(cap(bitmap)) * int64(unsafe.Sizeof(uint64(0)))
+}
+
+// IsValid returns true if value is valid, every value of nil bitmap is valid
+func (bitmap Bitmap) IsValid(idx int) bool {
+	return bitmap == nil || bitmap[idx/64]&(1<<(idx%64)) != 0
+}
+
+type Column interface {
+	Type() Type
+	Len() int
+	IsValid(row int) bool
+}
+
+type Int64Column struct {
+	Values   []int64
+	Validity Bitmap
+}
+
+func (column *Int64Column) Type() Type           { return Int64 }
+func (column *Int64Column) Len() int             { return len(column.Values) }
+func (column *Int64Column) IsValid(row int) bool { return column.Validity.IsValid(row) }
+
+type Float64Column struct {
+	Values   []float64
+	Validity Bitmap
+}
+
+func (column *Float64Column) Type() Type           { return Float64 }
+func (column *Float64Column) Len() int             { return len(column.Values) }
+func (column *Float64Column) IsValid(row int) bool { return column.Validity.IsValid(row) }
+
+type StringColumn struct {
+	// Data keeps all values one by one, value of row is Data[Offsets[row]:Offsets[row+1]]
+	Data     string
+	Offsets  []int32
+	Validity Bitmap
+}
+
+func (column *StringColumn) Type() Type           { return String }
+func (column *StringColumn) Len() int             { return len(column.Offsets) - 1 }
+func (column *StringColumn) IsValid(row int) bool { return column.Validity.IsValid(row) }
+
+// Value returns value of row as substring of Data, so there is no allocation
+func (column *StringColumn) Value(row int) string {
+	return column.Data[column.Offsets[row]:column.Offsets[row+1]]
+}
+
+type Batch struct {
+	Schema  Schema
+	Columns []Column
+	Rows    int
+	// account of buffers of columns, batch is immutable, so buffers are accounted once
+	account *memory.Account
+}
+
+var _ memory.Accounted = (*Batch)(nil)
+
+func (batch *Batch) Err() error {
+	return batch.account.Err()
+}
+
+func (batch *Batch) Release() {
+	batch.account.Release()
+}
+
+// Bytes returns memory taken by buffers of columns
+func (batch *Batch) Bytes() int64 {
+	var bytes int64
+	for _, column := range batch.Columns {
+		switch column := column.(type) {
+		case *Int64Column:
+			bytes += int64(cap(column.Values))*int64(unsafe.Sizeof(int64(0))) + column.Validity.bytes()
+		case *Float64Column:
+			bytes += int64(cap(column.Values))*int64(unsafe.Sizeof(float64(0))) + column.Validity.bytes()
+		case *StringColumn:
+			bytes += int64(len(column.Data)) + int64(cap(column.Offsets))*int64(unsafe.Sizeof(int32(0))) +
+				column.Validity.bytes()
+		}
+	}
+	return bytes
+}
+
+func (batch *Batch) Int64(column int) (*Int64Column, error) {
+	result, ok := batch.Columns[column].(*Int64Column)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is %s", ErrTypeMismatch, batch.Schema[column].Name, batch.Schema[column].Type)
+	}
+	return result, nil
+}
+
+func (batch *Batch) Float64(column int) (*Float64Column, error) {
+	result, ok := batch.Columns[column].(*Float64Column)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is %s", ErrTypeMismatch, batch.Schema[column].Name, batch.Schema[column].Type)
+	}
+	return result, nil
+}
+
+func (batch *Batch) String(column int) (*StringColumn, error) {
+	result, ok := batch.Columns[column].(*StringColumn)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is %s", ErrTypeMismatch, batch.Schema[column].Name, batch.Schema[column].Type)
+	}
+	return result, nil
+}
+
+// builder appends values of one column
+type builder interface {
+	append(value string, row int) error
+	build() Column
+}
+
+type int64Builder struct {
+	column Int64Column
+}
+
+type float64Builder struct {
+	column Float64Column
+}
+
+type stringBuilder struct {
+	data     strings.Builder
+	offsets  []int32
+	validity Bitmap
+}
+
+func newBuilder(fieldType Type, rows int) builder {
+	switch fieldType {
+	case Int64:
+		return &int64Builder{column: Int64Column{Values: make([]int64, 0, rows), Validity: NewBitmap(rows)}}
+	case Float64:
+		return &float64Builder{column: Float64Column{Values: make([]float64, 0, rows), Validity: NewBitmap(rows)}}
+	default:
+		return &stringBuilder{offsets: append(make([]int32, 0, rows+1), 0), validity: NewBitmap(rows)}
+	}
+}
+
+func (builder *stringBuilder) append(value string, row int) error {
+	if value != "" {
+		builder.validity.Set(row)
+	}
+	builder.data.WriteString(value)
+	builder.offsets = append(builder.offsets, int32(builder.data.Len()))
+	return nil
+}
+
+func (builder *stringBuilder) build() Column {
+	return &StringColumn{Data: builder.data.String(), Offsets: builder.offsets, Validity: builder.validity}
+}
@@ -0,0 +1,137 @@
+package batch
+
+import (
+	"errors"
+	"group/base/memory"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var schema = Schema{
+	{Name: "id", Type: Int64},
+	{Name: "os", Type: String},
+	{Name: "price", Type: Float64},
+}
+
+func TestFromRecords(t *testing.T) {
+	records := [][]string{
+		{"0", "Android", "1690.0"},
+		{"1", "", "5.5"},
+		{"2.0", "iOS", ""},
+	}
+	batch, err := FromRecords(records, schema)
+	require.NoError(t, err)
+	require.Equal(t, 3, batch.Rows)
+
+	ids, err := batch.Int64(0)
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 1, 2}, ids.Values)
+
+	os, err := batch.String(1)
+	require.NoError(t, err)
+	require.Equal(t, 3, os.Len())
+	require.Equal(t, "Android", os.Value(0))
+	require.Equal(t, "", os.Value(1))
+	require.Equal(t, "iOS", os.Value(2))
+	require.False(t, os.IsValid(1))
+
+	prices, err := batch.Float64(2)
+	require.NoError(t, err)
+	require.Equal(t, []float64{1690, 5.5, 0}, prices.Values)
+	require.True(t, prices.IsValid(1))
+	require.False(t, prices.IsValid(2))
+
+	_, err = batch.Int64(1)
+	require.True(t, errors.Is(err, ErrTypeMismatch))
+}
+
+func TestFromRecordsErrors(t *testing.T) {
+	_, err := FromRecords([][]string{{"0", "Android"}}, schema)
+	require.Error(t, err)
+
+	_, err = FromRecords([][]string{{"zero", "Android", "1.0"}}, schema)
+	require.Error(t, err)
+}
+
+func TestBitmap(t *testing.T) {
+	bitmap := NewBitmap(130)
+	require.Len(t, bitmap, 3)
+	bitmap.Set(0)
+	bitmap.Set(129)
+	require.True(t, bitmap.IsValid(0))
+	require.False(t, bitmap.IsValid(64))
+	require.True(t, bitmap.IsValid(129))
+
+	var all Bitmap
+	require.True(t, all.IsValid(100))
+}
+
+func TestFromRecordsWithBatchSize(t *testing.T) {
+	records := make([][]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		records = append(records, []string{"1", "Android", "1.0"})
+	}
+	batches, err := FromRecordsWithBatchSize(records, schema, 4)
+	require.NoError(t, err)
+	require.Len(t, batches, 3)
+	require.Equal(t, 4, batches[0].Rows)
+	require.Equal(t, 2, batches[2].Rows)
+}
+
+func TestDecoder(t *testing.T) {
+	csv := "id,os,price\n" +
+		"0,Android,1.0\n" +
+		"1,iOS,2.0\n" +
+		"2,,3.0\n"
+	decoder, err := NewDecoder(strings.NewReader(csv), schema, 2)
+	require.NoError(t, err)
+
+	rows := 0
+	keys := make([]string, 0)
+	for {
+		batch, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.LessOrEqual(t, batch.Rows, 2)
+		os, _ := batch.String(1)
+		for idx := 0; idx < batch.Rows; idx++ {
+			keys = append(keys, os.Value(idx))
+		}
+		rows += batch.Rows
+	}
+	require.Equal(t, 3, rows)
+	require.Equal(t, []string{"Android", "iOS", ""}, keys)
+}
+
+func TestBatchMemoryTracking(t *testing.T) {
+	csv := "id,os,price\n" +
+		"0,Android,1.0\n" +
+		"1,iOS,2.0\n" +
+		"2,,3.0\n"
+	tracker := new(memory.Tracker).New("batches", 0)
+	decoder, err := NewDecoderWithTracker(strings.NewReader(csv), schema, 2, tracker)
+	require.NoError(t, err)
+
+	first, err := decoder.Decode()
+	require.NoError(t, err)
+	// values of id and price, offsets and data of os, one bitmap word per column
+	require.Equal(t, int64(2*8+2*8+3*4+len("AndroidiOS")+3*8), first.Bytes())
+	require.Equal(t, first.Bytes(), tracker.Used())
+	second, err := decoder.Decode()
+	require.NoError(t, err)
+	require.NoError(t, second.Err())
+	require.Equal(t, first.Bytes()+second.Bytes(), tracker.Used())
+
+	first.Release()
+	second.Release()
+	require.Zero(t, tracker.Used())
+
+	limited, err := FromRecordsWithTracker([][]string{{"0", "Android", "1.0"}}, schema, new(memory.Tracker).New("batch", 8))
+	require.NoError(t, err)
+	require.ErrorIs(t, limited.Err(), memory.ErrMemoryLimitExceeded)
+}
@@ -0,0 +1,160 @@
+package batch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"group/base/memory"
+	"io"
+	"strconv"
+)
+
+const DefaultBatchSize = 1024
+
+func (builder *int64Builder) append(value string, row int) error {
+	if value == "" {
+		builder.column.Values = append(builder.column.Values, 0)
+		return nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		// numbers like `5.0` are valid integers too
+		float, floatErr := strconv.ParseFloat(value, 64)
+		if floatErr != nil {
+			return err
+		}
+		parsed = int64(float)
+	}
+	builder.column.Values = append(builder.column.Values, parsed)
+	builder.column.Validity.Set(row)
+	return nil
+}
+
+func (builder *int64Builder) build() Column {
+	return &builder.column
+}
+
+func (builder *float64Builder) append(value string, row int) error {
+	if value == "" {
+		builder.column.Values = append(builder.column.Values, 0)
+		return nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	builder.column.Values = append(builder.column.Values, parsed)
+	builder.column.Validity.Set(row)
+	return nil
+}
+
+func (builder *float64Builder) build() Column {
+	return &builder.column
+}
+
+// FromRecords decodes records (without csv caption) to one batch
+func FromRecords(records [][]string, schema Schema) (*Batch, error) {
+	builders := make([]builder, len(schema))
+	for idx, field := range schema {
+		builders[idx] = newBuilder(field.Type, len(records))
+	}
+	for row, record := range records {
+		if len(record) < len(schema) {
+			return nil, fmt.Errorf("batch: row %d has %d fields, schema has %d", row, len(record), len(schema))
+		}
+		for idx := range schema {
+			if err := builders[idx].append(record[idx], row); err != nil {
+				return nil, fmt.Errorf("batch: row %d, field %s: %w", row, schema[idx].Name, err)
+			}
+		}
+	}
+
+	batch := &Batch{Schema: schema, Columns: make([]Column, len(schema)), Rows: len(records)}
+	for idx, builder := range builders {
+		batch.Columns[idx] = builder.build()
+	}
+	return batch, nil
+}
+
+// FromRecordsWithTracker decodes records (without csv caption) to one batch reporting its buffers to tracker
+func FromRecordsWithTracker(records [][]string, schema Schema, tracker *memory.Tracker) (*Batch, error) {
+	batch, err := FromRecords(records, schema)
+	if err != nil {
+		return nil, err
+	}
+	batch.account = new(memory.Account).New(tracker)
+	batch.account.Alloc(batch.Bytes())
+	return batch, nil
+}
+
+// FromRecordsWithBatchSize splits records (without csv caption) to batches of batchSize rows
+func FromRecordsWithBatchSize(records [][]string, schema Schema, batchSize int) ([]*Batch, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	batches := make([]*Batch, 0, (len(records)+batchSize-1)/batchSize)
+	for start := 0; start < len(records); start += batchSize {
+		end := start + batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		batch, err := FromRecords(records[start:end], schema)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// Decoder decodes csv stream to batches without reading the whole stream in memory
+type Decoder struct {
+	reader    *csv.Reader
+	schema    Schema
+	batchSize int
+	records   [][]string
+	// tracker accounts buffers of decoded batches, every batch is released by caller
+	tracker *memory.Tracker
+}
+
+// NewDecoder makes decoder of csv stream, first line of csv (caption) is skipped
+func NewDecoder(reader io.Reader, schema Schema, batchSize int) (*Decoder, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	csvReader := csv.NewReader(reader)
+	// pass csv caption
+	if _, err := csvReader.Read(); err != nil {
+		return nil, err
+	}
+	return &Decoder{reader: csvReader, schema: schema, batchSize: batchSize,
+		records: make([][]string, 0, batchSize)}, nil
+}
+
+// NewDecoderWithTracker makes decoder of csv stream reporting buffers of decoded batches to tracker
+func NewDecoderWithTracker(reader io.Reader, schema Schema, batchSize int, tracker *memory.Tracker) (*Decoder, error) {
+	decoder, err := NewDecoder(reader, schema, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	decoder.tracker = tracker
+	return decoder, nil
+}
+
+// Decode returns next batch of at most batchSize rows, io.EOF when stream is over
+func (decoder *Decoder) Decode() (*Batch, error) {
+	decoder.records = decoder.records[:0]
+	for len(decoder.records) < decoder.batchSize {
+		record, err := decoder.reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		decoder.records = append(decoder.records, record)
+	}
+	if len(decoder.records) == 0 {
+		return nil, io.EOF
+	}
+	return FromRecordsWithTracker(decoder.records, decoder.schema, decoder.tracker)
+}
@@ -0,0 +1,223 @@
+package btree
+
+import (
+	"group/base/memory"
+	"sort"
+	"unsafe"
+)
+
+/*
+In-memory B-tree (CLRS) of string keys and int aggregates.
+
+Node of tree of degree t keeps from t-1 to 2t-1 sorted keys (root may keep less) in one slice, internal node has
+child per gap between keys, all leaves are on the same depth. Unlike binary tree (one key and two pointers per
+node), keys of node sit next to each other, so search in node is binary search over contiguous memory and tree of
+n keys has log_t(n) levels only.
+
+Insert goes down from root once: full child (2t-1 keys) met on the way is split in advance - median key goes up to
+parent (which is not full), so split never goes back up to root. Root is split by new root above it.
+Keys are never removed from aggregation, so there is no delete.
+*/
+
+// DefaultDegree is degree of tree, node keeps up to 2*DefaultDegree-1 keys
+const DefaultDegree = 32
+
+// memory accounted by tracker - key with value, node and link to child (spare capacity of slices is not accounted)
+const (
+	KeyBytes  = int64(unsafe.Sizeof("") + unsafe.Sizeof(0))
+	NodeBytes = int64(unsafe.Sizeof(node{}))
+	LinkBytes = int64(unsafe.Sizeof(&node{}))
+)
+
+type node struct {
+	keys   []string
+	values []int
+	// children is nil for leaf
+	children []*node
+}
+
+type BTree struct {
+	root    *node
+	degree  int
+	size    int
+	account *memory.Account
+}
+
+func (tree *BTree) New() *BTree {
+	return tree.NewWithDegree(DefaultDegree)
+}
+
+// NewWithDegree returns tree with node keeping up to 2*degree-1 keys, degree is at least 2
+func (tree *BTree) NewWithDegree(degree int) *BTree {
+	if degree < 2 {
+		degree = 2
+	}
+	return &BTree{root: &node{}, degree: degree}
+}
+
+// NewWithTracker returns tree of degree reporting memory of its keys and nodes to tracker
+func (tree *BTree) NewWithTracker(degree int, tracker *memory.Tracker) *BTree {
+	result := tree.NewWithDegree(degree)
+	result.account = new(memory.Account).New(tracker)
+	result.account.Alloc(NodeBytes)
+	return result
+}
+
+var _ memory.Accounted = (*BTree)(nil)
+
+func (tree *BTree) Err() error {
+	return tree.account.Err()
+}
+
+func (tree *BTree) Release() {
+	tree.account.Release()
+}
+
+func (tree *BTree) Size() int {
+	return tree.size
+}
+
+// Height returns number of levels of tree
+func (tree *BTree) Height() int {
+	height := 1
+	for current := tree.root; current.children != nil; current = current.children[0] {
+		height++
+	}
+	return height
+}
+
+func (tree *BTree) Get(key string) (int, bool) {
+	current := tree.root
+	for {
+		idx := sort.SearchStrings(current.keys, key)
+		if idx < len(current.keys) && current.keys[idx] == key {
+			return current.values[idx], true
+		}
+		if current.children == nil {
+			return 0, false
+		}
+		current = current.children[idx]
+	}
+}
+
+func (tree *BTree) ContainsKey(key string) bool {
+	_, ok := tree.Get(key)
+	return ok
+}
+
+// Put sets value of key
+func (tree *BTree) Put(key string, value int) {
+	tree.update(key, value, false)
+}
+
+// Add adds value to aggregate of key
+func (tree *BTree) Add(key string, value int) {
+	tree.update(key, value, true)
+}
+
+func (tree *BTree) update(key string, value int, add bool) {
+	maxKeys := 2*tree.degree - 1
+	if len(tree.root.keys) == maxKeys {
+		root := &node{children: []*node{tree.root}}
+		root.splitChild(0, tree.degree)
+		tree.root = root
+		// new root with two children and right node of split
+		tree.account.Alloc(2*NodeBytes + 2*LinkBytes)
+	}
+
+	current := tree.root
+	for {
+		idx := sort.SearchStrings(current.keys, key)
+		if idx < len(current.keys) && current.keys[idx] == key {
+			if add {
+				value += current.values[idx]
+			}
+			current.values[idx] = value
+			return
+		}
+		if current.children == nil {
+			current.keys = insertAt(current.keys, idx, key)
+			current.values = insertAt(current.values, idx, value)
+			tree.size++
+			tree.account.Alloc(KeyBytes)
+			return
+		}
+
+		if len(current.children[idx].keys) == maxKeys {
+			// split full child in advance, its median goes to current node at idx
+			current.splitChild(idx, tree.degree)
+			tree.account.Alloc(NodeBytes + LinkBytes)
+			if key == current.keys[idx] {
+				continue
+			}
+			if key > current.keys[idx] {
+				idx++
+			}
+		}
+		current = current.children[idx]
+	}
+}
+
+// splitChild splits full child at idx to two nodes of degree-1 keys, median key is inserted to node
+func (current *node) splitChild(idx int, degree int) {
+	child := current.children[idx]
+	right := &node{
+		keys:   append(make([]string, 0, 2*degree-1), child.keys[degree:]...),
+		values: append(make([]int, 0, 2*degree-1), child.values[degree:]...),
+	}
+	if child.children != nil {
+		right.children = append(make([]*node, 0, 2*degree), child.children[degree:]...)
+		for i := degree; i < len(child.children); i++ {
+			child.children[i] = nil
+		}
+		child.children = child.children[:degree]
+	}
+	medianKey, medianValue := child.keys[degree-1], child.values[degree-1]
+	// release keys moved to right node
+	for i := degree - 1; i < len(child.keys); i++ {
+		child.keys[i] = ""
+	}
+	child.keys = child.keys[:degree-1]
+	child.values = child.values[:degree-1]
+
+	current.keys = insertAt(current.keys, idx, medianKey)
+	current.values = insertAt(current.values, idx, medianValue)
+	current.children = insertAt(current.children, idx+1, right)
+}
+
+func insertAt[T any](items []T, idx int, item T) []T {
+	var empty T
+	items = append(items, empty)
+	copy(items[idx+1:], items[idx:])
+	items[idx] = item
+	return items
+}
+
+// Range calls fn for every key in ascending order
+func (tree *BTree) Range(fn func(key string, value int)) {
+	tree.root.ascend("", "", false, fn)
+}
+
+// RangeBetween calls fn for every key in [from, to) in ascending order
+func (tree *BTree) RangeBetween(from string, to string, fn func(key string, value int)) {
+	tree.root.ascend(from, to, true, fn)
+}
+
+// ascend visits keys from `from`, returns false when key `to` is reached
+func (current *node) ascend(from string, to string, bounded bool, fn func(key string, value int)) bool {
+	// children before the first key not less than from keep keys less than from
+	start := sort.SearchStrings(current.keys, from)
+	for idx := start; idx <= len(current.keys); idx++ {
+		if current.children != nil && !current.children[idx].ascend(from, to, bounded, fn) {
+			return false
+		}
+		if idx == len(current.keys) {
+			break
+		}
+		if bounded && current.keys[idx] >= to {
+			return false
+		}
+		fn(current.keys[idx], current.values[idx])
+	}
+	return true
+}
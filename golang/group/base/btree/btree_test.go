@@ -0,0 +1,141 @@
+package btree
+
+import (
+	"fmt"
+	"group/base/memory"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// checkNode checks order and number of keys of node and returns depth of leaves under node
+func checkNode(t *testing.T, current *node, degree int, root bool, low string, high string, bounded bool) int {
+	require.Len(t, current.values, len(current.keys))
+	require.LessOrEqual(t, len(current.keys), 2*degree-1)
+	if !root {
+		require.GreaterOrEqual(t, len(current.keys), degree-1)
+	}
+	for idx, key := range current.keys {
+		require.True(t, key > low || (idx == 0 && key == low && low == ""))
+		if bounded {
+			require.Less(t, key, high)
+		}
+		if idx > 0 {
+			require.Less(t, current.keys[idx-1], key)
+		}
+	}
+	if current.children == nil {
+		return 1
+	}
+
+	require.Len(t, current.children, len(current.keys)+1)
+	depth := -1
+	for idx, child := range current.children {
+		childLow, childHigh, childBounded := low, high, bounded
+		if idx > 0 {
+			childLow = current.keys[idx-1]
+		}
+		if idx < len(current.keys) {
+			childHigh, childBounded = current.keys[idx], true
+		}
+		childDepth := checkNode(t, child, degree, false, childLow, childHigh, childBounded)
+		if depth == -1 {
+			depth = childDepth
+		}
+		require.Equal(t, depth, childDepth, "leaves are on different depth")
+	}
+	return depth + 1
+}
+
+func TestBTree(t *testing.T) {
+	for _, degree := range []int{2, 3, 32} {
+		random := rand.New(rand.NewSource(int64(degree)))
+		tree := new(BTree).NewWithDegree(degree)
+		expected := make(map[string]int)
+		for op := 0; op < 20000; op++ {
+			key := fmt.Sprint(random.Intn(3000))
+			if random.Intn(4) == 0 {
+				tree.Put(key, op)
+				expected[key] = op
+			} else {
+				tree.Add(key, op)
+				expected[key] += op
+			}
+		}
+		require.Equal(t, tree.Height(), checkNode(t, tree.root, degree, true, "", "", false))
+		require.Equal(t, len(expected), tree.Size())
+
+		keys := make([]string, 0, len(expected))
+		for key, value := range expected {
+			actual, ok := tree.Get(key)
+			require.True(t, ok)
+			require.Equal(t, value, actual)
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		require.False(t, tree.ContainsKey("missing"))
+
+		ranged := make([]string, 0, len(keys))
+		tree.Range(func(key string, value int) {
+			require.Equal(t, expected[key], value)
+			ranged = append(ranged, key)
+		})
+		require.Equal(t, keys, ranged)
+
+		for _, bounds := range [][2]string{{"", "5"}, {"12", "13"}, {"250", "2500"}, {"5", "5"}, {"9", "a"}} {
+			between := make([]string, 0)
+			tree.RangeBetween(bounds[0], bounds[1], func(key string, value int) {
+				between = append(between, key)
+			})
+			expectedBetween := make([]string, 0)
+			for _, key := range keys {
+				if key >= bounds[0] && key < bounds[1] {
+					expectedBetween = append(expectedBetween, key)
+				}
+			}
+			require.Equal(t, expectedBetween, between, "range %v", bounds)
+		}
+	}
+}
+
+func TestBTreeHeight(t *testing.T) {
+	tree := new(BTree).New()
+	for key := 0; key < 100000; key++ {
+		tree.Add(fmt.Sprint(key), 1)
+	}
+	// node keeps at least 31 keys, so 100000 keys need 4 levels at most
+	require.LessOrEqual(t, tree.Height(), 4)
+}
+
+// countNodes returns number of nodes and links to children under node
+func countNodes(current *node) (int64, int64) {
+	nodes, links := int64(1), int64(len(current.children))
+	for _, child := range current.children {
+		childNodes, childLinks := countNodes(child)
+		nodes, links = nodes+childNodes, links+childLinks
+	}
+	return nodes, links
+}
+
+func TestBTreeMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("tree", 0)
+	tree := new(BTree).NewWithTracker(2, tracker)
+	for i := 0; i < 1000; i++ {
+		tree.Add(fmt.Sprint(i%500), 1)
+	}
+	require.Greater(t, tree.Height(), 3)
+	nodes, links := countNodes(tree.root)
+	require.Equal(t, nodes*NodeBytes+links*LinkBytes+500*KeyBytes, tracker.Used())
+	require.NoError(t, tree.Err())
+	tree.Release()
+	require.Zero(t, tracker.Used())
+
+	tree = new(BTree).NewWithTracker(DefaultDegree, new(memory.Tracker).New("tree", 100*KeyBytes))
+	for i := 0; i < 200; i++ {
+		tree.Put(fmt.Sprint(i), i)
+	}
+	require.ErrorIs(t, tree.Err(), memory.ErrMemoryLimitExceeded)
+	require.Equal(t, 200, tree.Size())
+}
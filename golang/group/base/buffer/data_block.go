@@ -1,7 +1,7 @@
 package buffer
 
-// let's make default block size as 24 lines
-const dataBlockSize = 24
+// DataBlockSize is default block size, let's make it as 24 lines
+const DataBlockSize = 24
 
 type DataBlock struct {
 	blockBuffer DataBuffer
@@ -10,6 +10,16 @@ type DataBlock struct {
 type Partitioning []DataBlock
 
 func MakePartitioning(results [][]string) (Partitioning, error) {
+	return MakePartitioningWithBlockSize(results, DataBlockSize)
+}
+
+// MakePartitioningWithBlockSize splits results (skipping csv caption) into data blocks of blockSize lines,
+// the last block keeps the reminder of lines
+func MakePartitioningWithBlockSize(results [][]string, blockSize int) (Partitioning, error) {
+	if blockSize <= 0 {
+		blockSize = DataBlockSize
+	}
+
 	var partitioning Partitioning
 
 	var dataBlockCounter = 0
@@ -25,7 +35,7 @@ func MakePartitioning(results [][]string) (Partitioning, error) {
 		}
 		dataBlockCounter++
 
-		if dataBlockCounter%dataBlockSize == 0 {
+		if dataBlockCounter%blockSize == 0 {
 			dataBlock := partitioning.AddBlock(buf)
 			partitioning = append(partitioning, *dataBlock)
 
@@ -33,6 +43,12 @@ func MakePartitioning(results [][]string) (Partitioning, error) {
 		}
 	}
 
+	// insert last block
+	if buf.Len() > 0 {
+		dataBlock := partitioning.AddBlock(buf)
+		partitioning = append(partitioning, *dataBlock)
+	}
+
 	return partitioning, nil
 }
 
@@ -41,7 +57,7 @@ func (p *Partitioning) AddBlock(blockBuffer DataBuffer) *DataBlock {
 }
 
 func (b *DataBlock) Read() [][]string {
-	res := b.blockBuffer.Next(dataBlockSize)
+	res := b.blockBuffer.Next(b.blockBuffer.Len())
 	b.blockBuffer.Reset()
 	return res
 }
@@ -2,11 +2,34 @@ package buffer
 
 import (
 	"errors"
+	"group/base/memory"
+	"unsafe"
 )
 
 type DataBuffer struct {
 	buf [][]string
 	off int
+
+	// tracker accounts memory of line slots, err keeps first error of accounting
+	tracker *memory.Tracker
+	err     error
+}
+
+// lineBytes is memory taken by slot of one line
+const lineBytes = int64(unsafe.Sizeof([]string{}))
+
+// Track makes buffer report memory of its line slots to tracker
+func (b *DataBuffer) Track(tracker *memory.Tracker) {
+	b.tracker = tracker
+	if err := tracker.Alloc(int64(cap(b.buf)) * lineBytes); err != nil && b.err == nil {
+		b.err = err
+	}
+}
+
+// Release returns memory of buffer to tracker
+func (b *DataBuffer) Release() {
+	b.tracker.Free(int64(cap(b.buf)) * lineBytes)
+	b.tracker = nil
 }
 
 var ErrTooLarge = errors.New("DataBuffer: too large")
@@ -68,6 +91,9 @@ func (b *DataBuffer) grow(n int) int {
 		panic(ErrTooLarge)
 	} else {
 		buf := makeSlice(2*c + n)
+		if err := b.tracker.Alloc(int64(cap(buf)-c) * lineBytes); err != nil && b.err == nil {
+			b.err = err
+		}
 		copy(buf, b.buf[b.off:])
 		b.buf = buf
 	}
@@ -82,7 +108,7 @@ func (b *DataBuffer) WriteLine(s []string) error {
 		m = b.grow(1)
 	}
 	b.buf[m] = s
-	return nil
+	return b.err
 }
 
 func (b *DataBuffer) Next(n int) [][]string {
@@ -0,0 +1,143 @@
+package dictionary
+
+import (
+	"errors"
+	"group/base/memory"
+	"sync"
+	"unsafe"
+)
+
+/*
+Dictionary encoding of string column - every distinct key of column gets dense code, so aggregation runs on codes
+(as index of array) instead of hashing and comparing strings on every row, keys are decoded only at output.
+Codes of uint8 / uint16 keys are keys of lookup table (see golang/group/base/lookup_table).
+
+Dictionary is safe for concurrent use and can be:
+- per block - every block (morsel) is encoded by its own dictionary (its lock is never contended), codes of blocks
+  are remapped to codes of one dictionary on merge;
+- global - all blocks are encoded by one shared dictionary, codes are the same everywhere and do not need remapping,
+  but encoding of new key takes write lock.
+
+Memory tracker (if set) accounts entry of every key - string in slice of keys and string with code in map of codes,
+bytes of keys are not accounted since they are shared with keys of input rows.
+*/
+
+var ErrFull = errors.New("dictionary is full")
+
+// Code is type of dense code of key
+type Code interface {
+	uint8 | uint16 | uint32
+}
+
+type Dictionary[K Code] struct {
+	mutex sync.RWMutex
+	codes map[string]K
+	keys  []string
+	// account of entries of keys, dictionary is never shrunk
+	account *memory.Account
+}
+
+func New[K Code]() *Dictionary[K] {
+	return &Dictionary[K]{codes: make(map[string]K)}
+}
+
+// NewWithTracker makes dictionary reporting memory of its entries to tracker
+func NewWithTracker[K Code](tracker *memory.Tracker) *Dictionary[K] {
+	dictionary := New[K]()
+	dictionary.account = new(memory.Account).New(tracker)
+	return dictionary
+}
+
+// EntryBytes returns memory taken by entry of one key
+func (dictionary *Dictionary[K]) EntryBytes() int64 {
+	var code K
+	return int64(2*unsafe.Sizeof("") + unsafe.Sizeof(code))
+}
+
+var _ memory.Accounted = (*Dictionary[uint32])(nil)
+
+func (dictionary *Dictionary[K]) Err() error {
+	return dictionary.account.Err()
+}
+
+func (dictionary *Dictionary[K]) Release() {
+	dictionary.account.Release()
+}
+
+// Encode returns code of key, new code is assigned on first met. Returns false if dictionary is full.
+func (dictionary *Dictionary[K]) Encode(key string) (K, bool) {
+	dictionary.mutex.RLock()
+	code, ok := dictionary.codes[key]
+	dictionary.mutex.RUnlock()
+	if ok {
+		return code, true
+	}
+
+	dictionary.mutex.Lock()
+	defer dictionary.mutex.Unlock()
+	if code, ok = dictionary.codes[key]; ok {
+		return code, true
+	}
+	if uint64(len(dictionary.keys)) > uint64(^K(0)) {
+		return 0, false
+	}
+	code = K(len(dictionary.keys))
+	dictionary.codes[key] = code
+	dictionary.keys = append(dictionary.keys, key)
+	dictionary.account.Alloc(dictionary.EntryBytes())
+	return code, true
+}
+
+func (dictionary *Dictionary[K]) Decode(code K) string {
+	dictionary.mutex.RLock()
+	defer dictionary.mutex.RUnlock()
+	return dictionary.keys[code]
+}
+
+func (dictionary *Dictionary[K]) Size() int {
+	dictionary.mutex.RLock()
+	defer dictionary.mutex.RUnlock()
+	return len(dictionary.keys)
+}
+
+// Merge adds keys of other dictionary, returns remapping of codes of other dictionary to codes of the dictionary.
+// Returns false if dictionary is full.
+func (dictionary *Dictionary[K]) Merge(other *Dictionary[K]) ([]K, bool) {
+	other.mutex.RLock()
+	keys := other.keys
+	other.mutex.RUnlock()
+
+	remapping := make([]K, len(keys))
+	for code, key := range keys {
+		remapped, ok := dictionary.Encode(key)
+		if !ok {
+			return nil, false
+		}
+		remapping[code] = remapped
+	}
+	return remapping, true
+}
+
+// Column is string column of block encoded by dictionary
+type Column struct {
+	Codes      []uint32
+	Dictionary *Dictionary[uint32]
+}
+
+// EncodeColumn encodes column of rows by new per block dictionary
+func EncodeColumn(rows [][]string, column int) (Column, error) {
+	return EncodeColumnWith(rows, column, New[uint32]())
+}
+
+// EncodeColumnWith encodes column of rows by given (as example global) dictionary
+func EncodeColumnWith(rows [][]string, column int, dictionary *Dictionary[uint32]) (Column, error) {
+	codes := make([]uint32, len(rows))
+	for idx, row := range rows {
+		code, ok := dictionary.Encode(row[column])
+		if !ok {
+			return Column{}, ErrFull
+		}
+		codes[idx] = code
+	}
+	return Column{Codes: codes, Dictionary: dictionary}, nil
+}
@@ -0,0 +1,105 @@
+package dictionary
+
+import (
+	"group/base/memory"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encode[K Code](t *testing.T, dictionary *Dictionary[K], key string) K {
+	code, ok := dictionary.Encode(key)
+	require.True(t, ok)
+	return code
+}
+
+func TestDictionary(t *testing.T) {
+	dictionary := New[uint32]()
+	require.Equal(t, uint32(0), encode(t, dictionary, "Android"))
+	require.Equal(t, uint32(1), encode(t, dictionary, "iOS"))
+	require.Equal(t, uint32(0), encode(t, dictionary, "Android"))
+	require.Equal(t, "iOS", dictionary.Decode(1))
+	require.Equal(t, 2, dictionary.Size())
+
+	other := New[uint32]()
+	encode(t, other, "Windows")
+	encode(t, other, "iOS")
+	remapping, ok := dictionary.Merge(other)
+	require.True(t, ok)
+	require.Equal(t, []uint32{2, 1}, remapping)
+	require.Equal(t, 3, dictionary.Size())
+}
+
+func TestEncodeColumn(t *testing.T) {
+	rows := [][]string{{"1", "Android"}, {"2", "iOS"}, {"3", "Android"}, {"4", ""}}
+
+	column, err := EncodeColumn(rows, 1)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{0, 1, 0, 2}, column.Codes)
+	for idx, code := range column.Codes {
+		require.Equal(t, rows[idx][1], column.Dictionary.Decode(code))
+	}
+
+	global := New[uint32]()
+	encode(t, global, "iOS")
+	column, err = EncodeColumnWith(rows, 1, global)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 0, 1, 2}, column.Codes)
+	require.Same(t, global, column.Dictionary)
+}
+
+func TestConcurrentEncode(t *testing.T) {
+	dictionary := New[uint8]()
+
+	var wg sync.WaitGroup
+	codes := make([][]uint8, 4)
+	for worker := range codes {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 256; i++ {
+				code, ok := dictionary.Encode("key-" + strconv.Itoa(i))
+				require.True(t, ok)
+				codes[worker] = append(codes[worker], code)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	// every worker got the same codes
+	for worker := range codes {
+		require.Equal(t, codes[0], codes[worker])
+	}
+	for i, code := range codes[0] {
+		require.Equal(t, "key-"+strconv.Itoa(i), dictionary.Decode(code))
+	}
+	require.Equal(t, 256, dictionary.Size())
+
+	// dictionary of uint8 codes is full
+	_, ok := dictionary.Encode("one more")
+	require.False(t, ok)
+	other := New[uint8]()
+	encode(t, other, "other")
+	_, ok = dictionary.Merge(other)
+	require.False(t, ok)
+}
+
+func TestDictionaryMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("dictionary", 0)
+	dictionary := NewWithTracker[uint16](tracker)
+	for idx := 0; idx < 100; idx++ {
+		encode(t, dictionary, strconv.Itoa(idx%50))
+	}
+	require.Equal(t, 50*dictionary.EntryBytes(), tracker.Used())
+	require.NoError(t, dictionary.Err())
+	dictionary.Release()
+	require.Zero(t, tracker.Used())
+
+	limited := NewWithTracker[uint16](new(memory.Tracker).New("dictionary", 10*dictionary.EntryBytes()))
+	for idx := 0; idx < 20; idx++ {
+		encode(t, limited, strconv.Itoa(idx))
+	}
+	require.ErrorIs(t, limited.Err(), memory.ErrMemoryLimitExceeded)
+}
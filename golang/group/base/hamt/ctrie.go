@@ -0,0 +1,493 @@
+package hamt
+
+import (
+	"errors"
+	"group/base/memory"
+	"math/bits"
+	"sync/atomic"
+	"unsafe"
+)
+
+/*
+Ctrie - concurrent HAMT with lock-free updates and O(1) snapshots,
+http://aleksandar-prokopec.com/resources/docs/ctries-snapshot.pdf
+
+Nodes of HAMT (C-nodes) are immutable, every C-node is referenced by mutable indirection node (I-node). Update copies
+C-node with changed slot and swaps it in I-node by CAS, so updates of different I-nodes do not interfere and update
+failed on concurrent change of the same I-node is just retried.
+
+Snapshot:
+- every I-node belongs to generation, snapshot replaces root by its copy of new generation (by RDCSS, which checks
+  root I-node is not changed concurrently), so old root with the whole trie belongs to snapshot;
+- writer meeting I-node of older generation on the path copies C-node of parent with children renewed to its
+  generation before going down, so nodes of snapshot are never changed, they are copied lazily by writers;
+- CAS of I-node is GCAS - new C-node is committed only if generation of root is still the generation of I-node,
+  otherwise CAS is rolled back, so writer started before snapshot can not change nodes owned by snapshot.
+
+Keys are never removed from aggregation, so this Ctrie has no remove (and no tomb nodes of original paper).
+Memory tracker (if set) accounts S-node and branch of every key added, nodes shared with snapshots are not accounted,
+writable snapshot reports keys added to it to the same tracker.
+*/
+
+var ErrReadOnlySnapshot = errors.New("update of read-only snapshot")
+
+// generation is compared by pointer, it has non-zero size, so different generations never share address
+type generation struct {
+	_ int
+}
+
+type sNode[K comparable, V any] struct {
+	hash  uint64
+	key   K
+	value V
+}
+
+type branch[K comparable, V any] struct {
+	// iNode is sub-trie, otherwise branch is sNode
+	iNode *iNode[K, V]
+	sNode *sNode[K, V]
+}
+
+type cNode[K comparable, V any] struct {
+	bitmap   uint32
+	branches []branch[K, V]
+	gen      *generation
+}
+
+type mainNode[K comparable, V any] struct {
+	cNode *cNode[K, V]
+	// lNode is list of entries with the same full hash
+	lNode []*sNode[K, V]
+	// prev is previous main node of I-node while GCAS is not committed
+	prev atomic.Pointer[mainNode[K, V]]
+	// failed marks rolled back GCAS, it keeps main node to restore
+	failed *mainNode[K, V]
+}
+
+type iNode[K comparable, V any] struct {
+	main atomic.Pointer[mainNode[K, V]]
+	gen  *generation
+	// rdcss is set for descriptor of root replacement, descriptor is put to root instead of I-node while in progress
+	rdcss *rdcssDescriptor[K, V]
+}
+
+type rdcssDescriptor[K comparable, V any] struct {
+	old         *iNode[K, V]
+	expected    *mainNode[K, V]
+	replacement *iNode[K, V]
+	committed   atomic.Bool
+}
+
+type Ctrie[K comparable, V any] struct {
+	root     atomic.Pointer[iNode[K, V]]
+	readOnly bool
+	hash     func(key K) uint64
+	account  *memory.Account
+}
+
+// NewCtrie returns empty concurrent trie with hash function of keys
+func NewCtrie[K comparable, V any](hash func(key K) uint64) *Ctrie[K, V] {
+	gen := new(generation)
+	root := &iNode[K, V]{gen: gen}
+	root.main.Store(&mainNode[K, V]{cNode: &cNode[K, V]{gen: gen}})
+	return newCtrie(root, hash, false, nil)
+}
+
+// NewCtrieWithTracker returns empty concurrent trie with hash function of keys reporting memory of keys to tracker
+func NewCtrieWithTracker[K comparable, V any](hash func(key K) uint64, tracker *memory.Tracker) *Ctrie[K, V] {
+	ctrie := NewCtrie[K, V](hash)
+	ctrie.account = new(memory.Account).New(tracker)
+	return ctrie
+}
+
+// EntryBytes returns memory taken by S-node of key and its branch
+func (ctrie *Ctrie[K, V]) EntryBytes() int64 {
+	return int64(unsafe.Sizeof(sNode[K, V]{}) + unsafe.Sizeof(branch[K, V]{}))
+}
+
+var _ memory.Accounted = (*Ctrie[string, int])(nil)
+
+func (ctrie *Ctrie[K, V]) Err() error {
+	return ctrie.account.Err()
+}
+
+// snapshots share account of the trie, so Release invalidates them too
+func (ctrie *Ctrie[K, V]) Release() {
+	ctrie.account.Release()
+}
+
+// NewCtrieWithStringKeys returns empty concurrent trie of string keys
+func NewCtrieWithStringKeys[V any]() *Ctrie[string, V] {
+	return NewCtrie[string, V](HashString)
+}
+
+func newCtrie[K comparable, V any](root *iNode[K, V], hash func(key K) uint64, readOnly bool,
+	account *memory.Account) *Ctrie[K, V] {
+	ctrie := &Ctrie[K, V]{readOnly: readOnly, hash: hash, account: account}
+	ctrie.root.Store(root)
+	return ctrie
+}
+
+func (ctrie *Ctrie[K, V]) ReadOnly() bool {
+	return ctrie.readOnly
+}
+
+func (ctrie *Ctrie[K, V]) Get(key K) (V, bool) {
+	hash := ctrie.hash(key)
+	for {
+		root := ctrie.readRoot()
+		if value, ok, done := ctrie.lookup(root, hash, key, 0, root.gen); done {
+			return value, ok
+		}
+	}
+}
+
+// Put sets value of key, panics with ErrReadOnlySnapshot for read-only snapshot
+func (ctrie *Ctrie[K, V]) Put(key K, value V) {
+	ctrie.Update(key, func(V, bool) V {
+		return value
+	})
+}
+
+// Update sets value of key to fn of current value (and whether key exists) atomically, fn may be called several
+// times on concurrent updates of the same node, so it must not have side effects.
+// Panics with ErrReadOnlySnapshot for read-only snapshot.
+func (ctrie *Ctrie[K, V]) Update(key K, fn func(value V, ok bool) V) {
+	if ctrie.readOnly {
+		panic(ErrReadOnlySnapshot)
+	}
+	hash := ctrie.hash(key)
+	// fn of committed attempt tells whether key is added
+	added := false
+	update := func(value V, ok bool) V {
+		added = !ok
+		return fn(value, ok)
+	}
+	for {
+		root := ctrie.readRoot()
+		if ctrie.insert(root, hash, key, update, 0, root.gen) {
+			if added {
+				ctrie.account.Alloc(ctrie.EntryBytes())
+			}
+			return
+		}
+	}
+}
+
+// ReadOnlySnapshot returns consistent read-only view of trie in O(1), trie stays writable
+func (ctrie *Ctrie[K, V]) ReadOnlySnapshot() *Ctrie[K, V] {
+	if ctrie.readOnly {
+		return ctrie
+	}
+	for {
+		root := ctrie.readRoot()
+		main := ctrie.gcasRead(root)
+		if ctrie.rdcssRoot(root, main, ctrie.copyToGen(root, new(generation))) {
+			return newCtrie(root, ctrie.hash, true, nil)
+		}
+	}
+}
+
+// Snapshot returns writable copy of trie in O(1), trie and copy are changed independently
+func (ctrie *Ctrie[K, V]) Snapshot() *Ctrie[K, V] {
+	if ctrie.readOnly {
+		return newCtrie(ctrie.copyToGen(ctrie.readRoot(), new(generation)), ctrie.hash, false, ctrie.account)
+	}
+	for {
+		root := ctrie.readRoot()
+		main := ctrie.gcasRead(root)
+		if ctrie.rdcssRoot(root, main, ctrie.copyToGen(root, new(generation))) {
+			return newCtrie(ctrie.copyToGen(root, new(generation)), ctrie.hash, false, ctrie.account)
+		}
+	}
+}
+
+// Range calls fn for every key and value of read-only snapshot of trie until fn returns false
+func (ctrie *Ctrie[K, V]) Range(fn func(key K, value V) bool) {
+	snapshot := ctrie.ReadOnlySnapshot()
+	snapshot.iterate(snapshot.readRoot(), fn)
+}
+
+// Size returns number of keys of read-only snapshot of trie, it takes O(n)
+func (ctrie *Ctrie[K, V]) Size() int {
+	size := 0
+	ctrie.Range(func(K, V) bool {
+		size++
+		return true
+	})
+	return size
+}
+
+func (ctrie *Ctrie[K, V]) iterate(in *iNode[K, V], fn func(key K, value V) bool) bool {
+	main := ctrie.gcasRead(in)
+	if main.cNode == nil {
+		for _, sn := range main.lNode {
+			if !fn(sn.key, sn.value) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, branch := range main.cNode.branches {
+		if branch.iNode != nil {
+			if !ctrie.iterate(branch.iNode, fn) {
+				return false
+			}
+			continue
+		}
+		if !fn(branch.sNode.key, branch.sNode.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns value of key, done is false if lookup has to be restarted from root
+func (ctrie *Ctrie[K, V]) lookup(in *iNode[K, V], hash uint64, key K, shift int,
+	startGen *generation) (value V, ok bool, done bool) {
+	main := ctrie.gcasRead(in)
+	if main.cNode == nil {
+		for _, sn := range main.lNode {
+			if sn.key == key {
+				return sn.value, true, true
+			}
+		}
+		return value, false, true
+	}
+
+	cn := main.cNode
+	bit := uint32(1) << (hash >> shift & levelMask)
+	if cn.bitmap&bit == 0 {
+		return value, false, true
+	}
+	branch := cn.branches[cn.position(bit)]
+	if branch.iNode != nil {
+		if ctrie.readOnly || branch.iNode.gen == startGen {
+			return ctrie.lookup(branch.iNode, hash, key, shift+bitsPerLevel, startGen)
+		}
+		// sub-trie is owned by snapshot - renew it to generation of the trie
+		if ctrie.gcas(in, main, &mainNode[K, V]{cNode: ctrie.renewed(cn, startGen)}) {
+			return ctrie.lookup(in, hash, key, shift, startGen)
+		}
+		return value, false, false
+	}
+	if branch.sNode.hash == hash && branch.sNode.key == key {
+		return branch.sNode.value, true, true
+	}
+	return value, false, true
+}
+
+// insert updates value of key, returns false if insert has to be restarted from root
+func (ctrie *Ctrie[K, V]) insert(in *iNode[K, V], hash uint64, key K, fn func(V, bool) V, shift int,
+	startGen *generation) bool {
+	var empty V
+	main := ctrie.gcasRead(in)
+	if main.cNode == nil {
+		lNode := make([]*sNode[K, V], 0, len(main.lNode)+1)
+		found := false
+		for _, sn := range main.lNode {
+			if sn.key == key {
+				sn = &sNode[K, V]{hash: hash, key: key, value: fn(sn.value, true)}
+				found = true
+			}
+			lNode = append(lNode, sn)
+		}
+		if !found {
+			lNode = append(lNode, &sNode[K, V]{hash: hash, key: key, value: fn(empty, false)})
+		}
+		return ctrie.gcas(in, main, &mainNode[K, V]{lNode: lNode})
+	}
+
+	cn := main.cNode
+	bit := uint32(1) << (hash >> shift & levelMask)
+	position := cn.position(bit)
+	if cn.bitmap&bit == 0 {
+		renewed := cn
+		if cn.gen != in.gen {
+			renewed = ctrie.renewed(cn, in.gen)
+		}
+		inserted := branch[K, V]{sNode: &sNode[K, V]{hash: hash, key: key, value: fn(empty, false)}}
+		return ctrie.gcas(in, main, &mainNode[K, V]{cNode: renewed.inserted(position, bit, inserted, in.gen)})
+	}
+
+	existing := cn.branches[position]
+	if existing.iNode != nil {
+		if existing.iNode.gen == startGen {
+			return ctrie.insert(existing.iNode, hash, key, fn, shift+bitsPerLevel, startGen)
+		}
+		// sub-trie is owned by snapshot - renew it to generation of the trie
+		if ctrie.gcas(in, main, &mainNode[K, V]{cNode: ctrie.renewed(cn, startGen)}) {
+			return ctrie.insert(in, hash, key, fn, shift, startGen)
+		}
+		return false
+	}
+
+	sn := existing.sNode
+	if sn.hash == hash && sn.key == key {
+		updated := branch[K, V]{sNode: &sNode[K, V]{hash: hash, key: key, value: fn(sn.value, true)}}
+		return ctrie.gcas(in, main, &mainNode[K, V]{cNode: cn.updated(position, updated, in.gen)})
+	}
+
+	// different keys share bits of the level - entry is split to sub-trie
+	renewed := cn
+	if cn.gen != in.gen {
+		renewed = ctrie.renewed(cn, in.gen)
+	}
+	child := &iNode[K, V]{gen: in.gen}
+	child.main.Store(splitEntries(sn, &sNode[K, V]{hash: hash, key: key, value: fn(empty, false)},
+		shift+bitsPerLevel, in.gen))
+	return ctrie.gcas(in, main, &mainNode[K, V]{cNode: renewed.updated(position, branch[K, V]{iNode: child}, in.gen)})
+}
+
+// splitEntries returns main node of two entries, list node if they have the same full hash
+func splitEntries[K comparable, V any](first *sNode[K, V], second *sNode[K, V], shift int,
+	gen *generation) *mainNode[K, V] {
+	if shift >= 64 {
+		return &mainNode[K, V]{lNode: []*sNode[K, V]{first, second}}
+	}
+	firstIdx, secondIdx := first.hash>>shift&levelMask, second.hash>>shift&levelMask
+	if firstIdx == secondIdx {
+		child := &iNode[K, V]{gen: gen}
+		child.main.Store(splitEntries(first, second, shift+bitsPerLevel, gen))
+		return &mainNode[K, V]{cNode: &cNode[K, V]{bitmap: 1 << firstIdx, branches: []branch[K, V]{{iNode: child}}, gen: gen}}
+	}
+	if firstIdx > secondIdx {
+		first, second = second, first
+		firstIdx, secondIdx = secondIdx, firstIdx
+	}
+	return &mainNode[K, V]{cNode: &cNode[K, V]{bitmap: 1<<firstIdx | 1<<secondIdx,
+		branches: []branch[K, V]{{sNode: first}, {sNode: second}}, gen: gen}}
+}
+
+// position returns index of branch of bit in compact slice of branches
+func (cn *cNode[K, V]) position(bit uint32) int {
+	return bits.OnesCount32(cn.bitmap & (bit - 1))
+}
+
+func (cn *cNode[K, V]) inserted(position int, bit uint32, inserted branch[K, V], gen *generation) *cNode[K, V] {
+	branches := make([]branch[K, V], len(cn.branches)+1)
+	copy(branches, cn.branches[:position])
+	branches[position] = inserted
+	copy(branches[position+1:], cn.branches[position:])
+	return &cNode[K, V]{bitmap: cn.bitmap | bit, branches: branches, gen: gen}
+}
+
+func (cn *cNode[K, V]) updated(position int, updated branch[K, V], gen *generation) *cNode[K, V] {
+	branches := make([]branch[K, V], len(cn.branches))
+	copy(branches, cn.branches)
+	branches[position] = updated
+	return &cNode[K, V]{bitmap: cn.bitmap, branches: branches, gen: gen}
+}
+
+// renewed returns copy of C-node with child I-nodes copied to generation
+func (ctrie *Ctrie[K, V]) renewed(cn *cNode[K, V], gen *generation) *cNode[K, V] {
+	branches := make([]branch[K, V], len(cn.branches))
+	for idx, branch := range cn.branches {
+		if branch.iNode != nil {
+			branch.iNode = ctrie.copyToGen(branch.iNode, gen)
+		}
+		branches[idx] = branch
+	}
+	return &cNode[K, V]{bitmap: cn.bitmap, branches: branches, gen: gen}
+}
+
+func (ctrie *Ctrie[K, V]) copyToGen(in *iNode[K, V], gen *generation) *iNode[K, V] {
+	copied := &iNode[K, V]{gen: gen}
+	copied.main.Store(ctrie.gcasRead(in))
+	return copied
+}
+
+// gcas replaces main node of I-node if generation of I-node is generation of root
+func (ctrie *Ctrie[K, V]) gcas(in *iNode[K, V], old *mainNode[K, V], replacement *mainNode[K, V]) bool {
+	replacement.prev.Store(old)
+	if in.main.CompareAndSwap(old, replacement) {
+		ctrie.gcasComplete(in, replacement)
+		return replacement.prev.Load() == nil
+	}
+	return false
+}
+
+// gcasRead returns committed main node of I-node
+func (ctrie *Ctrie[K, V]) gcasRead(in *iNode[K, V]) *mainNode[K, V] {
+	main := in.main.Load()
+	if main.prev.Load() == nil {
+		return main
+	}
+	return ctrie.gcasComplete(in, main)
+}
+
+// gcasComplete commits or rolls back pending GCAS of main node
+func (ctrie *Ctrie[K, V]) gcasComplete(in *iNode[K, V], main *mainNode[K, V]) *mainNode[K, V] {
+	for {
+		prev := main.prev.Load()
+		root := ctrie.rdcssReadRoot(true)
+		if prev == nil {
+			return main
+		}
+		if prev.failed != nil {
+			// roll back failed GCAS
+			if in.main.CompareAndSwap(main, prev.failed) {
+				return prev.failed
+			}
+			main = in.main.Load()
+			continue
+		}
+		if root.gen == in.gen && !ctrie.readOnly {
+			if main.prev.CompareAndSwap(prev, nil) {
+				return main
+			}
+			continue
+		}
+		// snapshot was taken - mark GCAS failed
+		main.prev.CompareAndSwap(prev, &mainNode[K, V]{failed: prev})
+		main = in.main.Load()
+	}
+}
+
+func (ctrie *Ctrie[K, V]) readRoot() *iNode[K, V] {
+	return ctrie.rdcssReadRoot(false)
+}
+
+func (ctrie *Ctrie[K, V]) rdcssReadRoot(abort bool) *iNode[K, V] {
+	root := ctrie.root.Load()
+	if root.rdcss != nil {
+		return ctrie.rdcssComplete(abort)
+	}
+	return root
+}
+
+// rdcssRoot replaces root by replacement if root is old and main node of old is expected
+func (ctrie *Ctrie[K, V]) rdcssRoot(old *iNode[K, V], expected *mainNode[K, V], replacement *iNode[K, V]) bool {
+	descriptor := &iNode[K, V]{rdcss: &rdcssDescriptor[K, V]{old: old, expected: expected, replacement: replacement}}
+	if ctrie.root.CompareAndSwap(old, descriptor) {
+		ctrie.rdcssComplete(false)
+		return descriptor.rdcss.committed.Load()
+	}
+	return false
+}
+
+func (ctrie *Ctrie[K, V]) rdcssComplete(abort bool) *iNode[K, V] {
+	for {
+		root := ctrie.root.Load()
+		if root.rdcss == nil {
+			return root
+		}
+		descriptor := root.rdcss
+		if abort {
+			if ctrie.root.CompareAndSwap(root, descriptor.old) {
+				return descriptor.old
+			}
+			continue
+		}
+		if ctrie.gcasRead(descriptor.old) == descriptor.expected {
+			if ctrie.root.CompareAndSwap(root, descriptor.replacement) {
+				descriptor.committed.Store(true)
+				return descriptor.replacement
+			}
+			continue
+		}
+		if ctrie.root.CompareAndSwap(root, descriptor.old) {
+			return descriptor.old
+		}
+	}
+}
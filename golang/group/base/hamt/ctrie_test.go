@@ -0,0 +1,186 @@
+package hamt
+
+import (
+	"fmt"
+	"group/base/memory"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func requireCtrieSameAs(t *testing.T, expected map[int]int, actual *Ctrie[int, int]) {
+	for key, value := range expected {
+		actualValue, ok := actual.Get(key)
+		require.True(t, ok, "key %d", key)
+		require.Equal(t, value, actualValue, "key %d", key)
+	}
+	ranged := make(map[int]int)
+	actual.Range(func(key int, value int) bool {
+		_, ok := ranged[key]
+		require.False(t, ok, "key %d is met twice", key)
+		ranged[key] = value
+		return true
+	})
+	require.Equal(t, expected, ranged)
+	require.Equal(t, len(expected), actual.Size())
+}
+
+func TestCtrieAgainstMap(t *testing.T) {
+	for name, hash := range hashes {
+		random := rand.New(rand.NewSource(1))
+		expected := make(map[int]int)
+		actual := NewCtrie[int, int](hash)
+		for op := 0; op < 20000; op++ {
+			key := random.Intn(500)
+			if random.Intn(2) == 0 {
+				expected[key] = op
+				actual.Put(key, op)
+			} else {
+				expected[key] += op
+				actual.Update(key, func(value int, ok bool) int {
+					return value + op
+				})
+			}
+		}
+		requireCtrieSameAs(t, expected, actual)
+
+		_, ok := actual.Get(-1)
+		require.False(t, ok, name)
+	}
+}
+
+func TestCtrieConcurrentUpdate(t *testing.T) {
+	const writers, rounds, keys = 8, 20, 500
+	for name, hash := range hashes {
+		ctrie := NewCtrie[int, int](hash)
+		var wg sync.WaitGroup
+		for writer := 0; writer < writers; writer++ {
+			wg.Add(1)
+			go func(writer int) {
+				defer wg.Done()
+				for round := 0; round < rounds; round++ {
+					for key := 0; key < keys; key++ {
+						ctrie.Update((key+writer*31)%keys, func(value int, ok bool) int {
+							return value + 1
+						})
+					}
+				}
+			}(writer)
+		}
+		// snapshots are taken while writers go on
+		for snapshot := 0; snapshot < 20; snapshot++ {
+			ctrie.ReadOnlySnapshot().Range(func(key int, value int) bool {
+				require.LessOrEqual(t, value, writers*rounds, name)
+				return true
+			})
+		}
+		wg.Wait()
+
+		expected := make(map[int]int)
+		for key := 0; key < keys; key++ {
+			expected[key] = writers * rounds
+		}
+		requireCtrieSameAs(t, expected, ctrie)
+	}
+}
+
+func TestCtrieSnapshotConsistency(t *testing.T) {
+	const keys = 20000
+	ctrie := NewCtrieWithStringKeys[int]()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// keys are inserted in order, so consistent snapshot has prefix of keys
+		for key := 0; key < keys; key++ {
+			ctrie.Put(fmt.Sprint(key), key)
+		}
+	}()
+
+	snapshots := make([]*Ctrie[string, int], 0)
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		snapshots = append(snapshots, ctrie.ReadOnlySnapshot())
+		time.Sleep(100 * time.Microsecond)
+	}
+
+	previous := 0
+	for _, snapshot := range snapshots {
+		size := snapshot.Size()
+		require.GreaterOrEqual(t, size, previous)
+		previous = size
+		snapshot.Range(func(key string, value int) bool {
+			require.Less(t, value, size, "snapshot of %d keys has key %s", size, key)
+			return true
+		})
+		require.Panics(t, func() {
+			snapshot.Put("key", 1)
+		})
+	}
+	require.Equal(t, keys, ctrie.Size())
+}
+
+func TestCtrieWritableSnapshot(t *testing.T) {
+	ctrie := NewCtrieWithStringKeys[int]()
+	for key := 0; key < 1000; key++ {
+		ctrie.Put(fmt.Sprint(key), key)
+	}
+	snapshot := ctrie.Snapshot()
+	readOnly := ctrie.ReadOnlySnapshot()
+	for key := 0; key < 1000; key++ {
+		ctrie.Update(fmt.Sprint(key), func(value int, ok bool) int {
+			return value + 1
+		})
+		snapshot.Put(fmt.Sprint(key+1000), key)
+	}
+
+	require.Equal(t, 1000, ctrie.Size())
+	require.Equal(t, 2000, snapshot.Size())
+	require.Equal(t, 1000, readOnly.Size())
+	for key := 0; key < 1000; key++ {
+		value, _ := ctrie.Get(fmt.Sprint(key))
+		require.Equal(t, key+1, value)
+		value, _ = snapshot.Get(fmt.Sprint(key))
+		require.Equal(t, key, value)
+		value, _ = readOnly.Get(fmt.Sprint(key))
+		require.Equal(t, key, value)
+	}
+}
+
+func TestCtrieMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("trie", 0)
+	ctrie := NewCtrieWithTracker[int, int](func(key int) uint64 {
+		return uint64(key)
+	}, tracker)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// every worker updates the same keys, every key is accounted once
+			for key := 0; key < 1000; key++ {
+				ctrie.Update(key, func(value int, ok bool) int {
+					return value + 1
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 1000*ctrie.EntryBytes(), tracker.Used())
+
+	// keys added to writable snapshot are accounted too
+	snapshot := ctrie.Snapshot()
+	snapshot.Put(1000, 1)
+	snapshot.Put(0, 1)
+	require.Equal(t, 1001*ctrie.EntryBytes(), tracker.Used())
+	require.NoError(t, ctrie.Err())
+	ctrie.Release()
+	require.Zero(t, tracker.Used())
+}
@@ -1,43 +1,304 @@
 package hamt
 
-func hash_str(data string) uint64 {
+import (
+	"group/base/memory"
+	"math/bits"
+	"unsafe"
+)
+
+/*
+Hash array mapped trie - https://infoscience.epfl.ch/record/64398/files/idealhashtrees.pdf
+
+Every node covers 5 bits of hash of key (starting from the lowest ones) and has up to 32 slots, but keeps only used
+ones: bit of 32 bit bitmap tells slot is used, position of slot in compact slice of slots is number of lower bits set
+in bitmap (popcount). Slot is either sub-node (for the next 5 bits of hash) or entries of one full hash - single
+entry or collision node with several entries of the same full hash, which are compared by key.
+
+Trie is persistent: Put, Update and Delete never change existing nodes, they copy nodes on the path from root to the
+changed slot and share all the other nodes with previous version, previous version stays valid and unchanged.
+Delete keeps trie canonical - sub-node left with single entry (or collision node) is pulled up to its parent.
+
+Memory tracker (if set) accounts entry and slot of every key added by versions made of the trie, nodes shared between
+versions are not accounted. Delete frees nothing - entry removed from new version is still held by older ones, which
+share the account, so memory is returned only by Release.
+*/
+
+const (
+	bitsPerLevel = 5
+	levelMask    = 1<<bitsPerLevel - 1
+)
+
+// HashString is FNV-1a hash of string with murmur finalizer spreading all bits of hash for levels of trie
+func HashString(data string) uint64 {
 	var prime uint64 = 0x100000001b3
 	var hash uint64 = 0xcbf29ce484222325
-	for i := range data {
+	for i := 0; i < len(data); i++ {
 		hash ^= uint64(data[i])
 		hash *= prime
 	}
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
 	return hash
 }
 
-type Hamt struct {
-	children []*Hamt
-	key      string
-	value    int
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+type slot[K comparable, V any] struct {
+	// child is sub-node, otherwise slot keeps entries of hash (collision node if there are several of them)
+	child   *node[K, V]
+	hash    uint64
+	entries []entry[K, V]
+}
+
+type node[K comparable, V any] struct {
+	bitmap uint32
+	slots  []slot[K, V]
 }
 
-func hamt_find_indirect(root *Hamt, key string) **Hamt {
-	hash := hash_str(key)
-	hamt := &root
+type Hamt[K comparable, V any] struct {
+	root *node[K, V]
+	size int
+	hash func(key K) uint64
+	// account is shared by all versions of the trie
+	account *memory.Account
+}
 
-	for hamt != nil {
-		hamt = &(*hamt).children[hash&1]
-		if *hamt == nil || (*hamt).key == key {
+// New returns empty trie with hash function of keys
+func New[K comparable, V any](hash func(key K) uint64) *Hamt[K, V] {
+	return &Hamt[K, V]{root: &node[K, V]{}, hash: hash}
+}
+
+// NewWithStringKeys returns empty trie of string keys
+func NewWithStringKeys[V any]() *Hamt[string, V] {
+	return New[string, V](HashString)
+}
+
+// NewWithTracker returns empty trie with hash function of keys reporting memory of entries to tracker
+func NewWithTracker[K comparable, V any](hash func(key K) uint64, tracker *memory.Tracker) *Hamt[K, V] {
+	return &Hamt[K, V]{root: &node[K, V]{}, hash: hash, account: new(memory.Account).New(tracker)}
+}
+
+// EntryBytes returns memory taken by entry of key and its slot
+func (hamt *Hamt[K, V]) EntryBytes() int64 {
+	return int64(unsafe.Sizeof(entry[K, V]{}) + unsafe.Sizeof(slot[K, V]{}))
+}
+
+var _ memory.Accounted = (*Hamt[string, int])(nil)
+
+func (hamt *Hamt[K, V]) Err() error {
+	return hamt.account.Err()
+}
+
+// versions share account of the trie, so Release invalidates all of them
+func (hamt *Hamt[K, V]) Release() {
+	hamt.account.Release()
+}
+
+func (hamt *Hamt[K, V]) Size() int {
+	return hamt.size
+}
+
+func (hamt *Hamt[K, V]) Get(key K) (V, bool) {
+	hash := hamt.hash(key)
+	current := hamt.root
+	for shift := 0; ; shift += bitsPerLevel {
+		bit := uint32(1) << (hash >> shift & levelMask)
+		if current.bitmap&bit == 0 {
 			break
 		}
-		hash >>= 1
+		slot := &current.slots[current.position(bit)]
+		if slot.child != nil {
+			current = slot.child
+			continue
+		}
+		if slot.hash == hash {
+			for _, item := range slot.entries {
+				if item.key == key {
+					return item.value, true
+				}
+			}
+		}
+		break
+	}
+	var empty V
+	return empty, false
+}
+
+// Put returns new version of trie with value of key
+func (hamt *Hamt[K, V]) Put(key K, value V) *Hamt[K, V] {
+	return hamt.Update(key, func(V, bool) V {
+		return value
+	})
+}
+
+// Update returns new version of trie with value of key set to fn of current value (and whether key exists),
+// path to key is copied once, so Update is cheaper than Get + Put for aggregation
+func (hamt *Hamt[K, V]) Update(key K, fn func(value V, ok bool) V) *Hamt[K, V] {
+	root, added := hamt.root.update(hamt.hash(key), 0, key, fn)
+	size := hamt.size
+	if added {
+		size++
+		hamt.account.Alloc(hamt.EntryBytes())
+	}
+	return &Hamt[K, V]{root: root, size: size, hash: hamt.hash, account: hamt.account}
+}
+
+// Delete returns new version of trie without key, the same trie if there is no key
+func (hamt *Hamt[K, V]) Delete(key K) *Hamt[K, V] {
+	root, removed := hamt.root.delete(hamt.hash(key), 0, key)
+	if !removed {
+		return hamt
+	}
+	return &Hamt[K, V]{root: root, size: hamt.size - 1, hash: hamt.hash, account: hamt.account}
+}
+
+// Range calls fn for every key and value in order of hashes of keys until fn returns false
+func (hamt *Hamt[K, V]) Range(fn func(key K, value V) bool) {
+	hamt.root.iterate(fn)
+}
+
+// position returns index of slot of bit in compact slice of slots
+func (current *node[K, V]) position(bit uint32) int {
+	return bits.OnesCount32(current.bitmap & (bit - 1))
+}
+
+// withSlot returns copy of node with slot at position replaced
+func (current *node[K, V]) withSlot(position int, replacement slot[K, V]) *node[K, V] {
+	slots := make([]slot[K, V], len(current.slots))
+	copy(slots, current.slots)
+	slots[position] = replacement
+	return &node[K, V]{bitmap: current.bitmap, slots: slots}
+}
+
+// withInsertedSlot returns copy of node with new slot of bit
+func (current *node[K, V]) withInsertedSlot(bit uint32, inserted slot[K, V]) *node[K, V] {
+	position := current.position(bit)
+	slots := make([]slot[K, V], len(current.slots)+1)
+	copy(slots, current.slots[:position])
+	slots[position] = inserted
+	copy(slots[position+1:], current.slots[position:])
+	return &node[K, V]{bitmap: current.bitmap | bit, slots: slots}
+}
+
+// withoutSlot returns copy of node without slot of bit
+func (current *node[K, V]) withoutSlot(bit uint32) *node[K, V] {
+	position := current.position(bit)
+	slots := make([]slot[K, V], len(current.slots)-1)
+	copy(slots, current.slots[:position])
+	copy(slots[position:], current.slots[position+1:])
+	return &node[K, V]{bitmap: current.bitmap &^ bit, slots: slots}
+}
+
+func (current *node[K, V]) update(hash uint64, shift int, key K, fn func(V, bool) V) (*node[K, V], bool) {
+	bit := uint32(1) << (hash >> shift & levelMask)
+	if current.bitmap&bit == 0 {
+		var empty V
+		return current.withInsertedSlot(bit, slot[K, V]{hash: hash, entries: []entry[K, V]{{key, fn(empty, false)}}}), true
+	}
+
+	position := current.position(bit)
+	existing := current.slots[position]
+	if existing.child != nil {
+		child, added := existing.child.update(hash, shift+bitsPerLevel, key, fn)
+		return current.withSlot(position, slot[K, V]{child: child}), added
+	}
+
+	if existing.hash == hash {
+		// update entry of key or add key to collision node
+		for idx, item := range existing.entries {
+			if item.key == key {
+				entries := make([]entry[K, V], len(existing.entries))
+				copy(entries, existing.entries)
+				entries[idx].value = fn(item.value, true)
+				return current.withSlot(position, slot[K, V]{hash: hash, entries: entries}), false
+			}
+		}
+		var empty V
+		entries := make([]entry[K, V], len(existing.entries), len(existing.entries)+1)
+		copy(entries, existing.entries)
+		entries = append(entries, entry[K, V]{key, fn(empty, false)})
+		return current.withSlot(position, slot[K, V]{hash: hash, entries: entries}), true
 	}
 
-	return hamt
+	// different hashes share bits of the level - slot is split to sub-node
+	var empty V
+	added := slot[K, V]{hash: hash, entries: []entry[K, V]{{key, fn(empty, false)}}}
+	child := split(existing, added, shift+bitsPerLevel)
+	return current.withSlot(position, slot[K, V]{child: child}), true
 }
 
-func hamt_find(root *Hamt, key string) *Hamt {
-	return *hamt_find_indirect(root, key)
+// split returns sub-node of two slots with different hashes
+func split[K comparable, V any](first slot[K, V], second slot[K, V], shift int) *node[K, V] {
+	firstIdx, secondIdx := first.hash>>shift&levelMask, second.hash>>shift&levelMask
+	if firstIdx == secondIdx {
+		return &node[K, V]{bitmap: 1 << firstIdx, slots: []slot[K, V]{{child: split(first, second, shift+bitsPerLevel)}}}
+	}
+	if firstIdx > secondIdx {
+		first, second = second, first
+		firstIdx, secondIdx = secondIdx, firstIdx
+	}
+	return &node[K, V]{bitmap: 1<<firstIdx | 1<<secondIdx, slots: []slot[K, V]{first, second}}
 }
 
-func hamt_add(root *Hamt, add *Hamt) {
-	var found **Hamt = hamt_find_indirect(root, add.key)
-	if *found == nil {
-		*found = add
+func (current *node[K, V]) delete(hash uint64, shift int, key K) (*node[K, V], bool) {
+	bit := uint32(1) << (hash >> shift & levelMask)
+	if current.bitmap&bit == 0 {
+		return current, false
+	}
+
+	position := current.position(bit)
+	existing := current.slots[position]
+	if existing.child != nil {
+		child, removed := existing.child.delete(hash, shift+bitsPerLevel, key)
+		if !removed {
+			return current, false
+		}
+		switch {
+		case len(child.slots) == 0:
+			return current.withoutSlot(bit), true
+		case len(child.slots) == 1 && child.slots[0].child == nil:
+			// pull up single entry (or collision node)
+			return current.withSlot(position, child.slots[0]), true
+		default:
+			return current.withSlot(position, slot[K, V]{child: child}), true
+		}
+	}
+
+	if existing.hash != hash {
+		return current, false
+	}
+	for idx, item := range existing.entries {
+		if item.key != key {
+			continue
+		}
+		if len(existing.entries) == 1 {
+			return current.withoutSlot(bit), true
+		}
+		entries := make([]entry[K, V], 0, len(existing.entries)-1)
+		entries = append(entries, existing.entries[:idx]...)
+		entries = append(entries, existing.entries[idx+1:]...)
+		return current.withSlot(position, slot[K, V]{hash: hash, entries: entries}), true
+	}
+	return current, false
+}
+
+func (current *node[K, V]) iterate(fn func(key K, value V) bool) bool {
+	for _, slot := range current.slots {
+		if slot.child != nil {
+			if !slot.child.iterate(fn) {
+				return false
+			}
+			continue
+		}
+		for _, item := range slot.entries {
+			if !fn(item.key, item.value) {
+				return false
+			}
+		}
 	}
+	return true
 }
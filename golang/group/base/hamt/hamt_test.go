@@ -1,22 +1,234 @@
 package hamt
 
 import (
+	"fmt"
+	"group/base"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	v2 "group/base/hashmap/open_addressing/linear_probing/v2"
+	"group/base/memory"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestHamt(t *testing.T) {
-	root := Hamt{children: make([]*Hamt, 2), key: "", value: 0}
-	a := Hamt{children: make([]*Hamt, 2), key: "a", value: 1}
-	b := Hamt{children: make([]*Hamt, 2), key: "b", value: 2}
-	c := Hamt{children: make([]*Hamt, 2), key: "c", value: 3}
-
-	hamt_add(&root, &a)
-	hamt_add(&root, &b)
-	hamt_add(&root, &c)
-
-	require.True(t, hamt_find(&root, "a").value == 1)
-	require.True(t, hamt_find(&root, "b").value == 2)
-	require.True(t, hamt_find(&root, "c").value == 3)
+	root := NewWithStringKeys[int]()
+	root = root.Put("a", 1)
+	root = root.Put("b", 2)
+	root = root.Put("c", 3)
+
+	for key, expected := range map[string]int{"a": 1, "b": 2, "c": 3} {
+		value, ok := root.Get(key)
+		require.True(t, ok)
+		require.Equal(t, expected, value)
+	}
+	_, ok := root.Get("d")
+	require.False(t, ok)
+	require.Equal(t, 3, root.Size())
+}
+
+// hashes of int keys: good one, one with equal lower bits (deep tries) and one with full hash collisions
+var hashes = map[string]func(key int) uint64{
+	"good": func(key int) uint64 {
+		return HashString(fmt.Sprint(key))
+	},
+	"equal lower bits": func(key int) uint64 {
+		return uint64(key) << 40
+	},
+	"collisions": func(key int) uint64 {
+		return uint64(key % 7)
+	},
+}
+
+func requireSameAs(t *testing.T, expected map[int]int, actual *Hamt[int, int]) {
+	require.Equal(t, len(expected), actual.Size())
+	for key, value := range expected {
+		actualValue, ok := actual.Get(key)
+		require.True(t, ok, "key %d", key)
+		require.Equal(t, value, actualValue, "key %d", key)
+	}
+	ranged := make(map[int]int)
+	actual.Range(func(key int, value int) bool {
+		_, ok := ranged[key]
+		require.False(t, ok, "key %d is met twice", key)
+		ranged[key] = value
+		return true
+	})
+	require.Equal(t, expected, ranged)
+}
+
+func TestHamtAgainstMap(t *testing.T) {
+	for name, hash := range hashes {
+		random := rand.New(rand.NewSource(1))
+		expected := make(map[int]int)
+		actual := New[int, int](hash)
+		for op := 0; op < 20000; op++ {
+			key := random.Intn(500)
+			switch random.Intn(3) {
+			case 0:
+				expected[key] = op
+				actual = actual.Put(key, op)
+			case 1:
+				expected[key] += op
+				actual = actual.Update(key, func(value int, ok bool) int {
+					return value + op
+				})
+			default:
+				delete(expected, key)
+				actual = actual.Delete(key)
+			}
+			if op%1000 == 0 {
+				requireSameAs(t, expected, actual)
+			}
+		}
+		requireSameAs(t, expected, actual)
+
+		_, ok := actual.Get(-1)
+		require.False(t, ok, name)
+		require.Same(t, actual, actual.Delete(-1), name)
+	}
+}
+
+func TestHamtPersistence(t *testing.T) {
+	versions := []*Hamt[string, int]{NewWithStringKeys[int]()}
+	for idx := 0; idx < 1000; idx++ {
+		previous := versions[len(versions)-1]
+		versions = append(versions, previous.Put(fmt.Sprint(idx), idx))
+	}
+	deleted := versions[len(versions)-1].Delete("500")
+
+	// every version keeps its keys only
+	for version, hamt := range versions {
+		require.Equal(t, version, hamt.Size())
+		for idx := 0; idx < 1000; idx++ {
+			_, ok := hamt.Get(fmt.Sprint(idx))
+			require.Equal(t, idx < version, ok)
+		}
+	}
+	_, ok := deleted.Get("500")
+	require.False(t, ok)
+	_, ok = versions[len(versions)-1].Get("500")
+	require.True(t, ok)
+}
+
+func TestHamtCanonicalDelete(t *testing.T) {
+	for name, hash := range hashes {
+		fresh := New[int, int](hash)
+		full := New[int, int](hash)
+		for key := 0; key < 300; key++ {
+			if key%3 == 0 {
+				fresh = fresh.Put(key, key)
+			}
+			full = full.Put(key, key)
+		}
+		for key := 0; key < 300; key++ {
+			if key%3 != 0 {
+				full = full.Delete(key)
+			}
+		}
+		// trie after delete has the same shape as trie built from remaining keys
+		require.Equal(t, fresh.root, full.root, name)
+
+		for key := 0; key < 300; key += 3 {
+			full = full.Delete(key)
+		}
+		require.Empty(t, full.root.slots, name)
+		require.Equal(t, 0, full.Size(), name)
+	}
+}
+
+func BenchmarkHamtVsLinearProbing(b *testing.B) {
+	for _, cardinality := range []int{8, 1000, 100000} {
+		records := base.SyntheticData(200000, cardinality)[1:]
+		keys := make([]string, len(records))
+		for idx, record := range records {
+			keys[idx] = record[base.OsColumn]
+		}
+
+		b.Run(fmt.Sprintf("HAMT %d keys", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				hamt := NewWithStringKeys[int]()
+				for _, key := range keys {
+					hamt = hamt.Update(key, func(value int, ok bool) int {
+						return value + 1
+					})
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("linear probing v1 %d keys", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				hashMap := new(v1.HashTableWithLinearProbing).New()
+				for _, key := range keys {
+					value := 1
+					if cell := hashMap.Get(key); cell != nil {
+						value += cell.Value
+					}
+					hashMap.Put(key, value)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("linear probing v2 %d keys", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				hashMap := new(v2.HashTableWithLinearProbing).New()
+				for _, key := range keys {
+					hashMap.Add(key, 1)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkHamtGet(b *testing.B) {
+	for _, cardinality := range []int{8, 1000, 100000} {
+		hamt := NewWithStringKeys[int]()
+		hashMap := new(v1.HashTableWithLinearProbing).New()
+		keys := make([]string, cardinality)
+		for idx := range keys {
+			keys[idx] = "os-" + fmt.Sprint(idx)
+			hamt = hamt.Put(keys[idx], idx)
+			hashMap.Put(keys[idx], idx)
+		}
+
+		b.Run(fmt.Sprintf("HAMT %d keys", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				hamt.Get(keys[n%cardinality])
+			}
+		})
+		b.Run(fmt.Sprintf("linear probing v1 %d keys", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				hashMap.Get(keys[n%cardinality])
+			}
+		})
+	}
+}
+
+func TestHamtMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("trie", 0)
+	trie := NewWithTracker[string, int](HashString, tracker)
+	for i := 0; i < 100; i++ {
+		trie = trie.Update(fmt.Sprint(i%50), func(value int, ok bool) int {
+			return value + 1
+		})
+	}
+	require.Equal(t, 50*trie.EntryBytes(), tracker.Used())
+	// previous version still holds deleted entry
+	previous := trie
+	trie = trie.Delete("0").Delete("missing")
+	require.Equal(t, 50*trie.EntryBytes(), tracker.Used())
+	_, ok := previous.Get("0")
+	require.True(t, ok)
+	// re-added key is new entry of new version
+	trie = trie.Put("0", 1)
+	require.Equal(t, 51*trie.EntryBytes(), tracker.Used())
+	require.NoError(t, trie.Err())
+	trie.Release()
+	require.Zero(t, tracker.Used())
+
+	limited := NewWithTracker[string, int](HashString, new(memory.Tracker).New("trie", 10*trie.EntryBytes()))
+	for i := 0; i < 20; i++ {
+		limited = limited.Put(fmt.Sprint(i), i)
+	}
+	require.ErrorIs(t, limited.Err(), memory.ErrMemoryLimitExceeded)
+	require.Equal(t, 20, limited.Size())
 }
@@ -0,0 +1,231 @@
+package arena_keys
+
+import (
+	"group/base/arena"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
+	"group/base/small_string"
+	"unsafe"
+)
+
+/*
+HashTableWithArenaKeys implementation - linear probing table with string keys copied to arena.
+
+Cell keeps reference of key in arena (chunk, offset, length) and cached hash of key instead of Go string:
+- cells have no pointers, so garbage collector never scans table, however big it is;
+- table does not keep alive rows keys were taken from, only bytes of keys in arena;
+- cached hash is compared before bytes of key, so most of probes of other keys do not touch arena,
+  resize does not rehash keys.
+Whole table with keys is released at once by Free when aggregation ends.
+
+With InlineKeys mode short keys (see small_string.Key) are kept inline in the cell as two words, they are hashed
+and compared by words and never touch arena, only longer keys are copied to arena.
+*/
+
+type KeyMode int
+
+const (
+	// ArenaKeys copies every key to arena
+	ArenaKeys KeyMode = iota
+	// InlineKeys keeps short keys inline in the cell, longer keys are copied to arena
+	InlineKeys
+)
+
+// CellBytes is memory taken by one cell
+const CellBytes = int64(unsafe.Sizeof(Cell{}))
+
+const (
+	defaultCapacity = 16
+	// usedBit is set in cached hash of used cell, so empty cell is the one with zero hash
+	usedBit uint64 = 1 << 63
+)
+
+type Cell struct {
+	key   small_string.Key
+	hash  uint64
+	Value int
+}
+
+type HashTableWithArenaKeys struct {
+	cells []Cell
+	mask  uint64
+	size  int
+	keys  *arena.Arena
+	mode  KeyMode
+
+	// tracker accounts memory of cells (arena of keys reports to the same tracker), err keeps first error of it
+	tracker *memory.Tracker
+	err     error
+}
+
+func (hashMap *HashTableWithArenaKeys) New() *HashTableWithArenaKeys {
+	return hashMap.NewWithCapacity(defaultCapacity)
+}
+
+// NewWithCapacity makes table with capacity rounded up to power of two
+func (hashMap *HashTableWithArenaKeys) NewWithCapacity(capacity int) *HashTableWithArenaKeys {
+	return hashMap.NewWithKeyMode(capacity, ArenaKeys)
+}
+
+// NewWithKeyMode makes table with capacity rounded up to power of two and given mode of key storage
+func (hashMap *HashTableWithArenaKeys) NewWithKeyMode(capacity int, mode KeyMode) *HashTableWithArenaKeys {
+	return hashMap.NewWithTracker(capacity, mode, nil)
+}
+
+// NewWithTracker makes table reporting memory of its cells and arena of keys to tracker
+func (hashMap *HashTableWithArenaKeys) NewWithTracker(capacity int, mode KeyMode,
+	tracker *memory.Tracker) *HashTableWithArenaKeys {
+	length := defaultCapacity
+	for length < capacity {
+		length <<= 1
+	}
+	result := &HashTableWithArenaKeys{
+		cells:   make([]Cell, length),
+		mask:    uint64(length - 1),
+		keys:    new(arena.Arena).NewWithTracker(arena.DefaultChunkSize, tracker),
+		mode:    mode,
+		tracker: tracker,
+	}
+	result.track(int64(length))
+	return result
+}
+
+func (hashMap *HashTableWithArenaKeys) track(cells int64) {
+	if err := hashMap.tracker.Alloc(cells * CellBytes); err != nil && hashMap.err == nil {
+		hashMap.err = err
+	}
+}
+
+// Err returns first error of memory accounting of cells or arena as memory.ErrMemoryLimitExceeded
+func (hashMap *HashTableWithArenaKeys) Err() error {
+	if hashMap.err != nil {
+		return hashMap.err
+	}
+	return hashMap.keys.Err()
+}
+
+func (hashMap *HashTableWithArenaKeys) Size() int {
+	return hashMap.size
+}
+
+// Arena returns arena of keys of table
+func (hashMap *HashTableWithArenaKeys) Arena() *arena.Arena {
+	return hashMap.keys
+}
+
+// probe is key prepared for lookup: inline key or string key with hash
+type probe struct {
+	key    string
+	inline small_string.Key
+	// isInline is true if key is kept inline in the cell
+	isInline bool
+	hash     uint64
+}
+
+func (hashMap *HashTableWithArenaKeys) makeProbe(key string) probe {
+	if hashMap.mode == InlineKeys {
+		if inline, ok := small_string.Inline(key); ok {
+			return probe{key: key, inline: inline, isInline: true, hash: inline.Hash() | usedBit}
+		}
+	}
+	return probe{key: key, hash: v1.HashStringKey(key) | usedBit}
+}
+
+// find returns cell of key or empty cell where key has to be inserted
+func (hashMap *HashTableWithArenaKeys) find(probe *probe) *Cell {
+	idx := probe.hash & hashMap.mask
+	for {
+		cell := &hashMap.cells[idx]
+		if cell.hash == 0 {
+			return cell
+		}
+		if cell.hash == probe.hash {
+			if probe.isInline {
+				if cell.key == probe.inline {
+					return cell
+				}
+			} else if !cell.key.IsInline() && hashMap.keys.Equal(cell.key.Ref(), probe.key) {
+				return cell
+			}
+		}
+		idx = (idx + 1) & hashMap.mask
+	}
+}
+
+func (hashMap *HashTableWithArenaKeys) resize() {
+	oldCells := hashMap.cells
+	hashMap.cells = make([]Cell, 2*len(oldCells))
+	// old and new cells are both alive during resize
+	hashMap.track(int64(len(hashMap.cells)))
+	defer hashMap.tracker.Free(int64(len(oldCells)) * CellBytes)
+	hashMap.mask = uint64(len(hashMap.cells) - 1)
+	for _, cell := range oldCells {
+		if cell.hash == 0 {
+			continue
+		}
+		// hash is cached, so keys are not read on resize
+		idx := cell.hash & hashMap.mask
+		for hashMap.cells[idx].hash != 0 {
+			idx = (idx + 1) & hashMap.mask
+		}
+		hashMap.cells[idx] = cell
+	}
+}
+
+// cell returns cell of key inserting key if needed
+func (hashMap *HashTableWithArenaKeys) cell(key string) *Cell {
+	probe := hashMap.makeProbe(key)
+	cell := hashMap.find(&probe)
+	if cell.hash != 0 {
+		return cell
+	}
+	if 2*(hashMap.size+1) > len(hashMap.cells) {
+		hashMap.resize()
+		cell = hashMap.find(&probe)
+	}
+	storedKey := probe.inline
+	if !probe.isInline {
+		storedKey = small_string.FromRef(hashMap.keys.Append(key))
+	}
+	*cell = Cell{key: storedKey, hash: probe.hash}
+	hashMap.size++
+	return cell
+}
+
+func (hashMap *HashTableWithArenaKeys) Put(key string, value int) {
+	hashMap.cell(key).Value = value
+}
+
+// Add adds value to aggregate of key, key is inserted if it does not exist
+func (hashMap *HashTableWithArenaKeys) Add(key string, value int) {
+	hashMap.cell(key).Value += value
+}
+
+func (hashMap *HashTableWithArenaKeys) Get(key string) (int, bool) {
+	probe := hashMap.makeProbe(key)
+	cell := hashMap.find(&probe)
+	return cell.Value, cell.hash != 0
+}
+
+// Range calls fn for every key, key is copied out of cell or arena
+func (hashMap *HashTableWithArenaKeys) Range(fn func(key string, value int)) {
+	for _, cell := range hashMap.cells {
+		if cell.hash == 0 {
+			continue
+		}
+		if cell.key.IsInline() {
+			fn(cell.key.String(), cell.Value)
+		} else {
+			fn(hashMap.keys.String(cell.key.Ref()), cell.Value)
+		}
+	}
+}
+
+// Free releases cells and arena of keys at once, table is empty after it
+func (hashMap *HashTableWithArenaKeys) Free() {
+	hashMap.keys.Free()
+	hashMap.tracker.Free(int64(len(hashMap.cells)-defaultCapacity) * CellBytes)
+	hashMap.cells = make([]Cell, defaultCapacity)
+	hashMap.mask = defaultCapacity - 1
+	hashMap.size = 0
+}
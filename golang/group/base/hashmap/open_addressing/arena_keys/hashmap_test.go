@@ -0,0 +1,166 @@
+package arena_keys
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/batch"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashTable(t *testing.T) {
+	hashMap := new(HashTableWithArenaKeys).New()
+	hashMap.Put("Android", 10)
+	hashMap.Add("Android", 5)
+	hashMap.Add("iOS", 1)
+
+	value, ok := hashMap.Get("Android")
+	require.True(t, ok)
+	require.Equal(t, 15, value)
+	value, ok = hashMap.Get("iOS")
+	require.True(t, ok)
+	require.Equal(t, 1, value)
+	_, ok = hashMap.Get("Windows")
+	require.False(t, ok)
+	require.Equal(t, 2, hashMap.Size())
+	require.Equal(t, len("Android")+len("iOS"), hashMap.Arena().Size())
+
+	hashMap.Free()
+	require.Equal(t, 0, hashMap.Size())
+	_, ok = hashMap.Get("Android")
+	require.False(t, ok)
+}
+
+func TestHashTableResize(t *testing.T) {
+	for _, mode := range []KeyMode{ArenaKeys, InlineKeys} {
+		hashMap := new(HashTableWithArenaKeys).NewWithKeyMode(0, mode)
+		expected := make(map[string]int)
+		for i := 0; i < 100000; i++ {
+			// short keys are inline, long keys go to arena
+			key := "key-" + strconv.Itoa(i%30000)
+			if i%3 == 0 {
+				key = "long-key-of-group-" + strconv.Itoa(i%30000)
+			}
+			hashMap.Add(key, i)
+			expected[key] += i
+		}
+		require.Equal(t, len(expected), hashMap.Size())
+
+		actual := make(map[string]int)
+		hashMap.Range(func(key string, value int) {
+			actual[key] = value
+		})
+		require.Equal(t, expected, actual)
+	}
+}
+
+func TestInlineKeys(t *testing.T) {
+	hashMap := new(HashTableWithArenaKeys).NewWithKeyMode(0, InlineKeys)
+	hashMap.Add("Android", 10)
+	hashMap.Add("Android", 5)
+	hashMap.Add("", 1)
+	hashMap.Add("Windows Phone 8.1", 2)
+
+	value, ok := hashMap.Get("Android")
+	require.True(t, ok)
+	require.Equal(t, 15, value)
+	value, ok = hashMap.Get("")
+	require.True(t, ok)
+	require.Equal(t, 1, value)
+	value, ok = hashMap.Get("Windows Phone 8.1")
+	require.True(t, ok)
+	require.Equal(t, 2, value)
+	_, ok = hashMap.Get("Androi")
+	require.False(t, ok)
+	require.Equal(t, 3, hashMap.Size())
+	// only long key is kept in arena
+	require.Equal(t, len("Windows Phone 8.1"), hashMap.Arena().Size())
+}
+
+// keys are copied, so table does not depend on memory of source rows
+func TestKeysAreCopied(t *testing.T) {
+	source := []byte("Android")
+	hashMap := new(HashTableWithArenaKeys).New()
+	hashMap.Add(string(source), 1)
+	source[0] = 'X'
+	_, ok := hashMap.Get("Android")
+	require.True(t, ok)
+}
+
+// aggregate sums popularity by os over synthetic batches in layout of phones data, batches are generated
+// and decoded one by one, so keys of v1 table (substrings of batches) are the only thing keeping batches alive
+func aggregate(rows int, cardinality int, add func(key string, value int)) {
+	random := rand.New(rand.NewSource(int64(rows*31 + cardinality)))
+	records := make([][]string, 0, batch.DefaultBatchSize)
+	for start := 0; start < rows; start += batch.DefaultBatchSize {
+		records = records[:0]
+		for i := start; i < start+batch.DefaultBatchSize && i < rows; i++ {
+			key := random.Intn(cardinality)
+			records = append(records, []string{strconv.Itoa(i), "brand-" + strconv.Itoa(key%64),
+				"model-" + strconv.Itoa(i), "os-" + strconv.Itoa(key), strconv.Itoa(random.Intn(1000)),
+				"0.0", "0.0", "0.0", "1", "0.0", "0.0", "0.0", "1-2020", "0"})
+		}
+		dataBatch, _ := batch.FromRecords(records, base.PhonesSchema)
+		osColumn, _ := dataBatch.String(base.OsColumn)
+		popularityColumn, _ := dataBatch.Int64(base.PopularityColumn)
+		for idx := 0; idx < dataBatch.Rows; idx++ {
+			add(osColumn.Value(idx), int(popularityColumn.Values[idx]))
+		}
+	}
+}
+
+// GC impact of large table: heap retained by table after aggregation, number of heap objects
+// and duration of full GC cycle while table is alive
+func BenchmarkGCImpact(b *testing.B) {
+	const rows, cardinality = 500000, 250000
+	measure := func(b *testing.B, build func() any) {
+		var retained, objects, gcTime, pause float64
+		for n := 0; n < b.N; n++ {
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			table := build()
+			runtime.GC()
+			start := time.Now()
+			runtime.GC()
+			gcTime += float64(time.Since(start).Nanoseconds())
+			runtime.ReadMemStats(&after)
+			runtime.KeepAlive(table)
+
+			retained += (float64(after.HeapAlloc) - float64(before.HeapAlloc)) / (1 << 20)
+			objects += float64(after.HeapObjects) - float64(before.HeapObjects)
+			pause += float64(after.PauseTotalNs - before.PauseTotalNs)
+		}
+		b.ReportMetric(retained/float64(b.N), "retained-MB")
+		b.ReportMetric(objects/float64(b.N), "heap-objects")
+		b.ReportMetric(gcTime/float64(b.N), "gc-ns")
+		b.ReportMetric(pause/float64(b.N), "gc-pause-ns")
+	}
+
+	b.Run(fmt.Sprintf("rows-%d/string-keys", rows), func(b *testing.B) {
+		measure(b, func() any {
+			hashMap := new(v1.HashTableWithLinearProbing).New()
+			aggregate(rows, cardinality, func(key string, value int) {
+				if cell := hashMap.Get(key); cell != nil {
+					value += cell.Value
+				}
+				hashMap.Put(key, value)
+			})
+			return hashMap
+		})
+	})
+	b.Run(fmt.Sprintf("rows-%d/arena-keys", rows), func(b *testing.B) {
+		measure(b, func() any {
+			hashMap := new(HashTableWithArenaKeys).New()
+			aggregate(rows, cardinality, hashMap.Add)
+			return hashMap
+		})
+	})
+}
@@ -0,0 +1,279 @@
+package conformance
+
+import (
+	"fmt"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+Conformance suite of open addressing tables - every layout (linear probing, quadratic probing, cuckoo hashing, ...)
+is checked by the same tests and measured by the same benchmarks, so layouts are compared on equal terms.
+Table is wrapped by adapter to Table interface, see conformance_test.go.
+*/
+
+// Table is string -> int table under test, empty key is never stored
+type Table interface {
+	Put(key string, value int)
+	Add(key string, value int)
+	Get(key string) (int, bool)
+	Remove(key string)
+	Size() int
+	Capacity() int
+	Range(fn func(key string, value int))
+}
+
+// Implementation makes empty tables of one layout
+type Implementation struct {
+	Name string
+	New  func() Table
+	// NewWithCapacity makes table of capacity which holds loadFactor of capacity without resize
+	NewWithCapacity func(capacity int, loadFactor float64) Table
+	// FixedCapacity table never grows, New makes it big enough for tests
+	FixedCapacity bool
+}
+
+func requireSameAs(t *testing.T, expected map[string]int, table Table) {
+	require.Equal(t, len(expected), table.Size())
+	for key, value := range expected {
+		actual, ok := table.Get(key)
+		require.True(t, ok, "key %q", key)
+		require.Equal(t, value, actual, "key %q", key)
+	}
+	ranged := make(map[string]int)
+	table.Range(func(key string, value int) {
+		_, ok := ranged[key]
+		require.False(t, ok, "key %q is met twice", key)
+		ranged[key] = value
+	})
+	require.Equal(t, expected, ranged)
+}
+
+// Run runs conformance tests against tables of implementation
+func Run(t *testing.T, implementation Implementation) {
+	t.Run("insert and update", func(t *testing.T) {
+		table := implementation.New()
+		expected := make(map[string]int)
+		for idx := 0; idx < 1000; idx++ {
+			key := "key-" + strconv.Itoa(idx)
+			table.Put(key, idx)
+			expected[key] = idx
+		}
+		requireSameAs(t, expected, table)
+
+		for idx := 0; idx < 1000; idx++ {
+			key := "key-" + strconv.Itoa(idx)
+			if idx%2 == 0 {
+				table.Put(key, -idx)
+				expected[key] = -idx
+			} else {
+				table.Add(key, idx)
+				expected[key] += idx
+			}
+		}
+		table.Add("new", 5)
+		expected["new"] = 5
+		requireSameAs(t, expected, table)
+
+		_, ok := table.Get("missing")
+		require.False(t, ok)
+		// empty key is ignored
+		table.Put("", 1)
+		table.Add("", 1)
+		_, ok = table.Get("")
+		require.False(t, ok)
+		require.Equal(t, len(expected), table.Size())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		table := implementation.New()
+		expected := make(map[string]int)
+		for idx := 0; idx < 3000; idx++ {
+			key := strconv.Itoa(idx)
+			table.Put(key, idx)
+			expected[key] = idx
+		}
+		for idx := 0; idx < 3000; idx += 3 {
+			key := strconv.Itoa(idx)
+			table.Remove(key)
+			delete(expected, key)
+			_, ok := table.Get(key)
+			require.False(t, ok, key)
+		}
+		table.Remove("missing")
+		table.Remove("")
+		requireSameAs(t, expected, table)
+
+		// removed keys come back with new values
+		for idx := 0; idx < 3000; idx += 3 {
+			key := strconv.Itoa(idx)
+			table.Add(key, 1)
+			expected[key] = 1
+		}
+		requireSameAs(t, expected, table)
+
+		for key := range expected {
+			table.Remove(key)
+		}
+		requireSameAs(t, map[string]int{}, table)
+	})
+
+	t.Run("resize", func(t *testing.T) {
+		table := implementation.New()
+		capacity := table.Capacity()
+		expected := make(map[string]int)
+		for idx := 0; idx < 100000; idx++ {
+			key := "os-" + strconv.Itoa(idx)
+			table.Add(key, idx)
+			expected[key] = idx
+			if idx%10000 == 0 {
+				require.GreaterOrEqual(t, table.Capacity(), table.Size())
+			}
+		}
+		if implementation.FixedCapacity {
+			require.Equal(t, capacity, table.Capacity())
+		} else {
+			require.Greater(t, table.Capacity(), capacity)
+		}
+		requireSameAs(t, expected, table)
+	})
+
+	t.Run("iteration against map", func(t *testing.T) {
+		random := rand.New(rand.NewSource(1))
+		table := implementation.New()
+		expected := make(map[string]int)
+		for op := 0; op < 50000; op++ {
+			key := "k" + strconv.Itoa(random.Intn(2000))
+			switch random.Intn(4) {
+			case 0:
+				table.Put(key, op)
+				expected[key] = op
+			case 1:
+				table.Remove(key)
+				delete(expected, key)
+			default:
+				table.Add(key, op)
+				expected[key] += op
+			}
+			if op%5000 == 0 {
+				requireSameAs(t, expected, table)
+			}
+		}
+		requireSameAs(t, expected, table)
+	})
+
+	t.Run("adversarial keys", func(t *testing.T) {
+		keys := AdversarialKeys()
+		table := implementation.New()
+		expected := make(map[string]int)
+		for round := 0; round < 3; round++ {
+			for idx, key := range keys {
+				table.Add(key, idx)
+				expected[key] += idx
+			}
+			requireSameAs(t, expected, table)
+			for idx := round; idx < len(keys); idx += 2 {
+				table.Remove(keys[idx])
+				delete(expected, keys[idx])
+			}
+			requireSameAs(t, expected, table)
+		}
+	})
+}
+
+// AdversarialKeys returns keys hard for open addressing:
+//   - keys different only in trailing zero bytes ("a", "a\x00", ...) and all-zero keys of any length, hash has to
+//     tell them apart by length;
+//   - group of 40 keys with equal lower 16 bits of hash - more than cells of two cuckoo buckets share the first
+//     bucket in tables up to 65536 cells, so they have to be moved to their second buckets;
+//   - keys with equal lower 12 bits of hash, so they share home cell in tables up to 4096 cells;
+//   - long keys different only in the last byte.
+func AdversarialKeys() []string {
+	keys := make([]string, 0)
+	for _, prefix := range []string{"a", "bc", "Android", "0"} {
+		for padding := 0; len(prefix)+padding <= 8; padding++ {
+			keys = append(keys, prefix+strings.Repeat("\x00", padding))
+		}
+	}
+	for length := 1; length <= 16; length++ {
+		keys = append(keys, strings.Repeat("\x00", length))
+	}
+	for idx, group := 0, 0; group < 40; idx++ {
+		key := "b" + strconv.Itoa(idx)
+		if v1.HashStringKey(key)&(1<<16-1) == 0 {
+			keys = append(keys, key)
+			group++
+		}
+	}
+	for idx := 0; len(keys) < 640; idx++ {
+		key := "c" + strconv.Itoa(idx)
+		if v1.HashStringKey(key)&(1<<12-1) == 0 {
+			keys = append(keys, key)
+		}
+	}
+	long := strings.Repeat("long key ", 8)
+	for b := 0; b < 256; b++ {
+		keys = append(keys, long+string([]byte{byte(b)}))
+	}
+	return keys
+}
+
+// LoadFactors are load factors of Benchmark
+var LoadFactors = []float64{0.5, 0.75, 0.9, 0.95}
+
+// Benchmark measures insert of keys, lookups of present keys and lookups of missing keys in table of fixed
+// capacity filled up to every load factor, results are in ns per key
+func Benchmark(b *testing.B, implementation Implementation) {
+	const capacity = 1 << 16
+	missing := make([]string, capacity)
+	for idx := range missing {
+		missing[idx] = "missing-" + strconv.Itoa(idx)
+	}
+
+	for _, loadFactor := range LoadFactors {
+		keys := make([]string, int(capacity*loadFactor))
+		for idx := range keys {
+			keys[idx] = "os-" + strconv.Itoa(idx)
+		}
+		rand.New(rand.NewSource(1)).Shuffle(len(keys), func(i, j int) {
+			keys[i], keys[j] = keys[j], keys[i]
+		})
+		perKey := func(b *testing.B) {
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(len(keys)), "ns/key")
+		}
+
+		table := implementation.NewWithCapacity(capacity, loadFactor)
+		b.Run(fmt.Sprintf("%s load %.2f insert", implementation.Name, loadFactor), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				table = implementation.NewWithCapacity(capacity, loadFactor)
+				for idx, key := range keys {
+					table.Add(key, idx)
+				}
+			}
+			perKey(b)
+			// table must not grow, otherwise load factor is not reached
+			b.ReportMetric(float64(table.Size())/float64(table.Capacity()), "load")
+		})
+		b.Run(fmt.Sprintf("%s load %.2f hit", implementation.Name, loadFactor), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				for _, key := range keys {
+					table.Get(key)
+				}
+			}
+			perKey(b)
+		})
+		b.Run(fmt.Sprintf("%s load %.2f miss", implementation.Name, loadFactor), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				for _, key := range missing[:len(keys)] {
+					table.Get(key)
+				}
+			}
+			perKey(b)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package conformance
+
+import (
+	"group/base/hashmap/open_addressing/cuckoo"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	v2 "group/base/hashmap/open_addressing/linear_probing/v2"
+	"group/base/hashmap/open_addressing/quadratic_probing"
+	"testing"
+)
+
+// adapters of tables to Table interface
+
+type linearProbingV1 struct {
+	*v1.HashTableWithLinearProbing
+}
+
+func (table linearProbingV1) Add(key string, value int) {
+	if cell := table.HashTableWithLinearProbing.Get(key); cell != nil {
+		cell.Value += value
+		return
+	}
+	table.Put(key, value)
+}
+
+func (table linearProbingV1) Get(key string) (int, bool) {
+	if cell := table.HashTableWithLinearProbing.Get(key); cell != nil {
+		return cell.Value, true
+	}
+	return 0, false
+}
+
+func (table linearProbingV1) Capacity() int {
+	return len(table.Cells)
+}
+
+// linearProbingV2 is table of fixed capacity - it never grows and never reuses cells of removed keys,
+// so it is made big enough for all tests
+type linearProbingV2 struct {
+	*v2.HashTableWithLinearProbing
+}
+
+func (table linearProbingV2) apply(code int) {
+	if code != v2.BreakerClosed {
+		panic("value is not applied")
+	}
+}
+
+func (table linearProbingV2) Put(key string, value int) {
+	table.apply(table.HashTableWithLinearProbing.Put(key, value))
+}
+
+func (table linearProbingV2) Add(key string, value int) {
+	table.apply(table.HashTableWithLinearProbing.Add(key, value))
+}
+
+func (table linearProbingV2) Get(key string) (int, bool) {
+	if cell := table.HashTableWithLinearProbing.Get(key); cell != nil {
+		return cell.Value(), true
+	}
+	return 0, false
+}
+
+type quadraticProbing struct {
+	*quadratic_probing.HashTableWithQuadraticProbing
+}
+
+func (table quadraticProbing) Get(key string) (int, bool) {
+	if cell := table.HashTableWithQuadraticProbing.Get(key); cell != nil {
+		return cell.Value, true
+	}
+	return 0, false
+}
+
+type cuckooHashing struct {
+	*cuckoo.HashTableWithCuckooHashing
+}
+
+func (table cuckooHashing) Get(key string) (int, bool) {
+	if cell := table.HashTableWithCuckooHashing.Get(key); cell != nil {
+		return cell.Value, true
+	}
+	return 0, false
+}
+
+var implementations = []Implementation{
+	{
+		Name: "linear probing v1",
+		New: func() Table {
+			return linearProbingV1{new(v1.HashTableWithLinearProbing).New()}
+		},
+		NewWithCapacity: func(capacity int, loadFactor float64) Table {
+			// table grows only when it is full
+			return linearProbingV1{new(v1.HashTableWithLinearProbing).NewWithCapacity(capacity)}
+		},
+	},
+	{
+		Name: "linear probing v2",
+		New: func() Table {
+			return linearProbingV2{new(v2.HashTableWithLinearProbing).NewWithCapacity(1<<18, 0)}
+		},
+		NewWithCapacity: func(capacity int, loadFactor float64) Table {
+			return linearProbingV2{new(v2.HashTableWithLinearProbing).NewWithCapacity(capacity, 0)}
+		},
+		FixedCapacity: true,
+	},
+	{
+		Name: "quadratic probing",
+		New: func() Table {
+			return quadraticProbing{new(quadratic_probing.HashTableWithQuadraticProbing).New()}
+		},
+		NewWithCapacity: func(capacity int, loadFactor float64) Table {
+			return quadraticProbing{new(quadratic_probing.HashTableWithQuadraticProbing).NewWithLoadFactor(capacity, 0.99)}
+		},
+	},
+	{
+		Name: "cuckoo",
+		New: func() Table {
+			return cuckooHashing{new(cuckoo.HashTableWithCuckooHashing).New()}
+		},
+		NewWithCapacity: func(capacity int, loadFactor float64) Table {
+			return cuckooHashing{new(cuckoo.HashTableWithCuckooHashing).NewWithLoadFactor(capacity, 1)}
+		},
+	},
+}
+
+func TestConformance(t *testing.T) {
+	for _, implementation := range implementations {
+		t.Run(implementation.Name, func(t *testing.T) {
+			Run(t, implementation)
+		})
+	}
+}
+
+func BenchmarkHighLoad(b *testing.B) {
+	for _, implementation := range implementations {
+		Benchmark(b, implementation)
+	}
+}
@@ -0,0 +1,299 @@
+package cuckoo
+
+import (
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
+	"unsafe"
+)
+
+/*
+HashTableWithCuckooHashing implementation - bucketized cuckoo hashing with 2 hash functions
+
+Cells are grouped to buckets of 4 cells, every key has two candidate buckets (from two independent hashes), so lookup
+reads at most 8 cells whatever load factor is - no probe sequences, no tombstones, removed cell is just emptied.
+Insert puts key to free cell of either bucket, if both are full it evicts random key of bucket, evicted key moves to
+its other bucket and so on (random walk, up to maxKicks evictions). With buckets of 4 cells inserts stay cheap up to
+~95% load factor, while cuckoo hashing with single cell buckets fails at 50%.
+
+Key which has no cell after maxKicks evictions goes to stash scanned by every lookup of missing key. When stash
+exceeds maxStash keys (or keys exceed load factor) table grows twice and reinserts all keys.
+
+Memory tracker (if set) accounts cells of buckets, old and new cells are both accounted during growth, stash is
+bounded by maxStash and is not accounted.
+*/
+const (
+	defaultCapacity   = 8
+	defaultLoadFactor = 0.9
+	bucketCells       = 4
+	maxKicks          = 128
+	maxStash          = 8
+	// usedBit is set in cached hash of used cell, so empty cell is the one with zero hash
+	usedBit uint64 = 1 << 63
+)
+
+// Basic murmur finalizer - https://gist.github.com/dnbaker/0fc1d4edbbdb24069eb063dc2559e4f5
+func murmurFinalizerHash64(hash uint64) uint64 {
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
+
+type Cell struct {
+	Key   string
+	Value int
+	hash  uint64
+}
+
+// CellBytes is memory taken by one cell
+const CellBytes = int64(unsafe.Sizeof(Cell{}))
+
+type HashTableWithCuckooHashing struct {
+	cells []Cell
+	// mask is mask of bucket index
+	mask       uint64
+	stash      []Cell
+	size       int
+	limit      int
+	loadFactor float64
+	// random is state of xorshift generator choosing evicted cell
+	random  uint64
+	account *memory.Account
+}
+
+func (hashMap *HashTableWithCuckooHashing) New() *HashTableWithCuckooHashing {
+	return hashMap.NewWithLoadFactor(defaultCapacity, defaultLoadFactor)
+}
+
+// NewWithLoadFactor makes table with capacity rounded up to power of two, table grows when keys exceed loadFactor
+// (in (0, 1]) of capacity
+func (hashMap *HashTableWithCuckooHashing) NewWithLoadFactor(capacity int,
+	loadFactor float64) *HashTableWithCuckooHashing {
+	if loadFactor <= 0 || loadFactor > 1 {
+		loadFactor = defaultLoadFactor
+	}
+	length := defaultCapacity
+	for length < capacity {
+		length <<= 1
+	}
+	return &HashTableWithCuckooHashing{
+		cells:      make([]Cell, length),
+		mask:       uint64(length/bucketCells - 1),
+		limit:      int(float64(length) * loadFactor),
+		loadFactor: loadFactor,
+		random:     0x9e3779b97f4a7c15,
+	}
+}
+
+// NewWithTracker makes table of NewWithLoadFactor reporting memory of its cells to tracker
+func (hashMap *HashTableWithCuckooHashing) NewWithTracker(capacity int, loadFactor float64,
+	tracker *memory.Tracker) *HashTableWithCuckooHashing {
+	result := hashMap.NewWithLoadFactor(capacity, loadFactor)
+	result.account = new(memory.Account).New(tracker)
+	result.account.Alloc(int64(len(result.cells)) * CellBytes)
+	return result
+}
+
+var _ memory.Accounted = (*HashTableWithCuckooHashing)(nil)
+
+func (hashMap *HashTableWithCuckooHashing) Err() error {
+	return hashMap.account.Err()
+}
+
+func (hashMap *HashTableWithCuckooHashing) Release() {
+	hashMap.account.Release()
+}
+
+func (hashMap *HashTableWithCuckooHashing) Size() int {
+	return hashMap.size
+}
+
+func (hashMap *HashTableWithCuckooHashing) Capacity() int {
+	return len(hashMap.cells)
+}
+
+func (hashMap *HashTableWithCuckooHashing) ContainsKey(key string) bool {
+	return hashMap.Get(key) != nil
+}
+
+// buckets returns two candidate buckets of hash, they are always different
+func (hashMap *HashTableWithCuckooHashing) buckets(hash uint64) (uint64, uint64) {
+	first := hash & hashMap.mask
+	second := murmurFinalizerHash64(hash) & hashMap.mask
+	if second == first {
+		second = (first + 1) & hashMap.mask
+	}
+	return first, second
+}
+
+func (hashMap *HashTableWithCuckooHashing) otherBucket(hash uint64, bucket uint64) uint64 {
+	first, second := hashMap.buckets(hash)
+	if bucket == first {
+		return second
+	}
+	return first
+}
+
+func (hashMap *HashTableWithCuckooHashing) bucket(idx uint64) []Cell {
+	return hashMap.cells[idx*bucketCells : (idx+1)*bucketCells]
+}
+
+func (hashMap *HashTableWithCuckooHashing) find(key string, hash uint64) *Cell {
+	first, second := hashMap.buckets(hash)
+	for _, idx := range [2]uint64{first, second} {
+		bucket := hashMap.bucket(idx)
+		for cell := range bucket {
+			// cached hash is compared first, so other keys are rarely compared
+			if bucket[cell].hash == hash && bucket[cell].Key == key {
+				return &bucket[cell]
+			}
+		}
+	}
+	for cell := range hashMap.stash {
+		if hashMap.stash[cell].hash == hash && hashMap.stash[cell].Key == key {
+			return &hashMap.stash[cell]
+		}
+	}
+	return nil
+}
+
+func (hashMap *HashTableWithCuckooHashing) Get(key string) *Cell {
+	if key == "" {
+		return nil
+	}
+	return hashMap.find(key, v1.HashStringKey(key)|usedBit)
+}
+
+// Put sets value of key
+func (hashMap *HashTableWithCuckooHashing) Put(key string, value int) {
+	hashMap.update(key, value, false)
+}
+
+// Add adds value to aggregate of key
+func (hashMap *HashTableWithCuckooHashing) Add(key string, value int) {
+	hashMap.update(key, value, true)
+}
+
+func (hashMap *HashTableWithCuckooHashing) update(key string, value int, add bool) {
+	if key == "" {
+		return
+	}
+
+	hash := v1.HashStringKey(key) | usedBit
+	if cell := hashMap.find(key, hash); cell != nil {
+		if add {
+			value += cell.Value
+		}
+		cell.Value = value
+		return
+	}
+
+	if hashMap.size+1 > hashMap.limit {
+		hashMap.grow()
+	}
+	hashMap.insert(Cell{Key: key, Value: value, hash: hash})
+	hashMap.size++
+	if len(hashMap.stash) > maxStash {
+		hashMap.grow()
+	}
+}
+
+// insert places new key to one of its buckets, evicting other keys if needed, key left without cell goes to stash
+func (hashMap *HashTableWithCuckooHashing) insert(cell Cell) {
+	first, second := hashMap.buckets(cell.hash)
+	if hashMap.place(first, cell) || hashMap.place(second, cell) {
+		return
+	}
+
+	current := first
+	if hashMap.nextRandom()&1 == 1 {
+		current = second
+	}
+	for kick := 0; kick < maxKicks; kick++ {
+		// evict random cell of full bucket, evicted key goes to its other bucket
+		bucket := hashMap.bucket(current)
+		victim := hashMap.nextRandom() % bucketCells
+		cell, bucket[victim] = bucket[victim], cell
+		current = hashMap.otherBucket(cell.hash, current)
+		if hashMap.place(current, cell) {
+			return
+		}
+	}
+	hashMap.stash = append(hashMap.stash, cell)
+}
+
+// place puts cell to free cell of bucket, returns false if bucket is full
+func (hashMap *HashTableWithCuckooHashing) place(idx uint64, cell Cell) bool {
+	bucket := hashMap.bucket(idx)
+	for free := range bucket {
+		if bucket[free].hash == 0 {
+			bucket[free] = cell
+			return true
+		}
+	}
+	return false
+}
+
+func (hashMap *HashTableWithCuckooHashing) nextRandom() uint64 {
+	hashMap.random ^= hashMap.random << 13
+	hashMap.random ^= hashMap.random >> 7
+	hashMap.random ^= hashMap.random << 17
+	return hashMap.random
+}
+
+// grow reinserts all keys to table of twice capacity
+func (hashMap *HashTableWithCuckooHashing) grow() {
+	grown := hashMap.NewWithLoadFactor(2*len(hashMap.cells), hashMap.loadFactor)
+	grown.account = hashMap.account
+	grown.account.Alloc(int64(len(grown.cells)) * CellBytes)
+	defer grown.account.Free(int64(len(hashMap.cells)) * CellBytes)
+	for _, cells := range [2][]Cell{hashMap.cells, hashMap.stash} {
+		for _, cell := range cells {
+			if cell.hash != 0 {
+				grown.insert(cell)
+			}
+		}
+	}
+	grown.size = hashMap.size
+	*hashMap = *grown
+}
+
+func (hashMap *HashTableWithCuckooHashing) Remove(key string) {
+	if key == "" {
+		return
+	}
+	hash := v1.HashStringKey(key) | usedBit
+	cell := hashMap.find(key, hash)
+	if cell == nil {
+		return
+	}
+	hashMap.size--
+	for idx := range hashMap.stash {
+		if &hashMap.stash[idx] == cell {
+			hashMap.stash = append(hashMap.stash[:idx], hashMap.stash[idx+1:]...)
+			return
+		}
+	}
+	*cell = Cell{}
+	// freed cell may take key of stash back
+	for idx := 0; idx < len(hashMap.stash); idx++ {
+		first, second := hashMap.buckets(hashMap.stash[idx].hash)
+		if hashMap.place(first, hashMap.stash[idx]) || hashMap.place(second, hashMap.stash[idx]) {
+			hashMap.stash = append(hashMap.stash[:idx], hashMap.stash[idx+1:]...)
+			idx--
+		}
+	}
+}
+
+// Range calls fn for every key of the table
+func (hashMap *HashTableWithCuckooHashing) Range(fn func(key string, value int)) {
+	for _, cells := range [2][]Cell{hashMap.cells, hashMap.stash} {
+		for idx := range cells {
+			if cell := &cells[idx]; cell.hash != 0 {
+				fn(cell.Key, cell.Value)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package cuckoo
+
+import (
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// collidingKeys returns keys which have the same two candidate buckets in hashMap
+func collidingKeys(hashMap *HashTableWithCuckooHashing, count int) []string {
+	first, second := hashMap.buckets(v1.HashStringKey("0") | usedBit)
+	keys := make([]string, 0, count)
+	for idx := 0; len(keys) < count; idx++ {
+		key := strconv.Itoa(idx)
+		keyFirst, keySecond := hashMap.buckets(v1.HashStringKey(key) | usedBit)
+		if (keyFirst == first && keySecond == second) || (keyFirst == second && keySecond == first) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func TestCuckooEvictionCycle(t *testing.T) {
+	// 9 keys of two buckets - evictions go round between them until key goes to stash
+	hashMap := new(HashTableWithCuckooHashing).NewWithLoadFactor(64, 1)
+	keys := collidingKeys(hashMap, 9)
+	for idx, key := range keys {
+		hashMap.Put(key, idx)
+	}
+	require.Len(t, hashMap.stash, 1)
+	require.Equal(t, 64, hashMap.Capacity())
+
+	first, second := hashMap.buckets(hashMap.stash[0].hash)
+	used := 0
+	for idx, cell := range hashMap.cells {
+		if cell.hash != 0 {
+			used++
+			bucket := uint64(idx / bucketCells)
+			require.True(t, bucket == first || bucket == second)
+		}
+	}
+	require.Equal(t, 8, used)
+	for idx, key := range keys {
+		require.Equal(t, idx, hashMap.Get(key).Value)
+	}
+}
+
+func TestCuckooEvictionsAtHighLoad(t *testing.T) {
+	hashMap := new(HashTableWithCuckooHashing).NewWithLoadFactor(1<<12, 1)
+	keys := int(0.95 * float64(hashMap.Capacity()))
+	for idx := 0; idx < keys; idx++ {
+		hashMap.Put(strconv.Itoa(idx), idx)
+	}
+	// random walk of evictions places keys without growth
+	require.Equal(t, 1<<12, hashMap.Capacity())
+	require.LessOrEqual(t, len(hashMap.stash), maxStash)
+	for idx := 0; idx < keys; idx++ {
+		require.Equal(t, idx, hashMap.Get(strconv.Itoa(idx)).Value)
+	}
+}
+
+func TestCuckooStashRefill(t *testing.T) {
+	hashMap := new(HashTableWithCuckooHashing).NewWithLoadFactor(64, 1)
+	keys := collidingKeys(hashMap, 12)
+	for idx, key := range keys {
+		hashMap.Put(key, idx)
+	}
+	require.Len(t, hashMap.stash, 4)
+
+	// removed key of stash leaves buckets full
+	stashed := hashMap.stash[0].Key
+	hashMap.Remove(stashed)
+	require.Len(t, hashMap.stash, 3)
+	require.Nil(t, hashMap.Get(stashed))
+
+	// freed cell of bucket takes key of stash back
+	var removed string
+	for _, cell := range hashMap.cells {
+		if cell.hash != 0 {
+			removed = cell.Key
+			break
+		}
+	}
+	hashMap.Remove(removed)
+	require.Len(t, hashMap.stash, 2)
+	require.Nil(t, hashMap.Get(removed))
+	require.Equal(t, 10, hashMap.Size())
+	for idx, key := range keys {
+		if key != stashed && key != removed {
+			require.Equal(t, idx, hashMap.Get(key).Value)
+		}
+	}
+}
+
+func TestCuckooStashIsBounded(t *testing.T) {
+	// more keys of two buckets than cells of buckets and stash - table grows, so they get other buckets
+	hashMap := new(HashTableWithCuckooHashing).NewWithLoadFactor(64, 1)
+	keys := collidingKeys(hashMap, 2*bucketCells+maxStash+1)
+	for idx, key := range keys {
+		hashMap.Add(key, idx)
+	}
+	require.Greater(t, hashMap.Capacity(), 64)
+	require.LessOrEqual(t, len(hashMap.stash), maxStash)
+	require.Equal(t, len(keys), hashMap.Size())
+	for idx, key := range keys {
+		require.Equal(t, idx, hashMap.Get(key).Value)
+	}
+}
+
+func TestCuckooMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("table", 0)
+	hashMap := new(HashTableWithCuckooHashing).NewWithTracker(8, 0.9, tracker)
+	require.Equal(t, 8*CellBytes, tracker.Used())
+	for idx := 0; idx < 100; idx++ {
+		hashMap.Put(strconv.Itoa(idx), idx)
+	}
+	require.Equal(t, int64(hashMap.Capacity())*CellBytes, tracker.Used())
+	// old and new cells at the moment of last growth
+	require.Equal(t, int64(hashMap.Capacity())*CellBytes*3/2, tracker.Peak())
+	require.NoError(t, hashMap.Err())
+	hashMap.Release()
+	require.Zero(t, tracker.Used())
+
+	limited := new(HashTableWithCuckooHashing).NewWithTracker(8, 0.9, new(memory.Tracker).New("table", 64*CellBytes))
+	for idx := 0; idx < 100; idx++ {
+		limited.Put(strconv.Itoa(idx), idx)
+	}
+	require.ErrorIs(t, limited.Err(), memory.ErrMemoryLimitExceeded)
+}
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"group/base/memory"
 	"unsafe"
 )
 
@@ -21,9 +22,6 @@ const (
 	defaultCapacity int = 8
 )
 
-var length int
-var size int
-
 const (
 	Null  = 0
 	Value = 1
@@ -35,51 +33,102 @@ type Cell struct {
 	state int
 }
 
+// CellBytes is memory taken by one cell
+const CellBytes = int64(unsafe.Sizeof(Cell{}))
+
 type HashTableWithLinearProbing struct {
-	Cells []Cell
+	Cells  []Cell
+	length int
+	size   int
+
+	// tracker accounts memory of cells, err keeps first error of accounting
+	tracker *memory.Tracker
+	err     error
 }
 
 func (hashMap *HashTableWithLinearProbing) New() *HashTableWithLinearProbing {
 	return hashMap.hashMapWithCapacity(defaultCapacity)
 }
 
+// NewWithTracker makes table reporting memory of its cells to tracker
+func (hashMap *HashTableWithLinearProbing) NewWithTracker(tracker *memory.Tracker) *HashTableWithLinearProbing {
+	result := hashMap.hashMapWithCapacity(defaultCapacity)
+	result.tracker = tracker
+	result.track(int64(defaultCapacity))
+	return result
+}
+
+func (hashMap *HashTableWithLinearProbing) track(cells int64) {
+	if err := hashMap.tracker.Alloc(cells * CellBytes); err != nil && hashMap.err == nil {
+		hashMap.err = err
+	}
+}
+
+var _ memory.Accounted = (*HashTableWithLinearProbing)(nil)
+
+func (hashMap *HashTableWithLinearProbing) Err() error {
+	return hashMap.err
+}
+
+func (hashMap *HashTableWithLinearProbing) Release() {
+	hashMap.tracker.Free(int64(len(hashMap.Cells)) * CellBytes)
+	hashMap.tracker = nil
+}
+
+// NewWithCapacity makes table of initial capacity, table grows twice when it is full
+func (hashMap *HashTableWithLinearProbing) NewWithCapacity(capacity int) *HashTableWithLinearProbing {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	return hashMap.hashMapWithCapacity(capacity)
+}
+
 func (hashMap *HashTableWithLinearProbing) hashMapWithCapacity(capacity int) *HashTableWithLinearProbing {
-	size = 0
-	length = capacity
-	cells := make([]Cell, length)
-	return &HashTableWithLinearProbing{Cells: cells}
+	cells := make([]Cell, capacity)
+	return &HashTableWithLinearProbing{Cells: cells, length: capacity}
 }
 
-func getCell(hash uint64) uint64 {
-	return hash % uint64(length)
+func (hashMap *HashTableWithLinearProbing) getCell(hash uint64) uint64 {
+	return hash % uint64(hashMap.length)
 }
 
-func linearProbing(cell uint64) uint64 {
-	return (cell + 1) % uint64(length)
+func (hashMap *HashTableWithLinearProbing) linearProbing(cell uint64) uint64 {
+	return (cell + 1) % uint64(hashMap.length)
 }
 
 func HashStringKey(key string) uint64 {
 	// very simple hash from string:
-	// get uint64 value as start pointer of string in memory
+	// fold string by uint64 words (tail word padded with zeros) and mix every word with murmur finalizer,
+	// hash is seeded with length of string, so zero padding does not make keys of different length equal
 	// will work only for non-empty strings
-	data := []byte(key)
-	toHash := *(*uint64)(unsafe.Pointer(&data[0]))
-	return murmurFinalizerHash64(toHash)
+	hash := uint64(len(key))
+	for i := 0; i < len(key); i += 8 {
+		var word uint64
+		for j := i; j < len(key) && j < i+8; j++ {
+			word |= uint64(key[j]) << (8 * (j - i))
+		}
+		hash = murmurFinalizerHash64(hash ^ word)
+	}
+	return hash
 }
 
 func (hashMap *HashTableWithLinearProbing) resize(capacity int) {
 	oldTable := hashMap.Cells
+	tracker, err := hashMap.tracker, hashMap.err
 	*hashMap = *hashMap.hashMapWithCapacity(capacity)
-	size = 0
+	hashMap.tracker, hashMap.err = tracker, err
+	// old and new cells are both alive during resize
+	hashMap.track(int64(capacity))
 	for _, cell := range oldTable {
 		if &cell != nil && cell.state == Value {
 			hashMap.Put(cell.Key, cell.Value)
 		}
 	}
+	hashMap.tracker.Free(int64(len(oldTable)) * CellBytes)
 }
 
 func (hashMap *HashTableWithLinearProbing) Size() int {
-	return size
+	return hashMap.size
 }
 
 func (hashMap *HashTableWithLinearProbing) ContainsKey(key string) bool {
@@ -92,7 +141,7 @@ func (hashMap *HashTableWithLinearProbing) Put(key string, value int) {
 	}
 
 	hash := HashStringKey(key)
-	cell := getCell(hash)
+	cell := hashMap.getCell(hash)
 	startIdx := cell
 
 	for &hashMap.Cells[cell] != nil && hashMap.Cells[cell].state != Null {
@@ -103,10 +152,10 @@ func (hashMap *HashTableWithLinearProbing) Put(key string, value int) {
 		}
 
 		// make linear probing
-		cell = linearProbing(cell)
+		cell = hashMap.linearProbing(cell)
 		if cell == startIdx {
-			hashMap.resize(length * 2)
-			cell = getCell(hash)
+			hashMap.resize(hashMap.length * 2)
+			cell = hashMap.getCell(hash)
 			startIdx = cell
 		}
 	}
@@ -116,7 +165,7 @@ func (hashMap *HashTableWithLinearProbing) Put(key string, value int) {
 		Value: value,
 		state: Value,
 	}
-	size++
+	hashMap.size++
 }
 
 func (hashMap *HashTableWithLinearProbing) Get(key string) *Cell {
@@ -125,13 +174,13 @@ func (hashMap *HashTableWithLinearProbing) Get(key string) *Cell {
 	}
 
 	hash := HashStringKey(key)
-	cell := getCell(hash)
+	cell := hashMap.getCell(hash)
 	startIdx := cell
 	for &hashMap.Cells[cell] != nil && hashMap.Cells[cell].state != Null {
 		if hashMap.Cells[cell].Key == key && hashMap.Cells[cell].state == Value {
 			return &hashMap.Cells[cell]
 		}
-		cell = linearProbing(cell)
+		cell = hashMap.linearProbing(cell)
 		if cell == startIdx {
 			return nil
 		}
@@ -145,20 +194,45 @@ func (hashMap *HashTableWithLinearProbing) Remove(key string) {
 	}
 
 	hash := HashStringKey(key)
-	cell := getCell(hash)
+	cell := hashMap.getCell(hash)
 	startIdx := cell
-	for &hashMap.Cells[cell] != nil {
-		if hashMap.Cells[cell].Key == key && hashMap.Cells[cell].state == Value {
-			hashMap.Cells[cell].state = Null
-			size--
+	for hashMap.Cells[cell].state != Null {
+		if hashMap.Cells[cell].Key == key {
+			hashMap.removeCell(cell)
+			hashMap.size--
 			break
 		}
-		cell = linearProbing(cell)
+		cell = hashMap.linearProbing(cell)
 		if cell == startIdx {
 			break
 		}
 	}
-	if size == length/4 && length/2 != 0 {
-		hashMap.resize(length / 2)
+	if hashMap.size == hashMap.length/4 && hashMap.length/2 != 0 {
+		hashMap.resize(hashMap.length / 2)
+	}
+}
+
+// removeCell empties cell and shifts back following keys of the chain, so no key is cut off from its home cell
+func (hashMap *HashTableWithLinearProbing) removeCell(empty uint64) {
+	// emptied cell ends the loop even if table was full
+	hashMap.Cells[empty] = Cell{}
+	for cell := hashMap.linearProbing(empty); hashMap.Cells[cell].state != Null; cell = hashMap.linearProbing(cell) {
+		home := hashMap.getCell(HashStringKey(hashMap.Cells[cell].Key))
+		// key stays if its home is cyclically in (empty, cell]
+		if (empty < cell && empty < home && home <= cell) || (cell < empty && (empty < home || home <= cell)) {
+			continue
+		}
+		hashMap.Cells[empty] = hashMap.Cells[cell]
+		hashMap.Cells[cell] = Cell{}
+		empty = cell
+	}
+}
+
+// Range calls fn for every key of the table
+func (hashMap *HashTableWithLinearProbing) Range(fn func(key string, value int)) {
+	for idx := range hashMap.Cells {
+		if cell := &hashMap.Cells[idx]; cell.state == Value {
+			fn(cell.Key, cell.Value)
+		}
 	}
 }
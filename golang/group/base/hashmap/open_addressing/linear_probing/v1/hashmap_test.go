@@ -2,6 +2,9 @@ package v1
 
 import (
 	"github.com/stretchr/testify/require"
+	"group/base/memory"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -49,3 +52,38 @@ func TestHashMapLinearProbing(t *testing.T) {
 
 	require.True(t, hashTable.Size() == 8)
 }
+
+func TestHashMapMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("table", 64*CellBytes)
+	hashTable := new(HashTableWithLinearProbing).NewWithTracker(tracker)
+	require.Equal(t, 8*CellBytes, tracker.Used())
+
+	for i := 0; i < 32; i++ {
+		hashTable.Put(strconv.Itoa(i), i)
+	}
+	require.NoError(t, hashTable.Err())
+	require.Equal(t, 32*CellBytes, tracker.Used())
+	// old and new cells at the moment of last resize
+	require.Equal(t, 48*CellBytes, tracker.Peak())
+
+	// resize to 64 cells keeps 32 + 64 cells alive for a moment
+	hashTable.Put("32", 32)
+	require.ErrorIs(t, hashTable.Err(), memory.ErrMemoryLimitExceeded)
+	require.Equal(t, 64*CellBytes, tracker.Used())
+	require.Equal(t, 33, hashTable.Size())
+
+	hashTable.Release()
+	require.Zero(t, tracker.Used())
+}
+
+func TestHashStringKeyOfZeroPaddedKeys(t *testing.T) {
+	// zero padding of the last word does not make keys of different length equal
+	hashes := make(map[uint64]string)
+	for _, key := range []string{"a", "a\x00", "a\x00\x00", "\x00", "\x00\x00", strings.Repeat("\x00", 9)} {
+		hash := HashStringKey(key)
+		require.NotZero(t, hash, "key %q", key)
+		_, ok := hashes[hash]
+		require.False(t, ok, "key %q", key)
+		hashes[hash] = key
+	}
+}
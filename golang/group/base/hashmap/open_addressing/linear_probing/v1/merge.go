@@ -0,0 +1,156 @@
+package v1
+
+import (
+	"sync"
+)
+
+/*
+Parallel merge of hash tables with linear probing (Approach 2)
+
+All tables are resized to the same capacity, so every key has the same home cell `hash % capacity` in every table.
+Range of cells [0, capacity) is split to `parallelism` ranges, worker owns keys with home cell in its range:
+- worker scans cells of its range in every table and goes on after the end of range until first empty cell,
+  since keys of the range displaced by collision resolution chain may sit after the range (or wrap around to the
+  beginning of table for the last range);
+- keys met in the range but having home cell in previous range belong to previous worker and skipped;
+- worker writes only cells of its own range in result table, if collision resolution chain of a key reaches
+  the end of range, key is kept aside as overflow and put to result table sequentially when all workers are done.
+Number of overflow keys is small - only chains crossing edges of ranges.
+*/
+
+// Merge sequentially merges all tables into the first one, values of same keys are summed up
+func Merge(tables []*HashTableWithLinearProbing) *HashTableWithLinearProbing {
+	if len(tables) == 0 {
+		return new(HashTableWithLinearProbing).New()
+	}
+
+	primaryTable := tables[0]
+	for _, table := range tables[1:] {
+		for _, cell := range table.Cells {
+			if cell.state != Value {
+				continue
+			}
+			primaryValue := cell.Value
+			if primaryCell := primaryTable.Get(cell.Key); primaryCell != nil {
+				primaryValue += primaryCell.Value
+			}
+			primaryTable.Put(cell.Key, primaryValue)
+		}
+	}
+	return primaryTable
+}
+
+// ParallelMerge merges all tables into new table by `parallelism` workers, values of same keys are summed up.
+// Tables are resized to the same capacity in place.
+func ParallelMerge(tables []*HashTableWithLinearProbing, parallelism int) *HashTableWithLinearProbing {
+	// keep load factor of result not bigger than 1/2, so there are always empty cells to stop scan of chain
+	capacity := defaultCapacity
+	for _, table := range tables {
+		capacity += 2 * table.size
+	}
+	return parallelMergeWithCapacity(tables, parallelism, capacity)
+}
+
+type mergeRange struct {
+	start    uint64
+	end      uint64
+	size     int
+	overflow *HashTableWithLinearProbing
+}
+
+func parallelMergeWithCapacity(tables []*HashTableWithLinearProbing, parallelism int, capacity int) *HashTableWithLinearProbing {
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	if parallelism > capacity {
+		parallelism = capacity
+	}
+
+	// phase 1 - resize tables to the same capacity
+	var wg sync.WaitGroup
+	for _, table := range tables {
+		if table.length == capacity {
+			continue
+		}
+		wg.Add(1)
+		go func(table *HashTableWithLinearProbing) {
+			defer wg.Done()
+			table.resize(capacity)
+		}(table)
+	}
+	wg.Wait()
+
+	// phase 2 - merge ranges in parallel
+	result := new(HashTableWithLinearProbing).hashMapWithCapacity(capacity)
+	ranges := make([]mergeRange, parallelism)
+	for idx := range ranges {
+		ranges[idx] = mergeRange{
+			start:    uint64(capacity * idx / parallelism),
+			end:      uint64(capacity * (idx + 1) / parallelism),
+			overflow: new(HashTableWithLinearProbing).New(),
+		}
+		wg.Add(1)
+		go func(mergeRange *mergeRange) {
+			defer wg.Done()
+			for _, table := range tables {
+				result.mergeRange(table, mergeRange)
+			}
+		}(&ranges[idx])
+	}
+	wg.Wait()
+
+	// phase 3 - put keys from edges of ranges
+	for _, mergeRange := range ranges {
+		result.size += mergeRange.size
+		for _, cell := range mergeRange.overflow.Cells {
+			if cell.state == Value {
+				result.Put(cell.Key, cell.Value)
+			}
+		}
+	}
+	return result
+}
+
+func (hashMap *HashTableWithLinearProbing) mergeRange(table *HashTableWithLinearProbing, mergeRange *mergeRange) {
+	length := uint64(table.length)
+	for cell := mergeRange.start; cell < mergeRange.start+length; cell++ {
+		idx := cell % length
+		if table.Cells[idx].state != Value {
+			if cell >= mergeRange.end {
+				// end of collision resolution chain after the range
+				return
+			}
+			continue
+		}
+
+		home := HashStringKey(table.Cells[idx].Key) % length
+		if home < mergeRange.start || home >= mergeRange.end {
+			continue
+		}
+		hashMap.putInRange(table.Cells[idx].Key, home, table.Cells[idx].Value, mergeRange)
+	}
+}
+
+// putInRange adds value to key in cells of range only, keeps key as overflow if chain leaves the range
+func (hashMap *HashTableWithLinearProbing) putInRange(key string, home uint64, value int, mergeRange *mergeRange) {
+	for cell := home; cell < mergeRange.end; cell++ {
+		if hashMap.Cells[cell].state != Value {
+			hashMap.Cells[cell] = Cell{
+				Key:   key,
+				Value: value,
+				state: Value,
+			}
+			mergeRange.size++
+			return
+		}
+		if hashMap.Cells[cell].Key == key {
+			hashMap.Cells[cell].Value += value
+			return
+		}
+	}
+
+	if overflowCell := mergeRange.overflow.Get(key); overflowCell != nil {
+		value += overflowCell.Value
+	}
+	mergeRange.overflow.Put(key, value)
+}
@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/require"
+)
+
+func randomTables(random *rand.Rand) []*HashTableWithLinearProbing {
+	tables := make([]*HashTableWithLinearProbing, 1+random.Intn(8))
+	keys := 1 + random.Intn(500)
+	for idx := range tables {
+		tables[idx] = new(HashTableWithLinearProbing).New()
+		for i := random.Intn(300); i > 0; i-- {
+			key := "key-" + strconv.Itoa(random.Intn(keys))
+			value := random.Intn(100)
+			if cell := tables[idx].Get(key); cell != nil {
+				value += cell.Value
+			}
+			tables[idx].Put(key, value)
+		}
+	}
+	return tables
+}
+
+func toMap(table *HashTableWithLinearProbing) map[string]int {
+	groups := make(map[string]int)
+	for _, cell := range table.Cells {
+		if cell.state == Value {
+			groups[cell.Key] = cell.Value
+		}
+	}
+	return groups
+}
+
+func copyTables(tables []*HashTableWithLinearProbing) []*HashTableWithLinearProbing {
+	copied := make([]*HashTableWithLinearProbing, len(tables))
+	for idx, table := range tables {
+		copied[idx] = &HashTableWithLinearProbing{
+			Cells:  append([]Cell(nil), table.Cells...),
+			length: table.length,
+			size:   table.size,
+		}
+	}
+	return copied
+}
+
+// every key of merged table must be reachable by linear probing from its home cell
+func requireConsistent(t *testing.T, table *HashTableWithLinearProbing) {
+	size := 0
+	for _, cell := range table.Cells {
+		if cell.state != Value {
+			continue
+		}
+		size++
+		require.NotNil(t, table.Get(cell.Key))
+		require.Equal(t, cell.Value, table.Get(cell.Key).Value)
+	}
+	require.Equal(t, size, table.Size())
+}
+
+func TestParallelMergeMatchesSequentialMerge(t *testing.T) {
+	property := func(seed int64, parallelism uint8) bool {
+		random := rand.New(rand.NewSource(seed))
+		tables := randomTables(random)
+
+		expected := toMap(Merge(copyTables(tables)))
+		merged := ParallelMerge(tables, 1+int(parallelism%16))
+
+		requireConsistent(t, merged)
+		return reflect.DeepEqual(expected, toMap(merged))
+	}
+	require.NoError(t, quick.Check(property, &quick.Config{MaxCount: 200}))
+}
+
+func TestParallelMergeHighLoadFactor(t *testing.T) {
+	// long collision resolution chains cross edges of ranges and wrap around the end of table
+	property := func(seed int64, parallelism uint8) bool {
+		random := rand.New(rand.NewSource(seed))
+		tables := randomTables(random)
+
+		expected := toMap(Merge(copyTables(tables)))
+		merged := parallelMergeWithCapacity(tables, 1+int(parallelism%64), len(expected)+1)
+
+		requireConsistent(t, merged)
+		return reflect.DeepEqual(expected, toMap(merged))
+	}
+	require.NoError(t, quick.Check(property, &quick.Config{MaxCount: 200}))
+}
+
+func TestParallelMergeEmpty(t *testing.T) {
+	merged := ParallelMerge(nil, 4)
+	require.Equal(t, 0, merged.Size())
+
+	merged = ParallelMerge([]*HashTableWithLinearProbing{new(HashTableWithLinearProbing).New()}, 4)
+	require.Equal(t, 0, merged.Size())
+}
+
+func BenchmarkMerge(b *testing.B) {
+	random := rand.New(rand.NewSource(42))
+	tables := make([]*HashTableWithLinearProbing, 8)
+	for idx := range tables {
+		tables[idx] = new(HashTableWithLinearProbing).New()
+		for i := 0; i < 5000; i++ {
+			tables[idx].Put("key-"+strconv.Itoa(random.Intn(20000)), i)
+		}
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			Merge(copyTables(tables))
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			ParallelMerge(copyTables(tables), 8)
+		}
+	})
+}
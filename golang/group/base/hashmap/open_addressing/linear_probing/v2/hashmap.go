@@ -1,6 +1,8 @@
 package v2
 
 import (
+	"group/base/memory"
+	"runtime"
 	"sync/atomic"
 	"unsafe"
 )
@@ -16,135 +18,199 @@ func murmurFinalizerHash64(hash uint64) uint64 {
 }
 
 /*
-HashTableWithLinearProbing implementation
+HashTableWithLinearProbing implementation - shared table with spin-lock on each cell
+
+Table has fixed capacity, so it is never resized under concurrent writers. Every cell has state which is changed
+by compare-and-swap only:
+- Null -> Locked: goroutine acquired empty cell to insert key;
+- Value -> Locked: goroutine acquired cell to update value;
+- Locked -> Value: goroutine published key / value and released cell;
+- Value -> Deleted: key removed, cell is never reused.
+Key of cell is written only while cell is locked before first publish, so it can be read without lock after
+state is loaded as Value.
+
+If collision resolution chain of key is longer than maxProbes (or whole table is full), table signals TableFull,
+so caller can aggregate key somewhere else (as example in thread-local table).
 */
 const (
-	defaultCapacity int = 16
+	defaultCapacity  int = 1 << 12
+	defaultMaxProbes int = 16
 )
 
-var length int
-var size int
-
 const (
-	Null  = 0
-	Value = 1
+	Null    = 0
+	Value   = 1
+	Locked  = 2
+	Deleted = 3
 )
 
 const (
+	// BreakerClosed - value is applied to the table
 	BreakerClosed = 0
+	// BreakerOpened - cell is locked by other goroutine, value is not applied
 	BreakerOpened = 1
+	// TableFull - there is no free cell in collision resolution chain of key, value is not applied
+	TableFull = 2
 )
 
 type Cell struct {
-	Key         string
-	Value       int
-	state       int
-	cellBreaker *atomic.Bool
+	Key   string
+	value atomic.Int64
+	state atomic.Int32
+}
+
+func (cell *Cell) Value() int {
+	return int(cell.value.Load())
 }
 
+// CellBytes is memory taken by one cell
+const CellBytes = int64(unsafe.Sizeof(Cell{}))
+
 type HashTableWithLinearProbing struct {
-	Cells []Cell
+	cells     []Cell
+	length    int
+	maxProbes int
+	size      atomic.Int64
+	// account of cells, table is never resized, so cells are accounted once
+	account *memory.Account
 }
 
 func (hashMap *HashTableWithLinearProbing) New() *HashTableWithLinearProbing {
-	return hashMap.hashMapWithCapacity(defaultCapacity)
+	return hashMap.NewWithCapacity(defaultCapacity, defaultMaxProbes)
+}
+
+// NewWithCapacity makes table of fixed capacity, key is looked up in maxProbes cells starting from its home cell
+func (hashMap *HashTableWithLinearProbing) NewWithCapacity(capacity int, maxProbes int) *HashTableWithLinearProbing {
+	if maxProbes <= 0 || maxProbes > capacity {
+		maxProbes = capacity
+	}
+	return &HashTableWithLinearProbing{cells: make([]Cell, capacity), length: capacity, maxProbes: maxProbes}
+}
+
+// NewWithTracker makes table of fixed capacity reporting memory of its cells to tracker
+func (hashMap *HashTableWithLinearProbing) NewWithTracker(capacity int, maxProbes int,
+	tracker *memory.Tracker) *HashTableWithLinearProbing {
+	result := hashMap.NewWithCapacity(capacity, maxProbes)
+	result.account = new(memory.Account).New(tracker)
+	result.account.Alloc(int64(capacity) * CellBytes)
+	return result
 }
 
-func (hashMap *HashTableWithLinearProbing) hashMapWithCapacity(capacity int) *HashTableWithLinearProbing {
-	size = 0
-	length = capacity
-	cells := make([]Cell, length)
-	return &HashTableWithLinearProbing{Cells: cells}
+var _ memory.Accounted = (*HashTableWithLinearProbing)(nil)
+
+func (hashMap *HashTableWithLinearProbing) Err() error {
+	return hashMap.account.Err()
 }
 
-func getCell(hash uint64) uint64 {
-	return hash % uint64(length)
+func (hashMap *HashTableWithLinearProbing) Release() {
+	hashMap.account.Release()
 }
 
-func linearProbing(cell uint64) uint64 {
-	return (cell + 1) % uint64(length)
+func (hashMap *HashTableWithLinearProbing) getCell(hash uint64) uint64 {
+	return hash % uint64(hashMap.length)
+}
+
+func (hashMap *HashTableWithLinearProbing) linearProbing(cell uint64) uint64 {
+	return (cell + 1) % uint64(hashMap.length)
 }
 
 func hashStringKey(key string) uint64 {
 	// very simple hash from string:
-	// get uint64 value as start pointer of string in memory
+	// fold string by uint64 words (tail word padded with zeros) and mix every word with murmur finalizer,
+	// hash is seeded with length of string, so zero padding does not make keys of different length equal
 	// will work only for non-empty strings
-	data := []byte(key)
-	toHash := *(*uint64)(unsafe.Pointer(&data[0]))
-	return murmurFinalizerHash64(toHash)
-}
-
-func (hashMap *HashTableWithLinearProbing) resize(capacity int) {
-	oldTable := hashMap.Cells
-	*hashMap = *hashMap.hashMapWithCapacity(capacity)
-	size = 0
-	for _, cell := range oldTable {
-		if &cell != nil && cell.state == Value {
-			hashMap.Put(cell.Key, cell.Value)
+	hash := uint64(len(key))
+	for i := 0; i < len(key); i += 8 {
+		var word uint64
+		for j := i; j < len(key) && j < i+8; j++ {
+			word |= uint64(key[j]) << (8 * (j - i))
 		}
+		hash = murmurFinalizerHash64(hash ^ word)
 	}
+	return hash
 }
 
 func (hashMap *HashTableWithLinearProbing) Size() int {
-	return size
+	return int(hashMap.size.Load())
+}
+
+func (hashMap *HashTableWithLinearProbing) Capacity() int {
+	return hashMap.length
 }
 
 func (hashMap *HashTableWithLinearProbing) ContainsKey(key string) bool {
 	return hashMap.Get(key) != nil
 }
 
+// Put sets value of key, returns BreakerOpened without waiting if cell is locked
 func (hashMap *HashTableWithLinearProbing) Put(key string, value int) int {
-	if key == "" {
-		return BreakerClosed
-	}
+	return hashMap.update(key, value, false, false)
+}
 
-	hash := hashStringKey(key)
-	cell := getCell(hash)
-	startIdx := cell
+// Add adds value to aggregate of key, returns BreakerOpened without waiting if cell is locked
+func (hashMap *HashTableWithLinearProbing) Add(key string, value int) int {
+	return hashMap.update(key, value, true, false)
+}
 
-	// init breaker if not yet
-	if hashMap.Cells[cell].cellBreaker == nil {
-		hashMap.Cells[cell].cellBreaker = new(atomic.Bool)
-	}
-	// if breaker is opened
-	if hashMap.Cells[cell].cellBreaker.Load() == true {
-		return BreakerOpened
+// AddSpin adds value to aggregate of key, spins while cell is locked by other goroutines
+func (hashMap *HashTableWithLinearProbing) AddSpin(key string, value int) int {
+	return hashMap.update(key, value, true, true)
+}
+
+func (hashMap *HashTableWithLinearProbing) update(key string, value int, add bool, spin bool) int {
+	if key == "" {
+		return BreakerClosed
 	}
 
-	for &hashMap.Cells[cell] != nil && hashMap.Cells[cell].state != Null {
-		// open breaker
-		hashMap.Cells[cell].cellBreaker.Store(true)
-		// update value of cell if it exists
-		if hashMap.Cells[cell].Key == key && hashMap.Cells[cell].state == Value {
-			hashMap.Cells[cell].Value = value
-			// close breaker
-			hashMap.Cells[cell].cellBreaker.Store(false)
+	cell := hashMap.getCell(hashStringKey(key))
+	for probe := 0; probe < hashMap.maxProbes; {
+		current := &hashMap.cells[cell]
+		switch current.state.Load() {
+		case Null:
+			// acquire empty cell
+			if !current.state.CompareAndSwap(Null, Locked) {
+				continue
+			}
+			current.Key = key
+			current.value.Store(int64(value))
+			hashMap.size.Add(1)
+			// release cell
+			current.state.Store(Value)
+			return BreakerClosed
+		case Locked:
+			// we do not know key of the cell until it is published
+			if !spin {
+				return BreakerOpened
+			}
+			runtime.Gosched()
+			continue
+		case Value:
+			if current.Key != key {
+				break
+			}
+			// acquire cell with the key
+			if !current.state.CompareAndSwap(Value, Locked) {
+				if !spin {
+					return BreakerOpened
+				}
+				runtime.Gosched()
+				continue
+			}
+			if add {
+				current.value.Add(int64(value))
+			} else {
+				current.value.Store(int64(value))
+			}
+			// release cell
+			current.state.Store(Value)
 			return BreakerClosed
 		}
 
-		// close breaker for cell since linear probing
-		hashMap.Cells[cell].cellBreaker.Store(false)
 		// make linear probing
-		cell = linearProbing(cell)
-		if cell == startIdx {
-			hashMap.resize(length * 2)
-			cell = getCell(hash)
-			startIdx = cell
-		}
-	}
-
-	hashMap.Cells[cell] = Cell{
-		Key:         key,
-		Value:       value,
-		state:       Value,
-		cellBreaker: new(atomic.Bool),
+		cell = hashMap.linearProbing(cell)
+		probe++
 	}
-	size++
-
-	// close breaker
-	hashMap.Cells[cell].cellBreaker.Store(false)
-
-	return BreakerClosed
+	return TableFull
 }
 
 func (hashMap *HashTableWithLinearProbing) Get(key string) *Cell {
@@ -152,17 +218,23 @@ func (hashMap *HashTableWithLinearProbing) Get(key string) *Cell {
 		return nil
 	}
 
-	hash := hashStringKey(key)
-	cell := getCell(hash)
-	startIdx := cell
-	for &hashMap.Cells[cell] != nil && hashMap.Cells[cell].state != Null {
-		if hashMap.Cells[cell].Key == key && hashMap.Cells[cell].state == Value {
-			return &hashMap.Cells[cell]
-		}
-		cell = linearProbing(cell)
-		if cell == startIdx {
+	cell := hashMap.getCell(hashStringKey(key))
+	for probe := 0; probe < hashMap.maxProbes; {
+		current := &hashMap.cells[cell]
+		state := current.state.Load()
+		if state == Null {
 			return nil
 		}
+		// key of locked cell may be not published yet
+		if state == Locked {
+			runtime.Gosched()
+			continue
+		}
+		if state == Value && current.Key == key {
+			return current
+		}
+		cell = hashMap.linearProbing(cell)
+		probe++
 	}
 	return nil
 }
@@ -172,21 +244,35 @@ func (hashMap *HashTableWithLinearProbing) Remove(key string) {
 		return
 	}
 
-	hash := hashStringKey(key)
-	cell := getCell(hash)
-	startIdx := cell
-	for &hashMap.Cells[cell] != nil {
-		if hashMap.Cells[cell].Key == key && hashMap.Cells[cell].state == Value {
-			hashMap.Cells[cell].state = Null
-			size--
-			break
+	cell := hashMap.getCell(hashStringKey(key))
+	for probe := 0; probe < hashMap.maxProbes; {
+		current := &hashMap.cells[cell]
+		state := current.state.Load()
+		if state == Null {
+			return
+		}
+		if state == Locked {
+			runtime.Gosched()
+			continue
 		}
-		cell = linearProbing(cell)
-		if cell == startIdx {
-			break
+		if state == Value && current.Key == key {
+			if !current.state.CompareAndSwap(Value, Deleted) {
+				continue
+			}
+			hashMap.size.Add(-1)
+			return
 		}
+		cell = hashMap.linearProbing(cell)
+		probe++
 	}
-	if size == length/4 && length/2 != 0 {
-		hashMap.resize(length / 2)
+}
+
+// Range calls fn for every key of the table, values of keys may be updated concurrently
+func (hashMap *HashTableWithLinearProbing) Range(fn func(key string, value int)) {
+	for idx := range hashMap.cells {
+		cell := &hashMap.cells[idx]
+		if cell.state.Load() == Value {
+			fn(cell.Key, cell.Value())
+		}
 	}
 }
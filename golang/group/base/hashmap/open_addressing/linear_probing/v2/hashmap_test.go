@@ -1,7 +1,13 @@
 package v2
 
 import (
+	"group/base/memory"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestHashMapAtomicBreaker(t *testing.T) {
@@ -27,3 +33,100 @@ func TestHashMapAtomicBreaker(t *testing.T) {
 		}()
 	}
 }
+
+func TestHashMapConcurrentAdd(t *testing.T) {
+	hashTable := new(HashTableWithLinearProbing).New()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 16; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				require.Equal(t, BreakerClosed, hashTable.AddSpin("key-"+strconv.Itoa(i%50), 1))
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 50, hashTable.Size())
+	hashTable.Range(func(key string, value int) {
+		require.Equal(t, 16*1000/50, value)
+	})
+}
+
+func TestHashMapTryAddSignalsContention(t *testing.T) {
+	hashTable := new(HashTableWithLinearProbing).New()
+
+	var wg sync.WaitGroup
+	var applied, opened atomic.Int64
+	for worker := 0; worker < 16; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				switch hashTable.Add("hot", 1) {
+				case BreakerClosed:
+					applied.Add(1)
+				case BreakerOpened:
+					opened.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// every value is either applied to the table or rejected, never lost
+	require.Equal(t, int64(16*1000), applied.Load()+opened.Load())
+	require.Equal(t, int(applied.Load()), hashTable.Get("hot").Value())
+}
+
+func TestHashMapLockedCell(t *testing.T) {
+	hashTable := new(HashTableWithLinearProbing).New()
+	require.Equal(t, BreakerClosed, hashTable.Put("1", 1))
+
+	// lock cell as other goroutine would do
+	cell := hashTable.Get("1")
+	require.True(t, cell.state.CompareAndSwap(Value, Locked))
+	require.Equal(t, BreakerOpened, hashTable.Add("1", 1))
+	require.Equal(t, BreakerOpened, hashTable.Put("1", 5))
+
+	done := make(chan int)
+	go func() {
+		done <- hashTable.AddSpin("1", 1)
+	}()
+	cell.state.Store(Value)
+	require.Equal(t, BreakerClosed, <-done)
+	require.Equal(t, 2, hashTable.Get("1").Value())
+}
+
+func TestHashMapFixedCapacity(t *testing.T) {
+	hashTable := new(HashTableWithLinearProbing).NewWithCapacity(8, 0)
+	for i := 0; i < 8; i++ {
+		require.Equal(t, BreakerClosed, hashTable.Put(strconv.Itoa(i), i))
+	}
+	require.Equal(t, TableFull, hashTable.Put("8", 8))
+	require.Equal(t, 8, hashTable.Size())
+
+	hashTable.Remove("3")
+	require.Nil(t, hashTable.Get("3"))
+	require.Equal(t, 7, hashTable.Size())
+	for i := 0; i < 8; i++ {
+		if i != 3 {
+			require.Equal(t, i, hashTable.Get(strconv.Itoa(i)).Value())
+		}
+	}
+	// removed cells are never reused
+	require.Equal(t, TableFull, hashTable.Put("8", 8))
+}
+
+func TestHashMapMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("table", 16*CellBytes)
+	hashTable := new(HashTableWithLinearProbing).NewWithTracker(8, 0, tracker)
+	require.Equal(t, 8*CellBytes, tracker.Used())
+	require.NoError(t, hashTable.Err())
+	hashTable.Release()
+	require.Zero(t, tracker.Used())
+
+	require.ErrorIs(t, new(HashTableWithLinearProbing).NewWithTracker(32, 0, tracker).Err(), memory.ErrMemoryLimitExceeded)
+}
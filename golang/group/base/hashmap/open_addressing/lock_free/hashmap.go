@@ -0,0 +1,428 @@
+package lock_free
+
+import (
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
+	"sync/atomic"
+	"unsafe"
+)
+
+/*
+LockFreeHashTable implementation - open addressing table with atomic add of int64 aggregate and cooperative
+resizing (Cliff Click style incremental migration - https://preshing.com/20160222/a-resizable-concurrent-map/).
+
+Key slot is claimed once by compare-and-swap from empty to key, keys are never moved inside of table.
+Value slot is one word: value in low 62 bits and two flags:
+- set: value is final for this table, until it is not set the value may still sit in previous table;
+- sealed: table is migrated to the next one, value is copied (or being copied) and must be updated there.
+
+Resize:
+- goroutine which sees table filled more than half allocates next table of double capacity;
+- every goroutine touching old table helps to migrate chunk of slots: empty key slots are closed by tombstone key
+  (so nobody inserts in old table anymore), values are sealed and copied to next table;
+- copy of value to next table is compare-and-swap from unset value, so it happens exactly once even if several
+  goroutines migrate the same slot;
+- update of unset value in next table copies slot of the key from old table first, so it never loses old value.
+When all slots of old table are migrated, next table becomes current one.
+Values are limited to 62 bits (two's complement).
+*/
+
+const (
+	defaultCapacity        = 16
+	migrationChunk         = 64
+	valueBits              = 62
+	valueMask       uint64 = 1<<valueBits - 1
+	setFlag         uint64 = 1 << valueBits
+	sealedFlag      uint64 = 1 << (valueBits + 1)
+)
+
+// SlotBytes is memory taken by key and value of one slot
+const SlotBytes = int64(unsafe.Sizeof(atomic.Pointer[string]{}) + unsafe.Sizeof(atomic.Uint64{}))
+
+// tombstone closes empty key slot of migrated table
+var tombstone = new(string)
+
+func encode(value int64) uint64 {
+	return uint64(value) & valueMask
+}
+
+func decode(word uint64) int64 {
+	return int64(word<<2) >> 2
+}
+
+type table struct {
+	keys   []atomic.Pointer[string]
+	values []atomic.Uint64
+	mask   uint64
+	size   atomic.Int64
+
+	prev      atomic.Pointer[table]
+	next      atomic.Pointer[table]
+	copyIdx   atomic.Int64
+	copyDone  atomic.Int64
+	resizeCnt *atomic.Int64
+	account   *memory.Account
+}
+
+func newTable(capacity int, resizeCnt *atomic.Int64, account *memory.Account) *table {
+	return &table{
+		keys:      make([]atomic.Pointer[string], capacity),
+		values:    make([]atomic.Uint64, capacity),
+		mask:      uint64(capacity - 1),
+		resizeCnt: resizeCnt,
+		account:   account,
+	}
+}
+
+type LockFreeHashTable struct {
+	current atomic.Pointer[table]
+	resizes atomic.Int64
+	// account of slots, old table is accounted until the next one is promoted
+	account *memory.Account
+}
+
+func (hashMap *LockFreeHashTable) New() *LockFreeHashTable {
+	return hashMap.NewWithCapacity(defaultCapacity)
+}
+
+// NewWithCapacity makes table with initial capacity rounded up to power of two
+func (hashMap *LockFreeHashTable) NewWithCapacity(capacity int) *LockFreeHashTable {
+	length := defaultCapacity
+	for length < capacity {
+		length <<= 1
+	}
+	result := &LockFreeHashTable{}
+	result.current.Store(newTable(length, &result.resizes, nil))
+	return result
+}
+
+// NewWithTracker makes table with initial capacity reporting memory of its slots to tracker
+func (hashMap *LockFreeHashTable) NewWithTracker(capacity int, tracker *memory.Tracker) *LockFreeHashTable {
+	result := hashMap.NewWithCapacity(capacity)
+	result.account = new(memory.Account).New(tracker)
+	t := result.current.Load()
+	t.account = result.account
+	t.account.Alloc(int64(len(t.keys)) * SlotBytes)
+	return result
+}
+
+var _ memory.Accounted = (*LockFreeHashTable)(nil)
+
+func (hashMap *LockFreeHashTable) Err() error {
+	return hashMap.account.Err()
+}
+
+func (hashMap *LockFreeHashTable) Release() {
+	hashMap.account.Release()
+}
+
+// find returns slot of key or -1 if key is not in table, empty slots met are not closed
+func (t *table) find(key string, hash uint64) int {
+	idx := hash & t.mask
+	for probe := uint64(0); probe <= t.mask; probe++ {
+		current := t.keys[idx].Load()
+		if current == nil || current == tombstone {
+			return -1
+		}
+		if *current == key {
+			return int(idx)
+		}
+		idx = (idx + 1) & t.mask
+	}
+	return -1
+}
+
+// findOrInsert returns slot of key, inserting it if needed. Returns -1 if table is full or being migrated.
+func (t *table) findOrInsert(key string, hash uint64) int {
+	if t.next.Load() != nil {
+		return -1
+	}
+	idx := hash & t.mask
+	for probe := uint64(0); probe <= t.mask; {
+		current := t.keys[idx].Load()
+		if current == nil {
+			if t.size.Load() >= int64(len(t.keys)/2) {
+				// table is filled more than half, time to resize
+				t.startResize()
+				return -1
+			}
+			if !t.keys[idx].CompareAndSwap(nil, &key) {
+				// reload slot, other goroutine claimed it
+				continue
+			}
+			t.size.Add(1)
+			return int(idx)
+		}
+		if current == tombstone {
+			return -1
+		}
+		if *current == key {
+			return int(idx)
+		}
+		idx = (idx + 1) & t.mask
+		probe++
+	}
+	t.startResize()
+	return -1
+}
+
+// insertForMigration returns slot of key inserting it if needed, ignoring fill factor of table.
+// Returns -1 only if table is migrated already (so key is copied already).
+func (t *table) insertForMigration(key string, hash uint64) int {
+	idx := hash & t.mask
+	for probe := uint64(0); probe <= t.mask; {
+		current := t.keys[idx].Load()
+		if current == nil {
+			if !t.keys[idx].CompareAndSwap(nil, &key) {
+				continue
+			}
+			t.size.Add(1)
+			return int(idx)
+		}
+		if current == tombstone {
+			return -1
+		}
+		if *current == key {
+			return int(idx)
+		}
+		idx = (idx + 1) & t.mask
+		probe++
+	}
+	return -1
+}
+
+// findOrClose returns slot of key or -1 if key is not in table, so it can not be inserted in table anymore
+func (t *table) findOrClose(key string, hash uint64) int {
+	idx := hash & t.mask
+	for probe := uint64(0); probe <= t.mask; {
+		current := t.keys[idx].Load()
+		if current == nil {
+			if !t.keys[idx].CompareAndSwap(nil, tombstone) {
+				continue
+			}
+			return -1
+		}
+		if current == tombstone {
+			return -1
+		}
+		if *current == key {
+			return int(idx)
+		}
+		idx = (idx + 1) & t.mask
+		probe++
+	}
+	return -1
+}
+
+func (t *table) startResize() {
+	if t.next.Load() != nil {
+		return
+	}
+	// previous migration must be finished before the next one
+	if prev := t.prev.Load(); prev != nil {
+		prev.finishMigration()
+	}
+	next := newTable(2*len(t.keys), t.resizeCnt, t.account)
+	next.prev.Store(t)
+	if t.next.CompareAndSwap(nil, next) {
+		t.resizeCnt.Add(1)
+		t.account.Alloc(int64(len(next.keys)) * SlotBytes)
+	}
+}
+
+// seal makes value of slot final for the table and returns it
+func (t *table) seal(idx int) uint64 {
+	for {
+		word := t.values[idx].Load()
+		if word&sealedFlag != 0 {
+			return word
+		}
+		if t.values[idx].CompareAndSwap(word, word|sealedFlag) {
+			return word | sealedFlag
+		}
+	}
+}
+
+// migrateSlot moves slot to the next table, it is safe to migrate same slot several times
+func (t *table) migrateSlot(idx int) {
+	key := t.keys[idx].Load()
+	if key == nil {
+		if t.keys[idx].CompareAndSwap(nil, tombstone) {
+			return
+		}
+		key = t.keys[idx].Load()
+	}
+	if key == tombstone {
+		return
+	}
+	t.copySlot(idx, *key, v1.HashStringKey(*key))
+}
+
+// copySlot seals slot of old table and puts its value to the next table once
+func (t *table) copySlot(idx int, key string, hash uint64) {
+	// value of the key may still sit in previous table, it must be copied to this table before seal
+	if prev := t.prev.Load(); prev != nil {
+		prev.finishMigration()
+	}
+	word := t.seal(idx)
+
+	next := t.next.Load()
+	if nextIdx := next.insertForMigration(key, hash); nextIdx >= 0 {
+		next.values[nextIdx].CompareAndSwap(0, encode(decode(word))|setFlag)
+	}
+}
+
+func (t *table) helpMigrate() {
+	length := int64(len(t.keys))
+	start := t.copyIdx.Add(migrationChunk) - migrationChunk
+	if start >= length {
+		return
+	}
+	end := start + migrationChunk
+	if end > length {
+		end = length
+	}
+	for idx := start; idx < end; idx++ {
+		t.migrateSlot(int(idx))
+	}
+	if t.copyDone.Add(end-start) == length {
+		t.next.Load().prev.Store(nil)
+	}
+}
+
+// finishMigration migrates all slots of table without waiting for other goroutines
+func (t *table) finishMigration() {
+	next := t.next.Load()
+	if next == nil || next.prev.Load() == nil {
+		return
+	}
+	for idx := range t.keys {
+		t.migrateSlot(idx)
+	}
+	next.prev.Store(nil)
+}
+
+func (hashMap *LockFreeHashTable) promote(t *table) *table {
+	next := t.next.Load()
+	if next.prev.Load() == nil && hashMap.current.CompareAndSwap(t, next) {
+		t.account.Free(int64(len(t.keys)) * SlotBytes)
+	}
+	return next
+}
+
+// resolve makes value of slot set (copying it from previous table if needed) and returns current word of slot
+func (t *table) resolve(idx int, key string, hash uint64) uint64 {
+	for {
+		word := t.values[idx].Load()
+		if word&(setFlag|sealedFlag) != 0 {
+			return word
+		}
+		prev := t.prev.Load()
+		if prev == nil {
+			// previous table is migrated, so unset value is zero
+			return word
+		}
+		if prevIdx := prev.findOrClose(key, hash); prevIdx >= 0 {
+			prev.copySlot(prevIdx, key, hash)
+		} else {
+			t.values[idx].CompareAndSwap(0, setFlag)
+		}
+	}
+}
+
+// Add adds value to aggregate of key, key is inserted if it does not exist
+func (hashMap *LockFreeHashTable) Add(key string, value int64) {
+	hash := v1.HashStringKey(key)
+	t := hashMap.current.Load()
+	for {
+		if t.next.Load() != nil {
+			t.helpMigrate()
+			t = hashMap.promote(t)
+			continue
+		}
+
+		idx := t.findOrInsert(key, hash)
+		if idx < 0 {
+			continue
+		}
+
+		for {
+			word := t.resolve(idx, key, hash)
+			if word&sealedFlag != 0 {
+				break
+			}
+			if t.values[idx].CompareAndSwap(word, encode(decode(word)+value)|setFlag) {
+				return
+			}
+		}
+	}
+}
+
+// Get returns aggregate of key
+func (hashMap *LockFreeHashTable) Get(key string) (int64, bool) {
+	hash := v1.HashStringKey(key)
+	t := hashMap.current.Load()
+	for {
+		// load previous table before lookup, if it is migrated already then key is copied to this table
+		prev := t.prev.Load()
+		idx := t.find(key, hash)
+		if idx < 0 {
+			if prev == nil {
+				if next := t.next.Load(); next != nil {
+					// key may be inserted in the next table only
+					t = next
+					continue
+				}
+				return 0, false
+			}
+			prevIdx := prev.find(key, hash)
+			if prevIdx < 0 {
+				return 0, false
+			}
+			word := prev.values[prevIdx].Load()
+			if word&sealedFlag == 0 {
+				return decode(word), true
+			}
+			// value is moving to this table, help to copy it
+			prev.copySlot(prevIdx, key, hash)
+			continue
+		}
+
+		word := t.resolve(idx, key, hash)
+		if word&sealedFlag != 0 {
+			t = t.next.Load()
+			continue
+		}
+		return decode(word), true
+	}
+}
+
+// Range calls fn for every key, finishes migration in progress first
+func (hashMap *LockFreeHashTable) Range(fn func(key string, value int64)) {
+	t := hashMap.current.Load()
+	for t.next.Load() != nil {
+		t.finishMigration()
+		t = hashMap.promote(t)
+	}
+	for idx := range t.keys {
+		key := t.keys[idx].Load()
+		if key == nil || key == tombstone {
+			continue
+		}
+		word := t.resolve(idx, *key, v1.HashStringKey(*key))
+		fn(*key, decode(word))
+	}
+}
+
+func (hashMap *LockFreeHashTable) Size() int {
+	size := 0
+	hashMap.Range(func(key string, value int64) {
+		size++
+	})
+	return size
+}
+
+// Resizes returns number of times table been resized
+func (hashMap *LockFreeHashTable) Resizes() int {
+	return int(hashMap.resizes.Load())
+}
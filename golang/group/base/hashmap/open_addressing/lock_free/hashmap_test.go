@@ -0,0 +1,162 @@
+package lock_free
+
+import (
+	"group/base/memory"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockFreeHashTable(t *testing.T) {
+	hashTable := new(LockFreeHashTable).New()
+
+	hashTable.Add("1", 1)
+	hashTable.Add("2", 2)
+	hashTable.Add("2", 2)
+	hashTable.Add("3", -3)
+
+	value, ok := hashTable.Get("2")
+	require.True(t, ok)
+	require.Equal(t, int64(4), value)
+	value, ok = hashTable.Get("3")
+	require.True(t, ok)
+	require.Equal(t, int64(-3), value)
+	_, ok = hashTable.Get("test")
+	require.False(t, ok)
+
+	for i := 0; i < 1000; i++ {
+		hashTable.Add(strconv.Itoa(i), int64(i))
+	}
+	require.Equal(t, 1000, hashTable.Size())
+	require.Positive(t, hashTable.Resizes())
+	previous := map[string]int{"1": 1, "2": 4, "3": -3}
+	hashTable.Range(func(key string, value int64) {
+		expected, _ := strconv.Atoi(key)
+		require.Equal(t, int64(expected+previous[key]), value, key)
+	})
+}
+
+// every writer owns its keys, so it must always read its own writes, even while table is being migrated
+func TestLockFreeHashTableReadYourWrites(t *testing.T) {
+	hashTable := new(LockFreeHashTable).New()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := strconv.Itoa(worker) + "-" + strconv.Itoa(i%500)
+				hashTable.Add(key, 1)
+				value, ok := hashTable.Get(key)
+				require.True(t, ok)
+				require.Equal(t, int64(i/500+1), value, key)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	require.Equal(t, 8*500, hashTable.Size())
+}
+
+// concurrent readers of shared keys never see value going back, no add is lost or applied twice
+func TestLockFreeHashTableMonotonicReads(t *testing.T) {
+	hashTable := new(LockFreeHashTable).New()
+	const writers, adds, keys = 8, 5120, 64
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for reader := 0; reader < 2; reader++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := make([]int64, keys)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for key := 0; key < keys; key++ {
+					value, _ := hashTable.Get("shared-" + strconv.Itoa(key))
+					require.GreaterOrEqual(t, value, last[key])
+					last[key] = value
+				}
+			}
+		}()
+	}
+
+	var writersGroup sync.WaitGroup
+	for writer := 0; writer < writers; writer++ {
+		writersGroup.Add(1)
+		go func(writer int) {
+			defer writersGroup.Done()
+			for i := 0; i < adds; i++ {
+				hashTable.Add("shared-"+strconv.Itoa(i%keys), 1)
+				// unique keys force table to resize while shared keys are updated
+				hashTable.Add("unique-"+strconv.Itoa(writer)+"-"+strconv.Itoa(i), 1)
+			}
+		}(writer)
+	}
+	writersGroup.Wait()
+	close(done)
+	wg.Wait()
+
+	for key := 0; key < keys; key++ {
+		value, ok := hashTable.Get("shared-" + strconv.Itoa(key))
+		require.True(t, ok)
+		require.Equal(t, int64(writers*adds/keys), value)
+	}
+	require.Equal(t, keys+writers*adds, hashTable.Size())
+}
+
+func BenchmarkLockFreeHashTable(b *testing.B) {
+	keys := make([]string, 100000)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	hashTable := new(LockFreeHashTable).New()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			hashTable.Add(keys[i%len(keys)], 1)
+			i++
+		}
+	})
+}
+
+func TestLockFreeHashTableMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("table", 0)
+	hashTable := new(LockFreeHashTable).NewWithTracker(16, tracker)
+	require.Equal(t, 16*SlotBytes, tracker.Used())
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				hashTable.Add(strconv.Itoa(w*1000+i), 1)
+			}
+		}(w)
+	}
+	wg.Wait()
+	// range finishes migration, so only current table is accounted
+	require.Equal(t, 4000, hashTable.Size())
+	require.Equal(t, int64(len(hashTable.current.Load().keys))*SlotBytes, tracker.Used())
+	require.Greater(t, tracker.Peak(), tracker.Used())
+	require.NoError(t, hashTable.Err())
+
+	hashTable.Release()
+	require.Zero(t, tracker.Used())
+
+	limited := new(memory.Tracker).New("table", 64*SlotBytes)
+	hashTable = new(LockFreeHashTable).NewWithTracker(16, limited)
+	for i := 0; i < 100; i++ {
+		hashTable.Add(strconv.Itoa(i), 1)
+	}
+	require.ErrorIs(t, hashTable.Err(), memory.ErrMemoryLimitExceeded)
+	require.Equal(t, 100, hashTable.Size())
+}
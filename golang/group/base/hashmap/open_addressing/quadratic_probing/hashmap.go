@@ -0,0 +1,225 @@
+package quadratic_probing
+
+import (
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
+	"unsafe"
+)
+
+/*
+HashTableWithQuadraticProbing implementation - open addressing with triangular probing
+
+Key is looked up in cells home, home+1, home+3, home+6, ... (offsets are triangular numbers i*(i+1)/2). For capacity
+of power of two the sequence visits every cell exactly once, and unlike linear probing keys of neighbouring home
+cells do not join one long cluster (primary clustering), only keys of the same home cell share the probe sequence.
+The price is locality: after the first few probes every probe touches other cache line.
+
+Removed key leaves tombstone, so probe sequences of other keys stay unbroken. Insert reuses the first tombstone of
+the sequence, rehash drops all of them. Table is rehashed when used cells (keys and tombstones) exceed load factor -
+to twice capacity or to the same capacity if most of used cells are tombstones.
+*/
+const (
+	defaultCapacity   = 8
+	defaultLoadFactor = 0.75
+)
+
+const (
+	Null    = 0
+	Value   = 1
+	Deleted = 2
+)
+
+type Cell struct {
+	Key   string
+	Value int
+	state int
+}
+
+// CellBytes is memory taken by one cell
+const CellBytes = int64(unsafe.Sizeof(Cell{}))
+
+type HashTableWithQuadraticProbing struct {
+	cells []Cell
+	mask  uint64
+	size  int
+	// used is number of cells with key or tombstone, limit is maximum of used cells by load factor
+	used       int
+	limit      int
+	loadFactor float64
+	// account of cells, old and new cells are both accounted during rehash
+	account *memory.Account
+}
+
+func (hashMap *HashTableWithQuadraticProbing) New() *HashTableWithQuadraticProbing {
+	return hashMap.NewWithLoadFactor(defaultCapacity, defaultLoadFactor)
+}
+
+// NewWithLoadFactor makes table with capacity rounded up to power of two, table is rehashed when used cells exceed
+// loadFactor (in (0, 1)) of capacity
+func (hashMap *HashTableWithQuadraticProbing) NewWithLoadFactor(capacity int,
+	loadFactor float64) *HashTableWithQuadraticProbing {
+	if loadFactor <= 0 || loadFactor >= 1 {
+		loadFactor = defaultLoadFactor
+	}
+	length := defaultCapacity
+	for length < capacity {
+		length <<= 1
+	}
+	// at least one cell stays empty, so every probe sequence ends
+	limit := int(float64(length) * loadFactor)
+	if limit >= length {
+		limit = length - 1
+	}
+	return &HashTableWithQuadraticProbing{
+		cells:      make([]Cell, length),
+		mask:       uint64(length - 1),
+		limit:      limit,
+		loadFactor: loadFactor,
+	}
+}
+
+// NewWithTracker makes table of NewWithLoadFactor reporting memory of its cells to tracker
+func (hashMap *HashTableWithQuadraticProbing) NewWithTracker(capacity int, loadFactor float64,
+	tracker *memory.Tracker) *HashTableWithQuadraticProbing {
+	result := hashMap.NewWithLoadFactor(capacity, loadFactor)
+	result.account = new(memory.Account).New(tracker)
+	result.account.Alloc(int64(len(result.cells)) * CellBytes)
+	return result
+}
+
+var _ memory.Accounted = (*HashTableWithQuadraticProbing)(nil)
+
+func (hashMap *HashTableWithQuadraticProbing) Err() error {
+	return hashMap.account.Err()
+}
+
+func (hashMap *HashTableWithQuadraticProbing) Release() {
+	hashMap.account.Release()
+}
+
+func (hashMap *HashTableWithQuadraticProbing) Size() int {
+	return hashMap.size
+}
+
+func (hashMap *HashTableWithQuadraticProbing) Capacity() int {
+	return len(hashMap.cells)
+}
+
+func (hashMap *HashTableWithQuadraticProbing) ContainsKey(key string) bool {
+	return hashMap.Get(key) != nil
+}
+
+// find returns cell of key (-1 if there is no key) and the first free cell of probe sequence of key
+func (hashMap *HashTableWithQuadraticProbing) find(key string, hash uint64) (int, int) {
+	free := -1
+	cell := hash & hashMap.mask
+	for probe := uint64(1); probe <= uint64(len(hashMap.cells)); probe++ {
+		current := &hashMap.cells[cell]
+		switch current.state {
+		case Null:
+			if free < 0 {
+				free = int(cell)
+			}
+			return -1, free
+		case Deleted:
+			if free < 0 {
+				free = int(cell)
+			}
+		case Value:
+			if current.Key == key {
+				return int(cell), free
+			}
+		}
+		// make triangular probing
+		cell = (cell + probe) & hashMap.mask
+	}
+	return -1, free
+}
+
+func (hashMap *HashTableWithQuadraticProbing) Get(key string) *Cell {
+	if key == "" {
+		return nil
+	}
+	if cell, _ := hashMap.find(key, v1.HashStringKey(key)); cell >= 0 {
+		return &hashMap.cells[cell]
+	}
+	return nil
+}
+
+// Put sets value of key
+func (hashMap *HashTableWithQuadraticProbing) Put(key string, value int) {
+	hashMap.update(key, value, false)
+}
+
+// Add adds value to aggregate of key
+func (hashMap *HashTableWithQuadraticProbing) Add(key string, value int) {
+	hashMap.update(key, value, true)
+}
+
+func (hashMap *HashTableWithQuadraticProbing) update(key string, value int, add bool) {
+	if key == "" {
+		return
+	}
+
+	hash := v1.HashStringKey(key)
+	cell, free := hashMap.find(key, hash)
+	if cell >= 0 {
+		if add {
+			value += hashMap.cells[cell].Value
+		}
+		hashMap.cells[cell].Value = value
+		return
+	}
+
+	if hashMap.cells[free].state == Null {
+		// tombstone is reused for free, new cell is used only under load factor
+		if hashMap.used+1 > hashMap.limit {
+			hashMap.rehash()
+			_, free = hashMap.find(key, hash)
+		}
+		hashMap.used++
+	}
+	hashMap.cells[free] = Cell{Key: key, Value: value, state: Value}
+	hashMap.size++
+}
+
+func (hashMap *HashTableWithQuadraticProbing) rehash() {
+	capacity := len(hashMap.cells)
+	if hashMap.size+1 > hashMap.limit/2 {
+		capacity *= 2
+	}
+	oldCells := hashMap.cells
+	account := hashMap.account
+	*hashMap = *hashMap.NewWithLoadFactor(capacity, hashMap.loadFactor)
+	hashMap.account = account
+	account.Alloc(int64(capacity) * CellBytes)
+	defer account.Free(int64(len(oldCells)) * CellBytes)
+	for idx := range oldCells {
+		if cell := &oldCells[idx]; cell.state == Value {
+			_, free := hashMap.find(cell.Key, v1.HashStringKey(cell.Key))
+			hashMap.cells[free] = *cell
+			hashMap.size++
+			hashMap.used++
+		}
+	}
+}
+
+func (hashMap *HashTableWithQuadraticProbing) Remove(key string) {
+	if key == "" {
+		return
+	}
+	if cell, _ := hashMap.find(key, v1.HashStringKey(key)); cell >= 0 {
+		// tombstone does not keep key alive
+		hashMap.cells[cell] = Cell{state: Deleted}
+		hashMap.size--
+	}
+}
+
+// Range calls fn for every key of the table
+func (hashMap *HashTableWithQuadraticProbing) Range(fn func(key string, value int)) {
+	for idx := range hashMap.cells {
+		if cell := &hashMap.cells[idx]; cell.state == Value {
+			fn(cell.Key, cell.Value)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package quadratic_probing
+
+import (
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTriangularProbingVisitsAllCells(t *testing.T) {
+	// keys of the same home cell share the whole probe sequence
+	hashMap := new(HashTableWithQuadraticProbing).NewWithLoadFactor(16, 0.99)
+	keys := make([]string, 0, 15)
+	for idx := 0; len(keys) < 15; idx++ {
+		if key := strconv.Itoa(idx); v1.HashStringKey(key)&hashMap.mask == 0 {
+			keys = append(keys, key)
+		}
+	}
+	for idx, key := range keys {
+		hashMap.Put(key, idx)
+	}
+	require.Equal(t, 16, hashMap.Capacity())
+	for idx, key := range keys {
+		require.Equal(t, idx, hashMap.Get(key).Value)
+	}
+}
+
+func TestTombstoneIsReused(t *testing.T) {
+	hashMap := new(HashTableWithQuadraticProbing).New()
+	for idx := 0; idx < 5; idx++ {
+		hashMap.Put(strconv.Itoa(idx), idx)
+	}
+	hashMap.Remove("3")
+	require.Nil(t, hashMap.Get("3"))
+	require.Equal(t, 5, hashMap.used)
+
+	hashMap.Put("3", 30)
+	require.Equal(t, 5, hashMap.used)
+	require.Equal(t, 5, hashMap.Size())
+	require.Equal(t, 30, hashMap.Get("3").Value)
+}
+
+func TestTombstoneRehash(t *testing.T) {
+	hashMap := new(HashTableWithQuadraticProbing).NewWithLoadFactor(16, 0.75)
+	require.Equal(t, 12, hashMap.limit)
+
+	// churn of short living keys leaves tombstones, rehash drops them in place
+	rehashed := false
+	for idx := 0; idx < 1000; idx++ {
+		key := "key-" + strconv.Itoa(idx)
+		used := hashMap.used
+		hashMap.Put(key, idx)
+		if hashMap.used <= used {
+			rehashed = true
+		}
+		if idx >= 2 {
+			hashMap.Remove("key-" + strconv.Itoa(idx-2))
+		}
+		require.Equal(t, 16, hashMap.Capacity())
+		require.LessOrEqual(t, hashMap.used, hashMap.limit)
+	}
+	require.True(t, rehashed)
+	require.Equal(t, 2, hashMap.Size())
+	require.Equal(t, 998, hashMap.Get("key-998").Value)
+	require.Equal(t, 999, hashMap.Get("key-999").Value)
+
+	// live keys over half of limit grow table
+	for idx := 0; idx < 12; idx++ {
+		hashMap.Put(strconv.Itoa(idx), idx)
+	}
+	require.Equal(t, 32, hashMap.Capacity())
+	require.Equal(t, 14, hashMap.Size())
+}
+
+func TestHashMapMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("table", 0)
+	hashMap := new(HashTableWithQuadraticProbing).NewWithTracker(8, 0.75, tracker)
+	require.Equal(t, 8*CellBytes, tracker.Used())
+	for idx := 0; idx < 100; idx++ {
+		hashMap.Put(strconv.Itoa(idx), idx)
+	}
+	require.Equal(t, int64(hashMap.Capacity())*CellBytes, tracker.Used())
+	// old and new cells at the moment of last rehash
+	require.Equal(t, int64(hashMap.Capacity())*CellBytes*3/2, tracker.Peak())
+	require.NoError(t, hashMap.Err())
+	hashMap.Release()
+	require.Zero(t, tracker.Used())
+
+	limited := new(HashTableWithQuadraticProbing).NewWithTracker(8, 0.75, new(memory.Tracker).New("table", 64*CellBytes))
+	for idx := 0; idx < 100; idx++ {
+		limited.Put(strconv.Itoa(idx), idx)
+	}
+	require.ErrorIs(t, limited.Err(), memory.ErrMemoryLimitExceeded)
+}
@@ -0,0 +1,173 @@
+package hashmap
+
+import (
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
+	"sync"
+)
+
+/*
+ShardedHashTable implementation - a set of small hash tables, each with its own mutex.
+Shard is defined by top bits of key hash (hash table inside shard uses low bits), so keys are spread
+between shards evenly only if data distributed evenly by keys - hot key makes its shard hot.
+*/
+
+const DefaultBitsForShard = 4
+
+type ShardStats struct {
+	// Acquisitions is number of times lock of shard been taken
+	Acquisitions int
+	// Contended is number of acquisitions when lock been already taken by other goroutine
+	Contended int
+}
+
+type shard struct {
+	mutex sync.Mutex
+	table *v1.HashTableWithLinearProbing
+	stats ShardStats
+}
+
+type ShardedHashTable struct {
+	shards       []shard
+	bitsForShard int
+}
+
+func (hashMap *ShardedHashTable) New() *ShardedHashTable {
+	return hashMap.NewWithShards(DefaultBitsForShard)
+}
+
+// NewWithShards makes table of 2^bitsForShard shards
+func (hashMap *ShardedHashTable) NewWithShards(bitsForShard int) *ShardedHashTable {
+	shards := make([]shard, 1<<bitsForShard)
+	for idx := range shards {
+		shards[idx].table = new(v1.HashTableWithLinearProbing).New()
+	}
+	return &ShardedHashTable{shards: shards, bitsForShard: bitsForShard}
+}
+
+// NewWithTracker makes table of 2^bitsForShard shards reporting memory of their tables to tracker
+func (hashMap *ShardedHashTable) NewWithTracker(bitsForShard int, tracker *memory.Tracker) *ShardedHashTable {
+	shards := make([]shard, 1<<bitsForShard)
+	for idx := range shards {
+		shards[idx].table = new(v1.HashTableWithLinearProbing).NewWithTracker(tracker)
+	}
+	return &ShardedHashTable{shards: shards, bitsForShard: bitsForShard}
+}
+
+var _ memory.Accounted = (*ShardedHashTable)(nil)
+
+func (hashMap *ShardedHashTable) Err() error {
+	for idx := range hashMap.shards {
+		shard := &hashMap.shards[idx]
+		shard.mutex.Lock()
+		err := shard.table.Err()
+		shard.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hashMap *ShardedHashTable) Release() {
+	for idx := range hashMap.shards {
+		hashMap.shards[idx].table.Release()
+	}
+}
+
+func (hashMap *ShardedHashTable) getShard(key string) *shard {
+	if hashMap.bitsForShard == 0 {
+		return &hashMap.shards[0]
+	}
+	hash := v1.HashStringKey(key)
+	return &hashMap.shards[hash>>(64-hashMap.bitsForShard)]
+}
+
+func (shard *shard) lock() {
+	if !shard.mutex.TryLock() {
+		shard.mutex.Lock()
+		shard.stats.Contended++
+	}
+	shard.stats.Acquisitions++
+}
+
+// Add adds value to aggregate of key, key is inserted if it does not exist
+func (hashMap *ShardedHashTable) Add(key string, value int) {
+	if key == "" {
+		return
+	}
+
+	shard := hashMap.getShard(key)
+	shard.lock()
+	defer shard.mutex.Unlock()
+
+	if cell := shard.table.Get(key); cell != nil {
+		cell.Value += value
+		return
+	}
+	shard.table.Put(key, value)
+}
+
+func (hashMap *ShardedHashTable) Put(key string, value int) {
+	if key == "" {
+		return
+	}
+
+	shard := hashMap.getShard(key)
+	shard.lock()
+	defer shard.mutex.Unlock()
+
+	shard.table.Put(key, value)
+}
+
+func (hashMap *ShardedHashTable) Get(key string) (int, bool) {
+	if key == "" {
+		return 0, false
+	}
+
+	shard := hashMap.getShard(key)
+	shard.lock()
+	defer shard.mutex.Unlock()
+
+	if cell := shard.table.Get(key); cell != nil {
+		return cell.Value, true
+	}
+	return 0, false
+}
+
+func (hashMap *ShardedHashTable) Size() int {
+	size := 0
+	for idx := range hashMap.shards {
+		shard := &hashMap.shards[idx]
+		shard.mutex.Lock()
+		size += shard.table.Size()
+		shard.mutex.Unlock()
+	}
+	return size
+}
+
+// Range calls fn for every key, shard is locked while its keys are iterated
+func (hashMap *ShardedHashTable) Range(fn func(key string, value int)) {
+	for idx := range hashMap.shards {
+		shard := &hashMap.shards[idx]
+		shard.mutex.Lock()
+		for _, cell := range shard.table.Cells {
+			if cell.Key != "" {
+				fn(cell.Key, cell.Value)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+}
+
+// Stats returns lock statistics of every shard
+func (hashMap *ShardedHashTable) Stats() []ShardStats {
+	stats := make([]ShardStats, len(hashMap.shards))
+	for idx := range hashMap.shards {
+		shard := &hashMap.shards[idx]
+		shard.mutex.Lock()
+		stats[idx] = shard.stats
+		shard.mutex.Unlock()
+	}
+	return stats
+}
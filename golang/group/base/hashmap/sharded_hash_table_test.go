@@ -0,0 +1,95 @@
+package hashmap
+
+import (
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedHashTable(t *testing.T) {
+	hashTable := new(ShardedHashTable).New()
+
+	hashTable.Put("1", 1)
+	hashTable.Put("2", 2)
+	hashTable.Add("2", 2)
+	hashTable.Add("3", 3)
+
+	value, ok := hashTable.Get("2")
+	require.True(t, ok)
+	require.Equal(t, 4, value)
+	value, ok = hashTable.Get("3")
+	require.True(t, ok)
+	require.Equal(t, 3, value)
+	_, ok = hashTable.Get("test")
+	require.False(t, ok)
+
+	require.Equal(t, 3, hashTable.Size())
+}
+
+func TestShardedHashTableConcurrentAdd(t *testing.T) {
+	hashTable := new(ShardedHashTable).NewWithShards(3)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				hashTable.Add("key-"+strconv.Itoa(i%100), 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	keys := 0
+	hashTable.Range(func(key string, value int) {
+		require.Equal(t, 80, value)
+		keys++
+	})
+	require.Equal(t, 100, keys)
+
+	acquisitions := 0
+	for _, stats := range hashTable.Stats() {
+		acquisitions += stats.Acquisitions
+	}
+	require.Equal(t, 8000, acquisitions)
+}
+
+func TestShardedHashTableMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("table", 0)
+	hashTable := new(ShardedHashTable).NewWithTracker(2, tracker)
+	initial := tracker.Used()
+	require.Positive(t, initial)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				hashTable.Add(strconv.Itoa(w*1000+i), 1)
+			}
+		}(w)
+	}
+	wg.Wait()
+	cells := 0
+	for idx := range hashTable.shards {
+		cells += len(hashTable.shards[idx].table.Cells)
+	}
+	require.Equal(t, int64(cells)*v1.CellBytes, tracker.Used())
+	require.NoError(t, hashTable.Err())
+
+	hashTable.Release()
+	require.Zero(t, tracker.Used())
+
+	hashTable = new(ShardedHashTable).NewWithTracker(2, new(memory.Tracker).New("table", initial))
+	for i := 0; i < 1000; i++ {
+		hashTable.Add(strconv.Itoa(i), 1)
+	}
+	require.ErrorIs(t, hashTable.Err(), memory.ErrMemoryLimitExceeded)
+	require.Equal(t, 1000, hashTable.Size())
+}
@@ -2,6 +2,7 @@ package two_level
 
 import (
 	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
 )
 
 const BitsForBucket = 8
@@ -10,12 +11,39 @@ const MaxBucket = NumBuckets - 1
 
 type TwoLevelHashMap struct {
 	Buckets []*v1.HashTableWithLinearProbing
+	tracker *memory.Tracker
 }
 
 func (hashMap *TwoLevelHashMap) New() *TwoLevelHashMap {
 	return hashMap.hashMapWithCapacity()
 }
 
+// NewWithTracker makes table reporting memory of its buckets to tracker
+func (hashMap *TwoLevelHashMap) NewWithTracker(tracker *memory.Tracker) *TwoLevelHashMap {
+	result := hashMap.hashMapWithCapacity()
+	result.tracker = tracker
+	return result
+}
+
+var _ memory.Accounted = (*TwoLevelHashMap)(nil)
+
+func (hashMap *TwoLevelHashMap) Err() error {
+	for _, bucket := range hashMap.Buckets {
+		if bucket != nil && bucket.Err() != nil {
+			return bucket.Err()
+		}
+	}
+	return nil
+}
+
+func (hashMap *TwoLevelHashMap) Release() {
+	for _, bucket := range hashMap.Buckets {
+		if bucket != nil {
+			bucket.Release()
+		}
+	}
+}
+
 func (hashMap *TwoLevelHashMap) hashMapWithCapacity() *TwoLevelHashMap {
 	buckets := make([]*v1.HashTableWithLinearProbing, NumBuckets)
 	return &TwoLevelHashMap{Buckets: buckets}
@@ -29,7 +57,7 @@ func getBucket(key string, hashMap *TwoLevelHashMap) int {
 	hash := v1.HashStringKey(key)
 	bucket := getBucketFromHash(int(hash))
 	if hashMap.Buckets[bucket] == nil {
-		hashMap.Buckets[bucket] = new(v1.HashTableWithLinearProbing).New()
+		hashMap.Buckets[bucket] = new(v1.HashTableWithLinearProbing).NewWithTracker(hashMap.tracker)
 	}
 	return bucket
 }
@@ -51,7 +79,7 @@ func (hashMap *TwoLevelHashMap) Get(key string) *v1.Cell {
 	hash := v1.HashStringKey(key)
 	bucket := getBucketFromHash(int(hash))
 	if hashMap.Buckets[bucket] == nil {
-		hashMap.Buckets[bucket] = new(v1.HashTableWithLinearProbing).New()
+		hashMap.Buckets[bucket] = new(v1.HashTableWithLinearProbing).NewWithTracker(hashMap.tracker)
 	}
 	return hashMap.Buckets[bucket].Get(key)
 }
@@ -0,0 +1,102 @@
+package lookup_table
+
+import (
+	"group/base/scheduler"
+)
+
+/*
+LookupTable implementation - direct addressed aggregation table for small integer keys.
+
+Key is index of the cell, so there is no hash function, no collisions and no resize: table of uint8 keys has 256 cells,
+table of uint16 keys has 65536 cells. Presence of key is tracked by number of rows aggregated to the cell, so zero sum
+of existing key is not lost. Thread-local tables are merged by vector addition of cells.
+String keys of small cardinality can use it too when they are dictionary encoded (see golang/group/base/dictionary).
+*/
+
+type Key interface {
+	uint8 | uint16
+}
+
+type LookupTable[K Key] struct {
+	Values []int
+	Counts []int
+	size   int
+}
+
+func New[K Key]() *LookupTable[K] {
+	length := int(^K(0)) + 1
+	return &LookupTable[K]{Values: make([]int, length), Counts: make([]int, length)}
+}
+
+func (table *LookupTable[K]) Add(key K, value int) {
+	if table.Counts[key] == 0 {
+		table.size++
+	}
+	table.Values[key] += value
+	table.Counts[key]++
+}
+
+func (table *LookupTable[K]) Get(key K) (int, bool) {
+	return table.Values[key], table.Counts[key] > 0
+}
+
+func (table *LookupTable[K]) Size() int {
+	return table.size
+}
+
+// Range calls fn for every key in ascending order
+func (table *LookupTable[K]) Range(fn func(key K, value int)) {
+	for idx, count := range table.Counts {
+		if count > 0 {
+			fn(K(idx), table.Values[idx])
+		}
+	}
+}
+
+// Merge adds other table to the table by vector addition
+func (table *LookupTable[K]) Merge(other *LookupTable[K]) {
+	table.mergeRange(other, 0, len(table.Values))
+}
+
+func (table *LookupTable[K]) mergeRange(other *LookupTable[K], start int, end int) {
+	values, counts := table.Values[start:end], table.Counts[start:end]
+	otherValues, otherCounts := other.Values[start:end], other.Counts[start:end]
+	for idx := range values {
+		values[idx] += otherValues[idx]
+		counts[idx] += otherCounts[idx]
+	}
+}
+
+func (table *LookupTable[K]) recountSize() {
+	table.size = 0
+	for _, count := range table.Counts {
+		if count > 0 {
+			table.size++
+		}
+	}
+}
+
+// mergeChunk is number of cells merged by one task, small tables are merged by one task
+const mergeChunk = 1 << 12
+
+// ParallelMerge merges tables to the first one, ranges of cells are merged in parallel
+func ParallelMerge[K Key](tables []*LookupTable[K], options scheduler.Options) *LookupTable[K] {
+	if len(tables) == 0 {
+		return New[K]()
+	}
+	result := tables[0]
+	length := len(result.Values)
+	chunks := (length + mergeChunk - 1) / mergeChunk
+	scheduler.ForEach(chunks, options, func(chunk int) {
+		start := chunk * mergeChunk
+		end := start + mergeChunk
+		if end > length {
+			end = length
+		}
+		for _, table := range tables[1:] {
+			result.mergeRange(table, start, end)
+		}
+	})
+	result.recountSize()
+	return result
+}
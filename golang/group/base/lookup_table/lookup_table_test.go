@@ -0,0 +1,60 @@
+package lookup_table
+
+import (
+	"group/base/scheduler"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupTable(t *testing.T) {
+	table := New[uint8]()
+	require.Len(t, table.Values, 256)
+
+	table.Add(1, 1)
+	table.Add(2, 2)
+	table.Add(2, 2)
+	table.Add(255, 0)
+
+	value, ok := table.Get(2)
+	require.True(t, ok)
+	require.Equal(t, 4, value)
+	// zero sum of existing key is not lost
+	value, ok = table.Get(255)
+	require.True(t, ok)
+	require.Equal(t, 0, value)
+	_, ok = table.Get(3)
+	require.False(t, ok)
+	require.Equal(t, 3, table.Size())
+
+	var keys []uint8
+	table.Range(func(key uint8, value int) {
+		keys = append(keys, key)
+	})
+	require.Equal(t, []uint8{1, 2, 255}, keys)
+
+	require.Len(t, New[uint16]().Values, 1<<16)
+}
+
+func TestParallelMerge(t *testing.T) {
+	tables := make([]*LookupTable[uint16], 4)
+	expected := New[uint16]()
+	for idx := range tables {
+		tables[idx] = New[uint16]()
+		for key := idx; key < 1<<16; key += 7 {
+			tables[idx].Add(uint16(key), key)
+			expected.Add(uint16(key), key)
+		}
+	}
+
+	result := ParallelMerge(tables, scheduler.Options{Parallelism: 4})
+	require.Equal(t, expected.Values, result.Values)
+	require.Equal(t, expected.Counts, result.Counts)
+	require.Equal(t, expected.Size(), result.Size())
+
+	sequential := New[uint16]()
+	sequential.Add(7, 1)
+	sequential.Merge(expected)
+	value, _ := sequential.Get(7)
+	require.Equal(t, 1+7, value)
+}
@@ -0,0 +1,71 @@
+package memory
+
+import "sync/atomic"
+
+// Accounted is structure (table, tree, buffer) made with tracker, it reports its memory through Account:
+//   - Err returns the first error of memory accounting as ErrMemoryLimitExceeded, structure keeps working anyway,
+//     so caller checks error when it wants (as example after every batch);
+//   - Release returns memory of structure to tracker, structure must not be used after it.
+type Accounted interface {
+	Err() error
+	Release()
+}
+
+// Account is memory of one structure reported to tracker - it keeps bytes in use, so structure can return all of
+// them at once, and the first error of accounting, so structure keeps working and caller checks error when it wants.
+// Account is safe for concurrent use, zero account (or account of nil tracker) does nothing.
+type Account struct {
+	tracker *Tracker
+	used    atomic.Int64
+	err     atomic.Pointer[error]
+}
+
+// New makes account reporting to tracker
+func (account *Account) New(tracker *Tracker) *Account {
+	return &Account{tracker: tracker}
+}
+
+func (account *Account) Alloc(bytes int64) {
+	if account == nil || account.tracker == nil {
+		return
+	}
+	account.used.Add(bytes)
+	if err := account.tracker.Alloc(bytes); err != nil {
+		account.err.CompareAndSwap(nil, &err)
+	}
+}
+
+func (account *Account) Free(bytes int64) {
+	if account == nil || account.tracker == nil {
+		return
+	}
+	account.used.Add(-bytes)
+	account.tracker.Free(bytes)
+}
+
+// Err returns the first error of accounting as ErrMemoryLimitExceeded
+func (account *Account) Err() error {
+	if account == nil {
+		return nil
+	}
+	if err := account.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// Used returns number of bytes in use by the structure
+func (account *Account) Used() int64 {
+	if account == nil {
+		return 0
+	}
+	return account.used.Load()
+}
+
+// Release returns all bytes in use to tracker
+func (account *Account) Release() {
+	if account == nil || account.tracker == nil {
+		return
+	}
+	account.tracker.Free(account.used.Swap(0))
+}
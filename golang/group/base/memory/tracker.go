@@ -0,0 +1,140 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Tracker accounts memory of aggregation state - tables, arenas and buffers report every allocation and release.
+
+Trackers make a tree: query tracker has tracker of every worker as child, allocation reported to worker tracker
+is accounted in all its ancestors, so every level has its own limit (not limited if limit is not positive).
+When allocation makes any tracker of the chain exceed its limit, Alloc returns typed error (LimitError matching
+ErrMemoryLimitExceeded), caller decides what to do: give up the query, spill state to disk or free something.
+Memory is accounted even if limit is exceeded, since caller has allocated it already.
+Peak of used memory is kept for every tracker.
+
+Methods of nil tracker do nothing, so structures without tracker do not pay for accounting.
+*/
+
+var ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+
+// LimitError is error of allocation exceeding limit of tracker
+type LimitError struct {
+	Tracker string
+	Limit   int64
+	Used    int64
+}
+
+func (err *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s uses %d bytes of %d", ErrMemoryLimitExceeded, err.Tracker, err.Used, err.Limit)
+}
+
+func (err *LimitError) Is(target error) bool {
+	return target == ErrMemoryLimitExceeded
+}
+
+type Tracker struct {
+	name   string
+	limit  int64
+	parent *Tracker
+	used   atomic.Int64
+	peak   atomic.Int64
+
+	mutex    sync.Mutex
+	children []*Tracker
+}
+
+// New makes root tracker, limit is number of bytes (not limited if it is not positive)
+func (tracker *Tracker) New(name string, limit int64) *Tracker {
+	return &Tracker{name: name, limit: limit}
+}
+
+// Child makes tracker accounting its allocations in the tracker as well
+func (tracker *Tracker) Child(name string, limit int64) *Tracker {
+	if tracker == nil {
+		return new(Tracker).New(name, limit)
+	}
+	child := &Tracker{name: tracker.name + "/" + name, limit: limit, parent: tracker}
+	tracker.mutex.Lock()
+	tracker.children = append(tracker.children, child)
+	tracker.mutex.Unlock()
+	return child
+}
+
+// Alloc accounts bytes in the tracker and all its ancestors, error is returned if any of limits is exceeded
+func (tracker *Tracker) Alloc(bytes int64) error {
+	var err error
+	for current := tracker; current != nil; current = current.parent {
+		used := current.used.Add(bytes)
+		for peak := current.peak.Load(); used > peak && !current.peak.CompareAndSwap(peak, used); {
+			peak = current.peak.Load()
+		}
+		if err == nil && current.limit > 0 && used > current.limit {
+			err = &LimitError{Tracker: current.name, Limit: current.limit, Used: used}
+		}
+	}
+	return err
+}
+
+// Free releases bytes in the tracker and all its ancestors
+func (tracker *Tracker) Free(bytes int64) {
+	for current := tracker; current != nil; current = current.parent {
+		current.used.Add(-bytes)
+	}
+}
+
+func (tracker *Tracker) Name() string {
+	if tracker == nil {
+		return ""
+	}
+	return tracker.name
+}
+
+func (tracker *Tracker) Limit() int64 {
+	if tracker == nil {
+		return 0
+	}
+	return tracker.limit
+}
+
+// Used returns number of bytes in use
+func (tracker *Tracker) Used() int64 {
+	if tracker == nil {
+		return 0
+	}
+	return tracker.used.Load()
+}
+
+// Peak returns maximum of bytes been in use
+func (tracker *Tracker) Peak() int64 {
+	if tracker == nil {
+		return 0
+	}
+	return tracker.peak.Load()
+}
+
+// Report returns used, peak and limit of the tracker and all its descendants, one tracker per line
+func (tracker *Tracker) Report() string {
+	var builder strings.Builder
+	tracker.report(&builder)
+	return builder.String()
+}
+
+func (tracker *Tracker) report(builder *strings.Builder) {
+	if tracker == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(builder, "%s: used %d, peak %d, limit %d\n", tracker.name, tracker.Used(), tracker.Peak(),
+		tracker.limit)
+	tracker.mutex.Lock()
+	children := tracker.children
+	tracker.mutex.Unlock()
+	for _, child := range children {
+		child.report(builder)
+	}
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+	query := new(Tracker).New("query", 1000)
+	worker := query.Child("worker-0", 600)
+	other := query.Child("worker-1", 0)
+
+	require.NoError(t, worker.Alloc(500))
+	require.NoError(t, other.Alloc(300))
+	require.Equal(t, int64(800), query.Used())
+
+	// worker limit is exceeded first
+	err := worker.Alloc(200)
+	require.True(t, errors.Is(err, ErrMemoryLimitExceeded))
+	var limitErr *LimitError
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, "query/worker-0", limitErr.Tracker)
+	require.Equal(t, int64(700), limitErr.Used)
+	// memory is accounted anyway
+	require.Equal(t, int64(1000), query.Used())
+	worker.Free(200)
+
+	// query limit is exceeded by other worker without its own limit
+	err = other.Alloc(300)
+	require.ErrorIs(t, err, ErrMemoryLimitExceeded)
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, "query", limitErr.Tracker)
+	other.Free(300)
+
+	worker.Free(500)
+	other.Free(300)
+	require.Zero(t, query.Used())
+	require.Equal(t, int64(1100), query.Peak())
+	require.Equal(t, int64(700), worker.Peak())
+	require.Contains(t, query.Report(), "query/worker-1: used 0, peak 600, limit 0")
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	require.NoError(t, tracker.Alloc(100))
+	tracker.Free(100)
+	require.Zero(t, tracker.Used())
+	require.Empty(t, tracker.Report())
+	require.Equal(t, "worker", tracker.Child("worker", 0).Name())
+}
+
+func TestConcurrentAlloc(t *testing.T) {
+	query := new(Tracker).New("query", 0)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		worker := query.Child("worker", 0)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				_ = worker.Alloc(10)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int64(80000), query.Used())
+	require.Equal(t, int64(80000), query.Peak())
+}
+
+func TestAccount(t *testing.T) {
+	tracker := new(Tracker).New("table", 100)
+	account := new(Account).New(tracker)
+	account.Alloc(60)
+	require.NoError(t, account.Err())
+	account.Alloc(60)
+	require.ErrorIs(t, account.Err(), ErrMemoryLimitExceeded)
+	account.Free(60)
+	// the first error is kept
+	require.ErrorIs(t, account.Err(), ErrMemoryLimitExceeded)
+	require.Equal(t, int64(60), account.Used())
+	require.Equal(t, int64(60), tracker.Used())
+
+	account.Release()
+	require.Zero(t, account.Used())
+	require.Zero(t, tracker.Used())
+
+	var empty *Account
+	empty.Alloc(10)
+	require.NoError(t, empty.Err())
+	new(Account).New(nil).Alloc(10)
+}
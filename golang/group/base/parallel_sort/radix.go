@@ -0,0 +1,130 @@
+package parallel_sort
+
+import (
+	"sort"
+	"sync"
+)
+
+// radixSorter keeps state of one MSD radix sort: buffer of items and semaphore capping parallelism
+type radixSorter[T any] struct {
+	buffer []T
+	// digit returns byte of key at depth shifted by one (1..256), 0 if key is shorter than depth
+	digit     func(item T, depth int) int
+	less      func(a, b T) bool
+	maxDepth  int
+	semaphore chan struct{}
+	wg        sync.WaitGroup
+}
+
+// RadixSortStrings sorts items by string key in ascending order with MSD radix sort
+func RadixSortStrings[T any](items []T, key func(item T) string, options Options) {
+	sorter := &radixSorter[T]{
+		digit: func(item T, depth int) int {
+			value := key(item)
+			if depth >= len(value) {
+				return 0
+			}
+			return int(value[depth]) + 1
+		},
+		less: func(a, b T) bool {
+			return key(a) < key(b)
+		},
+		maxDepth: -1,
+	}
+	sorter.sort(items, options)
+}
+
+// RadixSortUint64 sorts items by uint64 key in ascending order with MSD radix sort
+func RadixSortUint64[T any](items []T, key func(item T) uint64, options Options) {
+	sorter := &radixSorter[T]{
+		digit: func(item T, depth int) int {
+			return int(key(item)>>(56-8*depth)&0xFF) + 1
+		},
+		less: func(a, b T) bool {
+			return key(a) < key(b)
+		},
+		maxDepth: 8,
+	}
+	sorter.sort(items, options)
+}
+
+// RadixSortInt64 sorts items by int64 key in ascending order with MSD radix sort
+func RadixSortInt64[T any](items []T, key func(item T) int64, options Options) {
+	// flipped sign bit makes order of uint64 same as order of int64
+	RadixSortUint64(items, func(item T) uint64 {
+		return uint64(key(item)) ^ (1 << 63)
+	}, options)
+}
+
+func (sorter *radixSorter[T]) sort(items []T, options Options) {
+	sorter.buffer = make([]T, len(items))
+	sorter.semaphore = make(chan struct{}, options.Workers()-1)
+	sorter.sortRange(items, 0, 0)
+	sorter.wg.Wait()
+}
+
+// sortRange sorts items (which are equal by depth first bytes of key) placed at offset of buffer
+func (sorter *radixSorter[T]) sortRange(items []T, offset int, depth int) {
+	if len(items) < minRadixSize || depth == sorter.maxDepth {
+		if depth != sorter.maxDepth {
+			sort.Slice(items, func(i, j int) bool {
+				return sorter.less(items[i], items[j])
+			})
+		}
+		return
+	}
+
+	// counting sort by byte at depth
+	var counts [257]int
+	for _, item := range items {
+		counts[sorter.digit(item, depth)]++
+	}
+	if counts[0] == len(items) {
+		// all keys ended, items are equal
+		return
+	}
+	for digit := 1; digit < 257; digit++ {
+		if counts[digit] == len(items) {
+			// common prefix - all items go to one bucket, there is nothing to move
+			sorter.sortRange(items, offset, depth+1)
+			return
+		}
+	}
+	var starts [258]int
+	for digit := 0; digit < 257; digit++ {
+		starts[digit+1] = starts[digit] + counts[digit]
+	}
+	positions := starts
+	buffer := sorter.buffer[offset : offset+len(items)]
+	for _, item := range items {
+		digit := sorter.digit(item, depth)
+		buffer[positions[digit]] = item
+		positions[digit]++
+	}
+	copy(items, buffer)
+
+	// keys of bucket 0 ended, they are equal; other buckets are sorted by the next byte
+	for digit := 1; digit < 257; digit++ {
+		start, end := starts[digit], starts[digit+1]
+		if end-start < 2 {
+			continue
+		}
+		bucket := items[start:end]
+		bucketOffset := offset + start
+		if len(bucket) >= minParallelSize {
+			select {
+			case sorter.semaphore <- struct{}{}:
+				// sort big bucket on its own goroutine while there is free slot
+				sorter.wg.Add(1)
+				go func() {
+					defer sorter.wg.Done()
+					sorter.sortRange(bucket, bucketOffset, depth+1)
+					<-sorter.semaphore
+				}()
+				continue
+			default:
+			}
+		}
+		sorter.sortRange(bucket, bucketOffset, depth+1)
+	}
+}
@@ -0,0 +1,178 @@
+package parallel_sort
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+)
+
+/*
+Parallel sorts for sort-based aggregation. Items are sorted in place, so sorted slice is consumed by group-boundary
+detection (Groups) as is - every group is subslice of sorted items, nothing is copied.
+
+Sample sort (any comparable keys):
+- sample of items is sorted and parallelism-1 splitters are taken from it;
+- every worker counts items of its chunk per bucket (bucket is range between splitters), prefix sums of counts
+  give every worker its own place in every bucket, so items are scattered to buffer without synchronization;
+- buckets are sorted in parallel and copied back.
+
+MSD radix sort (strings and integer keys):
+- items are distributed by counting sort on byte of key (starting from the most significant one) to buckets,
+  every bucket is sorted recursively by the next byte, small buckets are sorted by comparison;
+- buckets of the first byte are sorted in parallel.
+Parallelism is capped by Options.Parallelism.
+*/
+
+const (
+	// minParallelSize is size of slice sorted on one goroutine
+	minParallelSize = 1 << 12
+	// oversampling is number of sample items per bucket
+	oversampling = 32
+	// minRadixSize is size of bucket sorted by comparison instead of next radix pass
+	minRadixSize = 64
+)
+
+type Options struct {
+	// Parallelism is maximum number of goroutines sorting at once, all cores are used if it is not positive
+	Parallelism int
+}
+
+func DefaultOptions() Options {
+	return Options{Parallelism: runtime.NumCPU()}
+}
+
+func (options Options) Workers() int {
+	if options.Parallelism <= 0 {
+		return runtime.NumCPU()
+	}
+	return options.Parallelism
+}
+
+// forEach runs fn for every task on at most workers goroutines
+func forEach(tasks int, workers int, fn func(task int)) {
+	if workers > tasks {
+		workers = tasks
+	}
+	if workers <= 1 {
+		for task := 0; task < tasks; task++ {
+			fn(task)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	next := 0
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mutex.Lock()
+				task := next
+				next++
+				mutex.Unlock()
+				if task >= tasks {
+					return
+				}
+				fn(task)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// SampleSort sorts items by less in parallel
+func SampleSort[T any](items []T, less func(a, b T) bool, options Options) {
+	workers := options.Workers()
+	if workers <= 1 || len(items) < minParallelSize {
+		sort.Slice(items, func(i, j int) bool {
+			return less(items[i], items[j])
+		})
+		return
+	}
+
+	// splitters from sorted sample
+	sampleSize := oversampling * workers
+	stride := len(items) / sampleSize
+	sample := make([]T, 0, sampleSize)
+	for idx := 0; idx < len(items) && len(sample) < sampleSize; idx += stride {
+		sample = append(sample, items[idx])
+	}
+	sort.Slice(sample, func(i, j int) bool {
+		return less(sample[i], sample[j])
+	})
+	splitters := make([]T, 0, workers-1)
+	for bucket := 1; bucket < workers; bucket++ {
+		splitters = append(splitters, sample[bucket*len(sample)/workers])
+	}
+	bucketOf := func(item T) int {
+		// first splitter greater than item
+		return sort.Search(len(splitters), func(i int) bool {
+			return less(item, splitters[i])
+		})
+	}
+
+	// count items of every chunk per bucket
+	chunkSize := (len(items) + workers - 1) / workers
+	chunks := (len(items) + chunkSize - 1) / chunkSize
+	buckets := make([][]int32, chunks)
+	counts := make([][]int, chunks)
+	forEach(chunks, workers, func(chunk int) {
+		start, end := chunk*chunkSize, (chunk+1)*chunkSize
+		if end > len(items) {
+			end = len(items)
+		}
+		buckets[chunk] = make([]int32, end-start)
+		counts[chunk] = make([]int, workers)
+		for idx, item := range items[start:end] {
+			bucket := bucketOf(item)
+			buckets[chunk][idx] = int32(bucket)
+			counts[chunk][bucket]++
+		}
+	})
+
+	// offsets of every chunk in every bucket
+	bucketStarts := make([]int, workers+1)
+	offsets := make([][]int, chunks)
+	for chunk := range offsets {
+		offsets[chunk] = make([]int, workers)
+	}
+	position := 0
+	for bucket := 0; bucket < workers; bucket++ {
+		bucketStarts[bucket] = position
+		for chunk := 0; chunk < chunks; chunk++ {
+			offsets[chunk][bucket] = position
+			position += counts[chunk][bucket]
+		}
+	}
+	bucketStarts[workers] = position
+
+	// scatter to buffer, sort buckets and copy back
+	buffer := make([]T, len(items))
+	forEach(chunks, workers, func(chunk int) {
+		start := chunk * chunkSize
+		offset := offsets[chunk]
+		for idx, bucket := range buckets[chunk] {
+			buffer[offset[bucket]] = items[start+idx]
+			offset[bucket]++
+		}
+	})
+	forEach(workers, workers, func(bucket int) {
+		part := buffer[bucketStarts[bucket]:bucketStarts[bucket+1]]
+		sort.Slice(part, func(i, j int) bool {
+			return less(part[i], part[j])
+		})
+		copy(items[bucketStarts[bucket]:], part)
+	})
+}
+
+// Groups calls fn for every group of equal items of sorted items, group is subslice of items
+func Groups[T any](items []T, equal func(a, b T) bool, fn func(group []T)) {
+	start := 0
+	for idx := 1; idx <= len(items); idx++ {
+		if idx == len(items) || !equal(items[start], items[idx]) {
+			fn(items[start:idx])
+			start = idx
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package parallel_sort
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	key   string
+	value int64
+}
+
+// makeItems generates items with keys of cardinality distinct values, keys share common prefixes
+func makeItems(size int, cardinality int) []item {
+	random := rand.New(rand.NewSource(int64(size + cardinality)))
+	items := make([]item, size)
+	for idx := range items {
+		key := random.Intn(cardinality)
+		items[idx] = item{key: "key-" + strconv.Itoa(key), value: random.Int63() - random.Int63()}
+	}
+	// empty and short keys
+	if size > 2 {
+		items[0].key = ""
+		items[1].key = "k"
+	}
+	return items
+}
+
+// requireSameItems checks that sorted items are permutation of expected items
+func requireSameItems(t *testing.T, expected []item, actual []item, name string) {
+	canonical := func(items []item) []item {
+		items = append([]item(nil), items...)
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].key != items[j].key {
+				return items[i].key < items[j].key
+			}
+			return items[i].value < items[j].value
+		})
+		return items
+	}
+	require.Equal(t, canonical(expected), canonical(actual), name)
+}
+
+func byKey(a, b item) bool {
+	return a.key < b.key
+}
+
+func TestSorts(t *testing.T) {
+	for _, size := range []int{0, 1, 10, 1000, 50000} {
+		for _, cardinality := range []int{1, 8, 1000, 100000} {
+			for _, parallelism := range []int{1, 2, 3, 8} {
+				name := fmt.Sprintf("size %d cardinality %d parallelism %d", size, cardinality, parallelism)
+				options := Options{Parallelism: parallelism}
+
+				items := makeItems(size, cardinality)
+				SampleSort(items, byKey, options)
+				require.True(t, sort.SliceIsSorted(items, func(i, j int) bool {
+					return items[i].key < items[j].key
+				}), name)
+				requireSameItems(t, makeItems(size, cardinality), items, name)
+
+				items = makeItems(size, cardinality)
+				RadixSortStrings(items, func(item item) string {
+					return item.key
+				}, options)
+				require.True(t, sort.SliceIsSorted(items, func(i, j int) bool {
+					return items[i].key < items[j].key
+				}), name)
+				requireSameItems(t, makeItems(size, cardinality), items, name)
+
+				items = makeItems(size, cardinality)
+				RadixSortInt64(items, func(item item) int64 {
+					return item.value
+				}, options)
+				require.True(t, sort.SliceIsSorted(items, func(i, j int) bool {
+					return items[i].value < items[j].value
+				}), name)
+				requireSameItems(t, makeItems(size, cardinality), items, name)
+			}
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	items := makeItems(10000, 100)
+	RadixSortStrings(items, func(item item) string {
+		return item.key
+	}, Options{Parallelism: 4})
+
+	expected := make(map[string]int)
+	for _, item := range items {
+		expected[item.key]++
+	}
+	actual := make(map[string]int)
+	offset := 0
+	Groups(items, func(a, b item) bool {
+		return a.key == b.key
+	}, func(group []item) {
+		_, ok := actual[group[0].key]
+		require.False(t, ok, "group %s is split", group[0].key)
+		actual[group[0].key] = len(group)
+		// group is subslice of sorted items, not copy
+		require.Same(t, &items[offset], &group[0])
+		offset += len(group)
+	})
+	require.Equal(t, expected, actual)
+	require.Equal(t, len(items), offset)
+
+	Groups([]item{}, byKey, func(group []item) {
+		require.Fail(t, "no groups in empty slice")
+	})
+}
+
+func BenchmarkSorts(b *testing.B) {
+	for _, cardinality := range []int{8, 1000, 100000} {
+		items := makeItems(200000, cardinality)
+		sorted := make([]item, len(items))
+
+		b.Run(fmt.Sprintf("sort.Slice %d keys", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				copy(sorted, items)
+				sort.Slice(sorted, func(i, j int) bool {
+					return sorted[i].key < sorted[j].key
+				})
+			}
+		})
+		b.Run(fmt.Sprintf("SampleSort %d keys", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				copy(sorted, items)
+				SampleSort(sorted, byKey, DefaultOptions())
+			}
+		})
+		b.Run(fmt.Sprintf("RadixSortStrings %d keys", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				copy(sorted, items)
+				RadixSortStrings(sorted, func(item item) string {
+					return item.key
+				}, DefaultOptions())
+			}
+		})
+	}
+}
+
+func BenchmarkSortsWithParallelism(b *testing.B) {
+	items := makeItems(200000, 1000)
+	sorted := make([]item, len(items))
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		options := Options{Parallelism: parallelism}
+		b.Run(fmt.Sprintf("SampleSort parallelism %d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				copy(sorted, items)
+				SampleSort(sorted, byKey, options)
+			}
+		})
+		b.Run(fmt.Sprintf("RadixSortStrings parallelism %d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				copy(sorted, items)
+				RadixSortStrings(sorted, func(item item) string {
+					return item.key
+				}, options)
+			}
+		})
+		b.Run(fmt.Sprintf("RadixSortInt64 parallelism %d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				copy(sorted, items)
+				RadixSortInt64(sorted, func(item item) int64 {
+					return item.value
+				}, options)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package scheduler
+
+import (
+	"group/base/batch"
+	"group/base/buffer"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Morsel-driven scheduler - https://15721.courses.cs.cmu.edu/spring2016/papers/p743-leis.pdf
+
+Instead of spawning goroutine per data block (or splitting data for fixed number of jobs once) we start fixed pool
+of workers. Every worker owns queue of small data blocks (morsels) and its own thread-local aggregation state.
+When worker runs out of morsels it steals from the tail of other workers queues, so skewed (slow) morsels do not
+stall the whole pipeline on one worker.
+*/
+
+// Morsel is small chunk of data rows, unit of work for the scheduler
+type Morsel [][]string
+
+type Options struct {
+	// Parallelism is number of workers in the pool, all cores are used if it is not positive
+	Parallelism int
+	// MorselSize is number of rows in one morsel, default data block size is used if it is not positive
+	MorselSize int
+}
+
+func DefaultOptions() Options {
+	return Options{Parallelism: runtime.NumCPU()}
+}
+
+func (options Options) Workers() int {
+	if options.Parallelism <= 0 {
+		return runtime.NumCPU()
+	}
+	return options.Parallelism
+}
+
+type Stats struct {
+	// Morsels is number of morsels processed by every worker
+	Morsels []int
+	// Steals is number of morsels stolen by every worker from other workers
+	Steals []int
+}
+
+// MakeMorsels splits records (including csv caption) to morsels of options.MorselSize rows
+func MakeMorsels(records [][]string, options Options) ([]Morsel, error) {
+	dataBlocks, err := buffer.MakePartitioningWithBlockSize(records, options.MorselSize)
+	if err != nil {
+		return nil, err
+	}
+
+	morsels := make([]Morsel, 0, len(dataBlocks))
+	for _, block := range dataBlocks {
+		morsels = append(morsels, block.Read())
+	}
+	return morsels, nil
+}
+
+// MakeBatches splits records (including csv caption) to columnar batches of options.MorselSize rows,
+// batch is morsel of drivers consuming columns directly. Batches are decoded in parallel.
+func MakeBatches(records [][]string, schema batch.Schema, options Options) ([]*batch.Batch, error) {
+	batchSize := options.MorselSize
+	if batchSize <= 0 {
+		batchSize = buffer.DataBlockSize
+	}
+	if len(records) <= 1 {
+		return nil, nil
+	}
+	// pass csv caption
+	rows := records[1:]
+
+	batches := make([]*batch.Batch, (len(rows)+batchSize-1)/batchSize)
+	errs := make([]error, len(batches))
+	ForEach(len(batches), options, func(task int) {
+		end := (task + 1) * batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		batches[task], errs[task] = batch.FromRecords(rows[task*batchSize:end], schema)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return batches, nil
+}
+
+type workQueue[M any] struct {
+	mutex   sync.Mutex
+	morsels []M
+}
+
+// pop takes morsel from the head of queue, used by owner of queue
+func (queue *workQueue[M]) pop() (M, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if len(queue.morsels) == 0 {
+		var empty M
+		return empty, false
+	}
+	morsel := queue.morsels[0]
+	queue.morsels = queue.morsels[1:]
+	return morsel, true
+}
+
+// steal takes morsel from the tail of queue, used by other workers
+func (queue *workQueue[M]) steal() (M, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	last := len(queue.morsels) - 1
+	if last < 0 {
+		var empty M
+		return empty, false
+	}
+	morsel := queue.morsels[last]
+	queue.morsels = queue.morsels[:last]
+	return morsel, true
+}
+
+func makeQueues[M any](morsels []M, workers int) []*workQueue[M] {
+	// every worker gets contiguous range of morsels to keep data locality
+	queues := make([]*workQueue[M], workers)
+	ratio := len(morsels) / workers
+	reminder := len(morsels) % workers
+	start := 0
+	for w := 0; w < workers; w++ {
+		end := start + ratio
+		if w < reminder {
+			end++
+		}
+		queues[w] = &workQueue[M]{morsels: morsels[start:end:end]}
+		start = end
+	}
+	return queues
+}
+
+// Run processes all morsels on pool of options.Workers() workers. Every worker gets its own state from newState,
+// process is never called concurrently for the same state. Returns states of all workers to merge them.
+// Morsel can be block of rows (Morsel) or any other unit of work as example columnar batch.
+func Run[S any, M any](morsels []M, options Options, newState func() S, process func(state S, morsel M)) ([]S, Stats) {
+	workers := options.Workers()
+	queues := makeQueues(morsels, workers)
+	states := make([]S, workers)
+	stats := Stats{Morsels: make([]int, workers), Steals: make([]int, workers)}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			state := newState()
+			for {
+				morsel, ok := queues[worker].pop()
+				if !ok {
+					morsel, ok = stealFrom(queues, worker)
+					if !ok {
+						// no new morsels appear after start, so all the work is done
+						break
+					}
+					stats.Steals[worker]++
+				}
+				process(state, morsel)
+				stats.Morsels[worker]++
+			}
+			states[worker] = state
+		}(w)
+	}
+	wg.Wait()
+
+	return states, stats
+}
+
+func stealFrom[M any](queues []*workQueue[M], worker int) (M, bool) {
+	for i := 1; i < len(queues); i++ {
+		victim := (worker + i) % len(queues)
+		if morsel, ok := queues[victim].steal(); ok {
+			return morsel, true
+		}
+	}
+	var empty M
+	return empty, false
+}
+
+// ForEach runs fn for every task in [0, tasks) on pool of options.Workers() workers
+func ForEach(tasks int, options Options, fn func(task int)) {
+	workers := options.Workers()
+	if workers > tasks {
+		workers = tasks
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				task := int(next.Add(1) - 1)
+				if task >= tasks {
+					return
+				}
+				fn(task)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func makeRecords(rows int) [][]string {
+	records := [][]string{{"caption"}}
+	for i := 0; i < rows; i++ {
+		records = append(records, []string{strconv.Itoa(i)})
+	}
+	return records
+}
+
+type sumState struct {
+	rows int
+	sum  int
+}
+
+func TestRunProcessesEveryMorselOnce(t *testing.T) {
+	records := makeRecords(1000)
+
+	for _, parallelism := range []int{1, 3, 8} {
+		options := Options{Parallelism: parallelism, MorselSize: 7}
+		morsels, err := MakeMorsels(records, options)
+		require.NoError(t, err)
+		require.Len(t, morsels, 143)
+
+		states, stats := Run(morsels, options, func() *sumState {
+			return &sumState{}
+		}, func(state *sumState, morsel Morsel) {
+			for _, record := range morsel {
+				value, _ := strconv.Atoi(record[0])
+				state.sum += value
+				state.rows++
+			}
+		})
+		require.Len(t, states, parallelism)
+
+		rows, sum, processed := 0, 0, 0
+		for w, state := range states {
+			rows += state.rows
+			sum += state.sum
+			processed += stats.Morsels[w]
+		}
+		require.Equal(t, 1000, rows)
+		require.Equal(t, 999*1000/2, sum)
+		require.Equal(t, len(morsels), processed)
+	}
+}
+
+func TestRunStealsSkewedWork(t *testing.T) {
+	options := Options{Parallelism: 4, MorselSize: 1}
+	morsels, err := MakeMorsels(makeRecords(64), options)
+	require.NoError(t, err)
+
+	// all slow morsels belong to the first worker
+	_, stats := Run(morsels, options, func() *sumState {
+		return &sumState{}
+	}, func(state *sumState, morsel Morsel) {
+		value, _ := strconv.Atoi(morsel[0][0])
+		if value < 16 {
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	steals := 0
+	for _, stolen := range stats.Steals {
+		steals += stolen
+	}
+	require.Positive(t, steals)
+	require.Less(t, stats.Morsels[0], 16)
+}
+
+func TestForEach(t *testing.T) {
+	var sum atomic.Int64
+	ForEach(100, Options{Parallelism: 4}, func(task int) {
+		sum.Add(int64(task))
+	})
+	require.Equal(t, int64(99*100/2), sum.Load())
+
+	ForEach(0, Options{Parallelism: 4}, func(task int) {
+		t.Fatal("no tasks expected")
+	})
+}
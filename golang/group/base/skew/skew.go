@@ -0,0 +1,141 @@
+package skew
+
+import (
+	"math"
+	"sort"
+)
+
+/*
+Skew detection for partitioning strategies.
+
+Partitioning serves every key by exactly one worker, so a key holding most of the rows (Android in phones_data.csv)
+makes one worker do most of the work. Detection takes sample of keys and finds heavy hitters - keys with share in
+sample above threshold. Rows of heavy hitter are salted over several partitions (sub-partitions of the key) and
+partial aggregates of the key are combined in the end.
+*/
+
+const (
+	defaultSampleSize = 1024
+)
+
+type Options struct {
+	// SampleSize is number of keys sampled to detect heavy hitters
+	SampleSize int
+	// Threshold is share of sample above which key is heavy hitter, 0 means fair share of one partition
+	Threshold float64
+}
+
+func DefaultOptions() Options {
+	return Options{SampleSize: defaultSampleSize}
+}
+
+type HeavyHitter struct {
+	Key string
+	// Share of the key in sample
+	Share float64
+	// Salts is number of partitions rows of the key are spread over
+	Salts int
+}
+
+type Report struct {
+	Sampled      int
+	HeavyHitters []HeavyHitter
+	// Skew is load of the hottest partition relative to average load (1 - evenly distributed) without salting
+	Skew float64
+	// SaltedSkew is estimated load of the hottest partition relative to average load with salting
+	SaltedSkew float64
+
+	heavyHitters map[string]int
+}
+
+// SampleRows returns indexes of rows sampled with equal stride from [from, rows)
+func SampleRows(from int, rows int, sampleSize int) []int {
+	if rows <= from || sampleSize <= 0 {
+		return nil
+	}
+	stride := (rows - from) / sampleSize
+	if stride == 0 {
+		stride = 1
+	}
+	sample := make([]int, 0, sampleSize)
+	for row := from; row < rows && len(sample) < sampleSize; row += stride {
+		sample = append(sample, row)
+	}
+	return sample
+}
+
+// Detect finds heavy hitters in sample of keys for number of partitions, partitionOf maps key to its home partition
+func Detect(sample []string, partitions int, partitionOf func(key string) int, options Options) Report {
+	report := Report{Sampled: len(sample), Skew: 1, SaltedSkew: 1, heavyHitters: map[string]int{}}
+	if len(sample) == 0 || partitions <= 1 {
+		return report
+	}
+	threshold := options.Threshold
+	if threshold <= 0 {
+		threshold = 1 / float64(partitions)
+	}
+
+	counts := make(map[string]int)
+	for _, key := range sample {
+		counts[key]++
+	}
+
+	load := make([]float64, partitions)
+	saltedLoad := make([]float64, partitions)
+	for key, count := range counts {
+		share := float64(count) / float64(len(sample))
+		home := partitionOf(key)
+		load[home] += share
+		if share <= threshold {
+			saltedLoad[home] += share
+			continue
+		}
+		// spread key over as many partitions as fair shares it holds
+		salts := int(math.Ceil(share * float64(partitions)))
+		if salts > partitions {
+			salts = partitions
+		}
+		report.HeavyHitters = append(report.HeavyHitters, HeavyHitter{Key: key, Share: share, Salts: salts})
+		for salt := 0; salt < salts; salt++ {
+			saltedLoad[(home+salt)%partitions] += share / float64(salts)
+		}
+	}
+
+	sort.Slice(report.HeavyHitters, func(i, j int) bool {
+		if report.HeavyHitters[i].Share != report.HeavyHitters[j].Share {
+			return report.HeavyHitters[i].Share > report.HeavyHitters[j].Share
+		}
+		return report.HeavyHitters[i].Key < report.HeavyHitters[j].Key
+	})
+	for idx, heavyHitter := range report.HeavyHitters {
+		report.heavyHitters[heavyHitter.Key] = idx
+	}
+
+	report.Skew = maxLoad(load) * float64(partitions)
+	report.SaltedSkew = maxLoad(saltedLoad) * float64(partitions)
+	return report
+}
+
+func maxLoad(load []float64) float64 {
+	result := 0.0
+	for _, value := range load {
+		result = math.Max(result, value)
+	}
+	return result
+}
+
+// HeavyHitter returns index of key in HeavyHitters, false if key is not heavy hitter
+func (report *Report) HeavyHitter(key string) (int, bool) {
+	idx, ok := report.heavyHitters[key]
+	return idx, ok
+}
+
+// Partition returns partition of row with key - heavy hitter rows are spread over Salts partitions after home one
+// by salt (as example row number), other keys stay in home partition
+func (report *Report) Partition(key string, home int, salt int, partitions int) int {
+	idx, ok := report.heavyHitters[key]
+	if !ok {
+		return home
+	}
+	return (home + salt%report.HeavyHitters[idx].Salts) % partitions
+}
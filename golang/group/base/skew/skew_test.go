@@ -0,0 +1,67 @@
+package skew
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleRows(t *testing.T) {
+	require.Equal(t, []int{1, 4, 7}, SampleRows(1, 10, 3))
+	require.Equal(t, []int{1, 2, 3}, SampleRows(1, 4, 10))
+	require.Empty(t, SampleRows(1, 1, 10))
+}
+
+func TestDetect(t *testing.T) {
+	// one key takes 70% of rows, others are spread evenly
+	sample := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		if i%10 < 7 {
+			sample = append(sample, "hot")
+		} else {
+			sample = append(sample, "cold-"+strconv.Itoa(i%100))
+		}
+	}
+	partitionOf := func(key string) int {
+		if key == "hot" {
+			return 1
+		}
+		id, _ := strconv.Atoi(key[len("cold-"):])
+		return id % 4
+	}
+
+	report := Detect(sample, 4, partitionOf, DefaultOptions())
+	require.Equal(t, 1000, report.Sampled)
+	require.Len(t, report.HeavyHitters, 1)
+	require.Equal(t, HeavyHitter{Key: "hot", Share: 0.7, Salts: 3}, report.HeavyHitters[0])
+	require.Greater(t, report.Skew, 2.5)
+	require.Less(t, report.SaltedSkew, report.Skew)
+
+	idx, ok := report.HeavyHitter("hot")
+	require.True(t, ok)
+	require.Equal(t, 0, idx)
+	_, ok = report.HeavyHitter("cold-1")
+	require.False(t, ok)
+
+	// heavy hitter rows go round robin over partitions after home one
+	require.Equal(t, 1, report.Partition("hot", 1, 0, 4))
+	require.Equal(t, 2, report.Partition("hot", 1, 1, 4))
+	require.Equal(t, 3, report.Partition("hot", 1, 2, 4))
+	require.Equal(t, 1, report.Partition("hot", 1, 3, 4))
+	require.Equal(t, 2, report.Partition("cold-2", 2, 1, 4))
+}
+
+func TestDetectUniform(t *testing.T) {
+	sample := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		sample = append(sample, strconv.Itoa(i%100))
+	}
+	report := Detect(sample, 4, func(key string) int {
+		id, _ := strconv.Atoi(key)
+		return id % 4
+	}, DefaultOptions())
+	require.Empty(t, report.HeavyHitters)
+	require.InDelta(t, 1, report.Skew, 0.001)
+	require.Equal(t, report.Skew, report.SaltedSkew)
+}
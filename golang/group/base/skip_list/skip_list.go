@@ -0,0 +1,195 @@
+package skip_list
+
+import (
+	"group/base/memory"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+/*
+Concurrent skip list - lazy skip list of Herlihy, Lev, Luchangco and Shavit
+(https://people.csail.mit.edu/shanir/publications/LazySkipList.pdf) without removal of keys.
+
+Keys are kept in ascending order on level 0, every upper level links random subset (1/4) of nodes of the level below,
+so search goes down from the sparsest level in O(log n). Search and lookup take no locks. Insert of new key locks
+predecessors of the node on all its levels (bottom-up), validates that predecessors still point to the same
+successors and links node, node is visible for lookups when it is linked on all levels. Aggregate of existing key is
+updated by atomic add without locks. Keys are never removed from aggregation, so nodes are never marked deleted.
+*/
+
+const (
+	// MaxLevel is enough for 4^16 keys
+	MaxLevel = 16
+	// levelRatio is inverse probability of node to be on the next level
+	levelRatio = 4
+)
+
+type node struct {
+	key         string
+	value       atomic.Int64
+	next        []atomic.Pointer[node]
+	mutex       sync.Mutex
+	fullyLinked atomic.Bool
+}
+
+// NodeBytes is memory taken by node without its links, LinkBytes is memory of link of node on one level
+const (
+	NodeBytes = int64(unsafe.Sizeof(node{}))
+	LinkBytes = int64(unsafe.Sizeof(atomic.Pointer[node]{}))
+)
+
+type SkipList struct {
+	head    *node
+	size    atomic.Int64
+	account *memory.Account
+}
+
+func (skipList *SkipList) New() *SkipList {
+	head := &node{next: make([]atomic.Pointer[node], MaxLevel)}
+	head.fullyLinked.Store(true)
+	return &SkipList{head: head}
+}
+
+// NewWithTracker makes skip list reporting memory of its nodes to tracker
+func (skipList *SkipList) NewWithTracker(tracker *memory.Tracker) *SkipList {
+	result := skipList.New()
+	result.account = new(memory.Account).New(tracker)
+	result.account.Alloc(NodeBytes + MaxLevel*LinkBytes)
+	return result
+}
+
+var _ memory.Accounted = (*SkipList)(nil)
+
+func (skipList *SkipList) Err() error {
+	return skipList.account.Err()
+}
+
+func (skipList *SkipList) Release() {
+	skipList.account.Release()
+}
+
+func randomLevel() int {
+	level := 1
+	for level < MaxLevel && rand.Intn(levelRatio) == 0 {
+		level++
+	}
+	return level
+}
+
+// find fills predecessors and successors of key on every level, returns the highest level where key is found or -1
+func (skipList *SkipList) find(key string, preds *[MaxLevel]*node, succs *[MaxLevel]*node) int {
+	found := -1
+	pred := skipList.head
+	for level := MaxLevel - 1; level >= 0; level-- {
+		curr := pred.next[level].Load()
+		for curr != nil && curr.key < key {
+			pred = curr
+			curr = pred.next[level].Load()
+		}
+		if found == -1 && curr != nil && curr.key == key {
+			found = level
+		}
+		preds[level] = pred
+		succs[level] = curr
+	}
+	return found
+}
+
+// Add adds value to aggregate of key, it is safe for concurrent use
+func (skipList *SkipList) Add(key string, value int) {
+	var preds, succs [MaxLevel]*node
+	topLevel := randomLevel()
+	for {
+		if found := skipList.find(key, &preds, &succs); found != -1 {
+			succs[found].value.Add(int64(value))
+			return
+		}
+
+		// lock predecessors bottom-up and validate they still point to successors
+		highestLocked := -1
+		valid := true
+		var prevPred *node
+		for level := 0; valid && level < topLevel; level++ {
+			pred := preds[level]
+			if pred != prevPred {
+				pred.mutex.Lock()
+				highestLocked = level
+				prevPred = pred
+			}
+			valid = pred.next[level].Load() == succs[level]
+		}
+		if valid {
+			added := &node{key: key, next: make([]atomic.Pointer[node], topLevel)}
+			added.value.Store(int64(value))
+			for level := 0; level < topLevel; level++ {
+				added.next[level].Store(succs[level])
+			}
+			for level := 0; level < topLevel; level++ {
+				preds[level].next[level].Store(added)
+			}
+			added.fullyLinked.Store(true)
+			skipList.size.Add(1)
+			skipList.account.Alloc(NodeBytes + int64(topLevel)*LinkBytes)
+		}
+		unlock(&preds, highestLocked)
+		if valid {
+			return
+		}
+	}
+}
+
+// unlock unlocks predecessors locked up to level, the same predecessor of several levels is locked once
+func unlock(preds *[MaxLevel]*node, highestLocked int) {
+	var prevPred *node
+	for level := 0; level <= highestLocked; level++ {
+		if preds[level] != prevPred {
+			preds[level].mutex.Unlock()
+			prevPred = preds[level]
+		}
+	}
+}
+
+func (skipList *SkipList) Get(key string) (int, bool) {
+	var preds, succs [MaxLevel]*node
+	found := skipList.find(key, &preds, &succs)
+	if found == -1 || !succs[found].fullyLinked.Load() {
+		return 0, false
+	}
+	return int(succs[found].value.Load()), true
+}
+
+func (skipList *SkipList) ContainsKey(key string) bool {
+	_, ok := skipList.Get(key)
+	return ok
+}
+
+func (skipList *SkipList) Size() int {
+	return int(skipList.size.Load())
+}
+
+// Range calls fn for every key in ascending order, keys added concurrently may be missed
+func (skipList *SkipList) Range(fn func(key string, value int)) {
+	skipList.ascend(skipList.head.next[0].Load(), "", false, fn)
+}
+
+// RangeBetween calls fn for every key in [from, to) in ascending order
+func (skipList *SkipList) RangeBetween(from string, to string, fn func(key string, value int)) {
+	// descend to the last node before from
+	pred := skipList.head
+	for level := MaxLevel - 1; level >= 0; level-- {
+		for curr := pred.next[level].Load(); curr != nil && curr.key < from; curr = pred.next[level].Load() {
+			pred = curr
+		}
+	}
+	skipList.ascend(pred.next[0].Load(), to, true, fn)
+}
+
+func (skipList *SkipList) ascend(curr *node, to string, bounded bool, fn func(key string, value int)) {
+	for ; curr != nil && (!bounded || curr.key < to); curr = curr.next[0].Load() {
+		if curr.fullyLinked.Load() {
+			fn(curr.key, int(curr.value.Load()))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package skip_list
+
+import (
+	"fmt"
+	"group/base/memory"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipList(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	skipList := new(SkipList).New()
+	expected := make(map[string]int)
+	for op := 0; op < 20000; op++ {
+		key := fmt.Sprint(random.Intn(3000))
+		skipList.Add(key, op)
+		expected[key] += op
+	}
+	require.Equal(t, len(expected), skipList.Size())
+
+	keys := make([]string, 0, len(expected))
+	for key, value := range expected {
+		actual, ok := skipList.Get(key)
+		require.True(t, ok)
+		require.Equal(t, value, actual)
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	require.False(t, skipList.ContainsKey("missing"))
+
+	ranged := make([]string, 0, len(keys))
+	skipList.Range(func(key string, value int) {
+		require.Equal(t, expected[key], value)
+		ranged = append(ranged, key)
+	})
+	require.Equal(t, keys, ranged)
+
+	for _, bounds := range [][2]string{{"", "5"}, {"12", "13"}, {"250", "2500"}, {"5", "5"}, {"9", "a"}} {
+		between := make([]string, 0)
+		skipList.RangeBetween(bounds[0], bounds[1], func(key string, value int) {
+			between = append(between, key)
+		})
+		expectedBetween := make([]string, 0)
+		for _, key := range keys {
+			if key >= bounds[0] && key < bounds[1] {
+				expectedBetween = append(expectedBetween, key)
+			}
+		}
+		require.Equal(t, expectedBetween, between, "range %v", bounds)
+	}
+}
+
+func TestSkipListConcurrentAdd(t *testing.T) {
+	const writers, rounds, keys = 8, 20, 1000
+	skipList := new(SkipList).New()
+	var wg sync.WaitGroup
+	for writer := 0; writer < writers; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for round := 0; round < rounds; round++ {
+				for key := 0; key < keys; key++ {
+					skipList.Add(fmt.Sprint((key+writer*97)%keys), 1)
+				}
+			}
+		}(writer)
+	}
+	// readers see keys in order while writers go on
+	for read := 0; read < 10; read++ {
+		previous := ""
+		skipList.Range(func(key string, value int) {
+			require.Less(t, previous, key)
+			previous = key
+		})
+	}
+	wg.Wait()
+
+	require.Equal(t, keys, skipList.Size())
+	for key := 0; key < keys; key++ {
+		value, ok := skipList.Get(fmt.Sprint(key))
+		require.True(t, ok)
+		require.Equal(t, writers*rounds, value)
+	}
+}
+
+func TestSkipListMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("skip list", 0)
+	skipList := new(SkipList).NewWithTracker(tracker)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				skipList.Add(fmt.Sprint(i), 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// every key is accounted once with links of all its levels
+	expected := NodeBytes + MaxLevel*LinkBytes
+	for curr := skipList.head.next[0].Load(); curr != nil; curr = curr.next[0].Load() {
+		expected += NodeBytes + int64(len(curr.next))*LinkBytes
+	}
+	require.Equal(t, expected, tracker.Used())
+	require.NoError(t, skipList.Err())
+	skipList.Release()
+	require.Zero(t, tracker.Used())
+
+	skipList = new(SkipList).NewWithTracker(new(memory.Tracker).New("skip list", 10*NodeBytes))
+	for i := 0; i < 100; i++ {
+		skipList.Add(fmt.Sprint(i), 1)
+	}
+	require.ErrorIs(t, skipList.Err(), memory.ErrMemoryLimitExceeded)
+	require.Equal(t, 100, skipList.Size())
+}
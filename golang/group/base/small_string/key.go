@@ -0,0 +1,79 @@
+package small_string
+
+import (
+	"encoding/binary"
+	"group/base/arena"
+)
+
+/*
+Key is group key of two uint64 words, most of keys here ("Android", "iOS", brand names) are shorter than 16 bytes:
+- key up to MaxInlineLength bytes is stored inline: bytes 0..7 in Lo, bytes 8..14 in low bytes of Hi and
+  length in the highest byte of Hi (unused bytes are zero), so equal keys have equal words;
+- longer key is stored in arena: Lo keeps chunk and offset, Hi keeps length and arenaTag in the highest byte.
+Inline keys are hashed and compared as two words without dereferencing of string pointer.
+*/
+
+const (
+	MaxInlineLength = 15
+	arenaTag        = 0xFF
+)
+
+type Key struct {
+	Lo uint64
+	Hi uint64
+}
+
+// Inline makes inline key, false if key is longer than MaxInlineLength
+func Inline(key string) (Key, bool) {
+	if len(key) > MaxInlineLength {
+		return Key{}, false
+	}
+	var buf [16]byte
+	copy(buf[:], key)
+	buf[15] = byte(len(key))
+	return Key{Lo: binary.LittleEndian.Uint64(buf[:8]), Hi: binary.LittleEndian.Uint64(buf[8:])}, true
+}
+
+// FromRef makes key referencing bytes of key in arena
+func FromRef(ref arena.Ref) Key {
+	return Key{Lo: uint64(ref.Chunk)<<32 | uint64(ref.Offset), Hi: arenaTag<<56 | uint64(ref.Length)}
+}
+
+func (key Key) IsInline() bool {
+	return key.Hi>>56 != arenaTag
+}
+
+// Ref returns reference of arena key
+func (key Key) Ref() arena.Ref {
+	return arena.Ref{Chunk: uint32(key.Lo >> 32), Offset: uint32(key.Lo), Length: uint32(key.Hi)}
+}
+
+func (key Key) Len() int {
+	if key.IsInline() {
+		return int(key.Hi >> 56)
+	}
+	return int(uint32(key.Hi))
+}
+
+// String returns inline key as string
+func (key Key) String() string {
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], key.Lo)
+	binary.LittleEndian.PutUint64(buf[8:], key.Hi)
+	return string(buf[:key.Len()])
+}
+
+// Hash hashes inline key by its words with murmur finalizer
+func (key Key) Hash() uint64 {
+	return mix(mix(key.Lo) ^ key.Hi)
+}
+
+// Basic murmur finalizer - https://gist.github.com/dnbaker/0fc1d4edbbdb24069eb063dc2559e4f5
+func mix(hash uint64) uint64 {
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
@@ -0,0 +1,43 @@
+package small_string
+
+import (
+	"group/base/arena"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInline(t *testing.T) {
+	for _, value := range []string{"", "iOS", "Android", "12345678", strings.Repeat("x", MaxInlineLength)} {
+		key, ok := Inline(value)
+		require.True(t, ok)
+		require.True(t, key.IsInline())
+		require.Equal(t, len(value), key.Len())
+		require.Equal(t, value, key.String())
+
+		other, _ := Inline(value)
+		require.Equal(t, key, other)
+		require.Equal(t, key.Hash(), other.Hash())
+	}
+
+	_, ok := Inline(strings.Repeat("x", MaxInlineLength+1))
+	require.False(t, ok)
+
+	// prefix of key is different key
+	android, _ := Inline("Android")
+	androi, _ := Inline("Androi")
+	require.NotEqual(t, android, androi)
+	require.NotEqual(t, android.Hash(), androi.Hash())
+}
+
+func TestFromRef(t *testing.T) {
+	keys := new(arena.Arena).New()
+	value := strings.Repeat("y", 100)
+	ref := keys.Append(value)
+	key := FromRef(ref)
+	require.False(t, key.IsInline())
+	require.Equal(t, ref, key.Ref())
+	require.Equal(t, 100, key.Len())
+	require.Equal(t, value, keys.String(key.Ref()))
+}
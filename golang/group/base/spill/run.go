@@ -0,0 +1,185 @@
+package spill
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+/*
+Run is sequence of records sorted by key, run file is sequence of records: uvarint length of key, bytes of key,
+varint value. Sorted runs (files or records in memory) are merged by k-way heap merge, equal keys come one after
+another so their values are summed up and emitted as soon as next key appears. Merge keeps only one record per run
+in memory.
+*/
+
+type Record struct {
+	Key   string
+	Value int
+}
+
+// WriteRun writes records (sorted by key) to run file, returns number of bytes written
+func WriteRun(path string, records []Record) (int64, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	writer := bufio.NewWriter(file)
+	var written int64
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, record := range records {
+		size, err := writeRecord(writer, buf, record)
+		if err != nil {
+			_ = file.Close()
+			return 0, err
+		}
+		written += size
+	}
+	if err = writer.Flush(); err != nil {
+		_ = file.Close()
+		return 0, err
+	}
+	return written, file.Close()
+}
+
+// writeRecord writes uvarint length of key, bytes of key and varint value, returns number of bytes written
+func writeRecord(writer *bufio.Writer, buf []byte, record Record) (int64, error) {
+	size := binary.PutUvarint(buf, uint64(len(record.Key)))
+	if _, err := writer.Write(buf[:size]); err != nil {
+		return 0, err
+	}
+	if _, err := writer.WriteString(record.Key); err != nil {
+		return 0, err
+	}
+	written := int64(size + len(record.Key))
+	size = binary.PutVarint(buf, int64(record.Value))
+	if _, err := writer.Write(buf[:size]); err != nil {
+		return 0, err
+	}
+	return written + int64(size), nil
+}
+
+// Source is sorted stream of records of one run
+type Source interface {
+	// Next returns next record, false if run is over
+	Next() (Record, bool, error)
+}
+
+type FileSource struct {
+	file   *os.File
+	reader *bufio.Reader
+}
+
+// OpenRun opens run file for reading
+func OpenRun(path string) (*FileSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSource{file: file, reader: bufio.NewReader(file)}, nil
+}
+
+func (source *FileSource) Next() (Record, bool, error) {
+	length, err := binary.ReadUvarint(source.reader)
+	if err == io.EOF {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+	key := make([]byte, length)
+	if _, err = io.ReadFull(source.reader, key); err != nil {
+		return Record{}, false, err
+	}
+	value, err := binary.ReadVarint(source.reader)
+	if err != nil {
+		return Record{}, false, err
+	}
+	return Record{Key: string(key), Value: int(value)}, true, nil
+}
+
+func (source *FileSource) Close() error {
+	return source.file.Close()
+}
+
+type MemorySource struct {
+	records []Record
+}
+
+// NewMemorySource makes source of records (sorted by key) kept in memory
+func NewMemorySource(records []Record) *MemorySource {
+	return &MemorySource{records: records}
+}
+
+func (source *MemorySource) Next() (Record, bool, error) {
+	if len(source.records) == 0 {
+		return Record{}, false, nil
+	}
+	record := source.records[0]
+	source.records = source.records[1:]
+	return record, true, nil
+}
+
+// head is current record of source in merge heap
+type head struct {
+	record Record
+	source Source
+}
+
+type mergeHeap []head
+
+func (h mergeHeap) Len() int           { return len(h) }
+func (h mergeHeap) Less(i, j int) bool { return h[i].record.Key < h[j].record.Key }
+func (h mergeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)        { *h = append(*h, x.(head)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// MergeSources merges sorted sources and calls fn for every key in sorted order with sum of its values
+func MergeSources(sources []Source, fn func(key string, value int)) error {
+	mergeHeap := make(mergeHeap, 0, len(sources))
+	for _, source := range sources {
+		record, ok, err := source.Next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			mergeHeap = append(mergeHeap, head{record: record, source: source})
+		}
+	}
+	heap.Init(&mergeHeap)
+
+	hasGroup := false
+	var groupKey string
+	var groupValue int
+	for mergeHeap.Len() > 0 {
+		top := &mergeHeap[0]
+		if hasGroup && top.record.Key != groupKey {
+			fn(groupKey, groupValue)
+			groupValue = 0
+		}
+		groupKey, hasGroup = top.record.Key, true
+		groupValue += top.record.Value
+
+		record, ok, err := top.source.Next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			top.record = record
+			heap.Fix(&mergeHeap, 0)
+		} else {
+			heap.Pop(&mergeHeap)
+		}
+	}
+	if hasGroup {
+		fn(groupKey, groupValue)
+	}
+	return nil
+}
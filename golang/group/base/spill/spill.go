@@ -0,0 +1,128 @@
+package spill
+
+import (
+	"fmt"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/hashmap/two_level"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+/*
+External (spill-to-disk) aggregation - buckets of two level table are natural units of spill.
+
+When aggregation state exceeds memory budget every non-empty bucket of two level table is written to disk as run file
+sorted by key, and table is reset. In the end runs are merged bucket by bucket: runs of the bucket (and rest of bucket
+still kept in memory) are merged by k-way heap merge, equal keys come one after another so their aggregates are
+summed up and emitted as soon as next key appears. Merge of bucket keeps only one record per run in memory,
+so memory of final phase is bounded by number of runs, not by number of keys.
+Keys of bucket come out of merge in sorted order.
+*/
+
+type Stats struct {
+	// Spills is number of times tables been spilled
+	Spills int
+	// Runs is number of run files written
+	Runs int
+	// SpilledBytes is number of bytes written to run files
+	SpilledBytes int64
+}
+
+// Spiller writes buckets of tables to run files in temporary directory and merges them back
+type Spiller struct {
+	dir   string
+	mutex sync.Mutex
+	runs  [][]string
+	stats Stats
+}
+
+// New makes spiller with run files in new temporary directory under dir (default temporary directory if empty)
+func (spiller *Spiller) New(dir string) (*Spiller, error) {
+	runsDir, err := os.MkdirTemp(dir, "spill-")
+	if err != nil {
+		return nil, err
+	}
+	return &Spiller{dir: runsDir, runs: make([][]string, two_level.NumBuckets)}, nil
+}
+
+func (spiller *Spiller) Stats() Stats {
+	spiller.mutex.Lock()
+	defer spiller.mutex.Unlock()
+	return spiller.stats
+}
+
+// sortedRecords returns records of used cells of table sorted by key
+func sortedRecords(table *v1.HashTableWithLinearProbing) []Record {
+	records := make([]Record, 0, table.Size())
+	for _, cell := range table.Cells {
+		if cell.Key != "" {
+			records = append(records, Record{Key: cell.Key, Value: cell.Value})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Key < records[j].Key
+	})
+	return records
+}
+
+// Spill writes every non-empty bucket of table to its sorted run file, table can be reset after it.
+// Safe to call concurrently for different tables.
+func (spiller *Spiller) Spill(table *two_level.TwoLevelHashMap) error {
+	spiller.mutex.Lock()
+	spill := spiller.stats.Spills
+	spiller.stats.Spills++
+	spiller.mutex.Unlock()
+
+	for bucketId, bucket := range table.Buckets {
+		if bucket == nil || bucket.Size() == 0 {
+			continue
+		}
+		path := filepath.Join(spiller.dir, fmt.Sprintf("bucket-%d-run-%d", bucketId, spill))
+		written, err := WriteRun(path, sortedRecords(bucket))
+		if err != nil {
+			return err
+		}
+
+		spiller.mutex.Lock()
+		spiller.runs[bucketId] = append(spiller.runs[bucketId], path)
+		spiller.stats.Runs++
+		spiller.stats.SpilledBytes += written
+		spiller.mutex.Unlock()
+	}
+	return nil
+}
+
+// Merge merges run files of bucket with buckets of tables still in memory and calls fn for every key of bucket
+// in sorted order with total aggregate. Run files of bucket are removed after merge.
+func (spiller *Spiller) Merge(bucketId int, tables []*two_level.TwoLevelHashMap, fn func(key string, value int)) error {
+	spiller.mutex.Lock()
+	paths := spiller.runs[bucketId]
+	spiller.runs[bucketId] = nil
+	spiller.mutex.Unlock()
+
+	sources := make([]Source, 0, len(paths)+len(tables))
+	for _, path := range paths {
+		run, err := OpenRun(path)
+		if err != nil {
+			return err
+		}
+		defer func(run *FileSource, path string) {
+			_ = run.Close()
+			_ = os.Remove(path)
+		}(run, path)
+		sources = append(sources, run)
+	}
+	for _, table := range tables {
+		if bucket := table.Buckets[bucketId]; bucket != nil && bucket.Size() > 0 {
+			sources = append(sources, NewMemorySource(sortedRecords(bucket)))
+		}
+	}
+	return MergeSources(sources, fn)
+}
+
+// Close removes temporary directory with all run files
+func (spiller *Spiller) Close() error {
+	return os.RemoveAll(spiller.dir)
+}
@@ -0,0 +1,99 @@
+package spill
+
+import (
+	"group/base/hashmap/two_level"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func add(table *two_level.TwoLevelHashMap, key string, value int) {
+	if cell := table.Get(key); cell != nil {
+		value += cell.Value
+	}
+	table.Put(key, value)
+}
+
+func TestSpillAndMerge(t *testing.T) {
+	spiller, err := new(Spiller).New(t.TempDir())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, spiller.Close())
+	}()
+
+	expected := make(map[string]int)
+	table := new(two_level.TwoLevelHashMap).New()
+	for i := 0; i < 30000; i++ {
+		key := "key-" + strconv.Itoa(i%5000)
+		add(table, key, i)
+		expected[key] += i
+		// spill every 10000 rows, same keys go to several runs
+		if i%10000 == 9999 {
+			require.NoError(t, spiller.Spill(table))
+			table = new(two_level.TwoLevelHashMap).New()
+		}
+	}
+	// rest stays in memory
+	for i := 0; i < 100; i++ {
+		add(table, "key-"+strconv.Itoa(i), 1)
+		expected["key-"+strconv.Itoa(i)]++
+	}
+
+	stats := spiller.Stats()
+	require.Equal(t, 3, stats.Spills)
+	require.Greater(t, stats.Runs, 3)
+	require.Greater(t, stats.SpilledBytes, int64(0))
+
+	actual := make(map[string]int)
+	for bucketId := 0; bucketId < two_level.NumBuckets; bucketId++ {
+		keys := make([]string, 0)
+		require.NoError(t, spiller.Merge(bucketId, []*two_level.TwoLevelHashMap{table}, func(key string, value int) {
+			keys = append(keys, key)
+			actual[key] = value
+		}))
+		require.True(t, sort.StringsAreSorted(keys))
+	}
+	require.Equal(t, expected, actual)
+
+	// run files are removed after merge
+	entries, err := os.ReadDir(spiller.dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestMergeSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run")
+	written, err := WriteRun(path, []Record{{"Android", 1}, {"Android", 2}, {"iOS", 3}})
+	require.NoError(t, err)
+	require.Greater(t, written, int64(0))
+
+	run, err := OpenRun(path)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, run.Close())
+	}()
+
+	memory := NewMemorySource([]Record{{"Android", 10}, {"Windows", 4}})
+	keys := make([]string, 0)
+	values := make([]int, 0)
+	require.NoError(t, MergeSources([]Source{run, memory}, func(key string, value int) {
+		keys = append(keys, key)
+		values = append(values, value)
+	}))
+	require.Equal(t, []string{"Android", "Windows", "iOS"}, keys)
+	require.Equal(t, []int{13, 4, 3}, values)
+}
+
+func TestWriteRunError(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full")
+	}
+	// key longer than buffer of writer fails on write of key, not on flush
+	_, err := WriteRun("/dev/full", []Record{{strings.Repeat("x", 1<<16), 1}})
+	require.Error(t, err)
+}
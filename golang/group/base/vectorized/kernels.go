@@ -0,0 +1,60 @@
+package vectorized
+
+import "group/base/batch"
+
+// Number is type of aggregated column
+type Number interface {
+	int64 | float64
+}
+
+// Grow extends state vector to size groups, new groups get init value
+func Grow[T any](state []T, size int, init T) []T {
+	for len(state) < size {
+		state = append(state, init)
+	}
+	return state
+}
+
+// Sum adds valid values of rows to sums of their groups
+func Sum[T Number](groups []int32, values []T, validity batch.Bitmap, sums []T) {
+	if validity == nil {
+		for row, group := range groups {
+			if group != NoGroup {
+				sums[group] += values[row]
+			}
+		}
+		return
+	}
+	for row, group := range groups {
+		if group != NoGroup && validity.IsValid(row) {
+			sums[group] += values[row]
+		}
+	}
+}
+
+// Count counts valid rows of groups, nil validity counts all rows
+func Count(groups []int32, validity batch.Bitmap, counts []int64) {
+	for row, group := range groups {
+		if group != NoGroup && validity.IsValid(row) {
+			counts[group]++
+		}
+	}
+}
+
+// Min keeps minimum of valid values of groups, state must be initialised by maximum of type
+func Min[T Number](groups []int32, values []T, validity batch.Bitmap, mins []T) {
+	for row, group := range groups {
+		if group != NoGroup && validity.IsValid(row) && values[row] < mins[group] {
+			mins[group] = values[row]
+		}
+	}
+}
+
+// Max keeps maximum of valid values of groups, state must be initialised by minimum of type
+func Max[T Number](groups []int32, values []T, validity batch.Bitmap, maxs []T) {
+	for row, group := range groups {
+		if group != NoGroup && validity.IsValid(row) && values[row] > maxs[group] {
+			maxs[group] = values[row]
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package vectorized
+
+import (
+	"group/base/batch"
+	"group/base/memory"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var schema = batch.Schema{
+	{Name: "os", Type: batch.String},
+	{Name: "popularity", Type: batch.Int64},
+	{Name: "price", Type: batch.Float64},
+}
+
+func TestKernels(t *testing.T) {
+	dataBatch, err := batch.FromRecords([][]string{
+		{"Android", "10", "1.5"},
+		{"iOS", "20", "2.5"},
+		{"Android", "5", ""},
+		{"", "100", "100.0"},
+		{"iOS", "", "0.5"},
+	}, schema)
+	require.NoError(t, err)
+	keys, _ := dataBatch.String(0)
+	popularity, _ := dataBatch.Int64(1)
+	prices, _ := dataBatch.Float64(2)
+
+	table := new(GroupTable).New()
+	groups := make([]int32, dataBatch.Rows)
+	table.FindOrInsert(keys, groups)
+	require.Equal(t, []int32{0, 1, 0, NoGroup, 1}, groups)
+	require.Equal(t, []string{"Android", "iOS"}, table.Keys)
+
+	sums := Grow[int64](nil, table.Size(), 0)
+	Sum(groups, popularity.Values, popularity.Validity, sums)
+	require.Equal(t, []int64{15, 20}, sums)
+
+	counts := Grow[int64](nil, table.Size(), 0)
+	Count(groups, prices.Validity, counts)
+	require.Equal(t, []int64{1, 2}, counts)
+
+	mins := Grow(nil, table.Size(), math.Inf(1))
+	Min(groups, prices.Values, prices.Validity, mins)
+	require.Equal(t, []float64{1.5, 0.5}, mins)
+
+	maxs := Grow(nil, table.Size(), int64(math.MinInt64))
+	Max(groups, popularity.Values, popularity.Validity, maxs)
+	require.Equal(t, []int64{10, 20}, maxs)
+
+	floatSums := Grow[float64](nil, table.Size(), 0)
+	Sum(groups, prices.Values, nil, floatSums)
+	require.Equal(t, []float64{1.5, 3}, floatSums)
+}
+
+func TestGroupTableResize(t *testing.T) {
+	table := new(GroupTable).New()
+	for round := 0; round < 2; round++ {
+		records := make([][]string, 0, 1000)
+		for i := 0; i < 1000; i++ {
+			records = append(records, []string{"key-" + strconv.Itoa(round*500+i), "1", "1.0"})
+		}
+		dataBatch, err := batch.FromRecords(records, schema)
+		require.NoError(t, err)
+		keys, _ := dataBatch.String(0)
+		groups := make([]int32, dataBatch.Rows)
+		table.FindOrInsert(keys, groups)
+	}
+	require.Equal(t, 1500, table.Size())
+	for i := 0; i < 1500; i++ {
+		group := table.Find("key-" + strconv.Itoa(i))
+		require.NotEqual(t, NoGroup, group)
+		require.Equal(t, "key-"+strconv.Itoa(i), table.Keys[group])
+	}
+	require.Equal(t, NoGroup, table.Find("missing"))
+}
+
+func TestInsert(t *testing.T) {
+	table := new(GroupTable).New()
+	for i := 0; i < 100; i++ {
+		require.Equal(t, int32(i), table.Insert(strconv.Itoa(i)))
+	}
+	require.Equal(t, int32(42), table.Insert("42"))
+	require.Equal(t, 100, table.Size())
+}
+
+func TestGroupTableMemoryTracking(t *testing.T) {
+	tracker := new(memory.Tracker).New("table", 0)
+	table := new(GroupTable).NewWithTracker(16, tracker)
+	require.Equal(t, 16*SlotBytes, tracker.Used())
+
+	records := make([][]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		records = append(records, []string{"key-" + strconv.Itoa(i%100), "1", "1.0"})
+	}
+	dataBatch, err := batch.FromRecords(records, schema)
+	require.NoError(t, err)
+	keys, _ := dataBatch.String(0)
+	table.FindOrInsert(keys, make([]int32, dataBatch.Rows))
+	table.Insert("key-100")
+
+	// table is reserved for the whole batch
+	require.Equal(t, int64(len(table.slots))*SlotBytes+101*GroupBytes+1000*scratchBytes, tracker.Used())
+	require.NoError(t, table.Err())
+	table.Release()
+	require.Zero(t, tracker.Used())
+
+	limited := new(memory.Tracker).New("table", 1000*scratchBytes)
+	table = new(GroupTable).NewWithTracker(16, limited)
+	table.FindOrInsert(keys, make([]int32, dataBatch.Rows))
+	require.ErrorIs(t, table.Err(), memory.ErrMemoryLimitExceeded)
+	require.Equal(t, 100, table.Size())
+}
@@ -0,0 +1,212 @@
+package vectorized
+
+import (
+	"group/base/batch"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
+	"unsafe"
+)
+
+/*
+Vectorized aggregation - instead of hash, probe and update per row, every step runs over the whole batch:
+1. HashStrings hashes key column of batch into hash vector;
+2. GroupTable.FindOrInsert computes home slots of all rows (slot vector), then probes slots in tight loop and
+   writes dense group index of every row into group vector;
+3. aggregate kernels (Sum, Count, Min, Max) update state vectors indexed by group in one loop per column.
+Every loop is small and branch light, loads of the next iterations are independent, so cpu can issue them ahead
+(there is no prefetch intrinsic in Go, batching of slot computation is what makes probing prefetch friendly).
+Table keeps only keys and group indexes, aggregates are plain vectors (one per aggregate function), so any number
+of aggregates over the same keys costs one probe per row.
+*/
+
+const (
+	defaultCapacity = 16
+	// NoGroup marks row with null key, kernels skip it
+	NoGroup   int32 = -1
+	emptySlot int32 = -1
+)
+
+const (
+	// SlotBytes is memory taken by slot of table
+	SlotBytes = int64(unsafe.Sizeof(int32(0)))
+	// GroupBytes is memory taken by key (without its bytes) and hash of group
+	GroupBytes = int64(unsafe.Sizeof("") + unsafe.Sizeof(uint64(0)))
+	// scratchBytes is memory of scratch vectors per row of batch
+	scratchBytes = int64(2 * unsafe.Sizeof(uint64(0)))
+)
+
+// HashStrings hashes every value of column to hashes, hashes must have length of column at least
+func HashStrings(column *batch.StringColumn, hashes []uint64) {
+	for row := range hashes[:column.Len()] {
+		hashes[row] = v1.HashStringKey(column.Value(row))
+	}
+}
+
+// Slots computes home slot of every hash of table with capacity mask+1
+func Slots(hashes []uint64, mask uint64, slots []uint64) {
+	for row, hash := range hashes {
+		slots[row] = hash & mask
+	}
+}
+
+// GroupTable is open addressing table (linear probing) mapping string key to dense group index
+type GroupTable struct {
+	// Keys of groups by group index
+	Keys   []string
+	hashes []uint64
+	// slots keep group index of slot
+	slots []int32
+	mask  uint64
+
+	// scratch vectors of batch
+	batchHashes []uint64
+	batchSlots  []uint64
+
+	// account of slots, groups and scratch vectors
+	account *memory.Account
+}
+
+func (table *GroupTable) New() *GroupTable {
+	return table.NewWithCapacity(defaultCapacity)
+}
+
+// NewWithCapacity makes table with capacity rounded up to power of two
+func (table *GroupTable) NewWithCapacity(capacity int) *GroupTable {
+	length := defaultCapacity
+	for length < capacity {
+		length <<= 1
+	}
+	result := &GroupTable{mask: uint64(length - 1)}
+	result.slots = makeSlots(length)
+	return result
+}
+
+// NewWithTracker makes table with capacity reporting memory of its slots, groups and scratch vectors to tracker
+func (table *GroupTable) NewWithTracker(capacity int, tracker *memory.Tracker) *GroupTable {
+	result := table.NewWithCapacity(capacity)
+	result.account = new(memory.Account).New(tracker)
+	result.account.Alloc(int64(len(result.slots)) * SlotBytes)
+	return result
+}
+
+var _ memory.Accounted = (*GroupTable)(nil)
+
+func (table *GroupTable) Err() error {
+	return table.account.Err()
+}
+
+func (table *GroupTable) Release() {
+	table.account.Release()
+}
+
+func makeSlots(length int) []int32 {
+	slots := make([]int32, length)
+	for idx := range slots {
+		slots[idx] = emptySlot
+	}
+	return slots
+}
+
+// Size returns number of groups
+func (table *GroupTable) Size() int {
+	return len(table.Keys)
+}
+
+// reserve grows table so rows more keys can be inserted without resize (fill factor is kept under half)
+func (table *GroupTable) reserve(rows int) {
+	length := len(table.slots)
+	for 2*(len(table.Keys)+rows) > length {
+		length <<= 1
+	}
+	if length == len(table.slots) {
+		return
+	}
+	table.account.Alloc(int64(length-len(table.slots)) * SlotBytes)
+	table.slots = makeSlots(length)
+	table.mask = uint64(length - 1)
+	for group, hash := range table.hashes {
+		slot := hash & table.mask
+		for table.slots[slot] != emptySlot {
+			slot = (slot + 1) & table.mask
+		}
+		table.slots[slot] = int32(group)
+	}
+}
+
+// FindOrInsert writes group index of every row of key column to groups, new keys are inserted.
+// Rows with null key get NoGroup. Groups must have length of column at least.
+func (table *GroupTable) FindOrInsert(column *batch.StringColumn, groups []int32) {
+	rows := column.Len()
+	// table is grown for the whole batch once, so slots computed below stay valid during probing
+	table.reserve(rows)
+	if cap(table.batchHashes) < rows {
+		table.account.Alloc(int64(rows-cap(table.batchHashes)) * scratchBytes)
+		table.batchHashes = make([]uint64, rows)
+		table.batchSlots = make([]uint64, rows)
+	}
+	hashes := table.batchHashes[:rows]
+	slots := table.batchSlots[:rows]
+
+	HashStrings(column, hashes)
+	Slots(hashes, table.mask, slots)
+
+	for row, slot := range slots {
+		if !column.IsValid(row) {
+			groups[row] = NoGroup
+			continue
+		}
+		key := column.Value(row)
+		for {
+			group := table.slots[slot]
+			if group == emptySlot {
+				group = int32(len(table.Keys))
+				table.slots[slot] = group
+				table.Keys = append(table.Keys, key)
+				table.hashes = append(table.hashes, hashes[row])
+				table.account.Alloc(GroupBytes)
+				groups[row] = group
+				break
+			}
+			if table.hashes[group] == hashes[row] && table.Keys[group] == key {
+				groups[row] = group
+				break
+			}
+			slot = (slot + 1) & table.mask
+		}
+	}
+}
+
+// Find returns group index of key, NoGroup if there is no such key
+func (table *GroupTable) Find(key string) int32 {
+	hash := v1.HashStringKey(key)
+	slot := hash & table.mask
+	for {
+		group := table.slots[slot]
+		if group == emptySlot {
+			return NoGroup
+		}
+		if table.hashes[group] == hash && table.Keys[group] == key {
+			return group
+		}
+		slot = (slot + 1) & table.mask
+	}
+}
+
+// Insert returns group index of key inserting it if needed, it is row-at-a-time path used to merge tables
+func (table *GroupTable) Insert(key string) int32 {
+	if group := table.Find(key); group != NoGroup {
+		return group
+	}
+	table.reserve(1)
+	hash := v1.HashStringKey(key)
+	slot := hash & table.mask
+	for table.slots[slot] != emptySlot {
+		slot = (slot + 1) & table.mask
+	}
+	group := int32(len(table.Keys))
+	table.slots[slot] = group
+	table.Keys = append(table.Keys, key)
+	table.hashes = append(table.hashes, hash)
+	table.account.Alloc(GroupBytes)
+	return group
+}
@@ -0,0 +1,253 @@
+package auto
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/dictionary"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/scheduler"
+	"group/base/skew"
+	"group/multicore/baseline_hashmap"
+	"group/multicore/global_local_hashmap"
+	multicore_lookup_table "group/multicore/lookup_table"
+	"group/multicore/two_level_hashmap"
+	onecore "group/onecore/hashmap"
+	onecore_lookup_table "group/onecore/lookup_table"
+	"log"
+	"math"
+	"runtime"
+)
+
+/*
+Auto aggregator - cost based choice of aggregation strategy, follows decision table of README:
+- few rows (or one core) - one core hash map, there is nothing to parallelize;
+- small cardinality with heavy hitters - shared table with thread-local overflow (hot keys do not contend on cells);
+- small cardinality - thread-local lookup tables of dictionary encoded keys merged by vector addition
+  (thread-local hash maps if dictionary overflows);
+- big cardinality - two level hash map (merge is parallel by buckets), shared table has fixed capacity, so it does
+  not fit big cardinality even if data is skewed.
+
+Number of rows is known, cardinality is estimated on sample of keys (GEE estimator -
+https://dl.acm.org/doi/10.1145/335168.335230) and skew by heavy hitters of the sample. Since sample can be wrong
+(as example all rare keys are missed), query runs in stages: first stage aggregates probe part of rows, then number of
+groups it produced is checked against estimation and the rest of rows is re-planned if estimation proved wrong.
+*/
+
+type Strategy string
+
+const (
+	OneCore     Strategy = "onecore/hashmap"
+	ThreadLocal Strategy = "multicore/baseline_hashmap"
+	LookupTable Strategy = "multicore/lookup_table"
+	GlobalLocal Strategy = "multicore/global_local_hashmap"
+	TwoLevel    Strategy = "multicore/two_level_hashmap"
+)
+
+const (
+	// SmallRows is number of rows below which data is aggregated on one core
+	SmallRows = 1 << 14
+	// SmallCardinality is number of groups below which thread-local tables are merged cheaply
+	SmallCardinality = 1 << 10
+
+	sampleSize = 4096
+	// probeFraction is part of rows aggregated before estimations are checked
+	probeFraction = 8
+	// estimation is wrong if real cardinality differs more than misestimation times
+	misestimation = 2
+)
+
+type Plan struct {
+	Strategy    Strategy
+	Rows        int
+	Cardinality int
+	// Skew is load of the hottest worker relative to average load if keys are partitioned between workers
+	Skew         float64
+	HeavyHitters int
+	Reason       string
+}
+
+func GroupByOsAndSumByPopularity() {
+	// use all cores on your machine
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	// prepare data
+	records := base.Data()
+	groups, _ := GroupBy(records, options)
+
+	// print out result
+	for key, value := range groups {
+		log.Printf("Popularity %d for group %s", value, key)
+	}
+	log.Println()
+}
+
+// GroupBy groups records (with csv caption) by os and sums popularity by automatically chosen strategy.
+// Plans of every stage of query are returned together with result.
+func GroupBy(records [][]string, options scheduler.Options) (map[string]int, []Plan) {
+	rows := len(records) - 1
+	if rows <= 0 {
+		return map[string]int{}, nil
+	}
+
+	plan := Estimate(records, options)
+	log.Printf("Plan %s: %s", plan.Strategy, plan.Reason)
+	if plan.Strategy == OneCore {
+		return Execute(plan.Strategy, records, options), []Plan{plan}
+	}
+
+	// probe stage
+	probeRows := rows / probeFraction
+	groups := Execute(plan.Strategy, records[:probeRows+1], options)
+	plans := []Plan{plan}
+
+	// re-plan the rest of rows if estimation proved wrong
+	if cardinality, wrong := checkEstimation(plan.Cardinality, len(groups), probeRows, rows); wrong {
+		replan := choose(rows-probeRows, cardinality, plan.Skew, plan.HeavyHitters, options)
+		replan.Reason = fmt.Sprintf("estimated cardinality %d but probe of %d rows produced %d groups, %s",
+			plan.Cardinality, probeRows, len(groups), replan.Reason)
+		log.Printf("Re-plan %s: %s", replan.Strategy, replan.Reason)
+		plan = replan
+		plans = append(plans, plan)
+	}
+
+	rest := make([][]string, 0, rows-probeRows+1)
+	rest = append(rest, records[0])
+	rest = append(rest, records[probeRows+1:]...)
+	for key, value := range Execute(plan.Strategy, rest, options) {
+		groups[key] += value
+	}
+	return groups, plans
+}
+
+// Estimate makes plan by number of rows and estimations of cardinality and skew on sample of keys
+func Estimate(records [][]string, options scheduler.Options) Plan {
+	rows := len(records) - 1
+	sample := make([]string, 0, sampleSize)
+	for _, row := range skew.SampleRows(1, len(records), sampleSize) {
+		if key := records[row][3]; key != "" {
+			sample = append(sample, key)
+		}
+	}
+
+	workers := options.Workers()
+	report := skew.Detect(sample, workers, func(key string) int {
+		return int(v1.HashStringKey(key) % uint64(workers))
+	}, skew.DefaultOptions())
+
+	return choose(rows, estimateCardinality(sample, rows), report.Skew, len(report.HeavyHitters), options)
+}
+
+// estimateCardinality is GEE estimator - keys seen once in sample are scaled by sqrt(rows / sample), keys seen
+// several times are counted once
+func estimateCardinality(sample []string, rows int) int {
+	if len(sample) == 0 {
+		return 0
+	}
+	counts := make(map[string]int)
+	for _, key := range sample {
+		counts[key]++
+	}
+	singletons := 0
+	for _, count := range counts {
+		if count == 1 {
+			singletons++
+		}
+	}
+	estimation := math.Sqrt(float64(rows)/float64(len(sample)))*float64(singletons) + float64(len(counts)-singletons)
+	return int(math.Min(math.Round(estimation), float64(rows)))
+}
+
+// checkEstimation compares estimated cardinality with number of groups of probe rows, returns new estimation
+func checkEstimation(estimation int, groups int, probeRows int, rows int) (int, bool) {
+	if groups > misestimation*estimation {
+		// cardinality is underestimated - scale groups of probe to all rows
+		return int(math.Min(float64(groups)*float64(rows)/float64(probeRows), float64(rows))), true
+	}
+	if groups*misestimation < estimation && probeRows >= SmallCardinality*groups {
+		// cardinality is overestimated - probe saw every group many times, so it saw all of them
+		return groups, true
+	}
+	return estimation, false
+}
+
+func choose(rows int, cardinality int, skewness float64, heavyHitters int, options scheduler.Options) Plan {
+	plan := Plan{Rows: rows, Cardinality: cardinality, Skew: skewness, HeavyHitters: heavyHitters}
+	switch {
+	case options.Workers() == 1:
+		plan.Strategy = OneCore
+		plan.Reason = "one worker"
+	case rows < SmallRows:
+		plan.Strategy = OneCore
+		plan.Reason = fmt.Sprintf("%d rows is less than %d", rows, SmallRows)
+	case cardinality <= SmallCardinality && heavyHitters > 0:
+		plan.Strategy = GlobalLocal
+		plan.Reason = fmt.Sprintf("cardinality %d with %d heavy hitters (skew %.2f), hot keys go to thread-local tables",
+			cardinality, heavyHitters, skewness)
+	case cardinality <= SmallCardinality:
+		plan.Strategy = LookupTable
+		plan.Reason = fmt.Sprintf("cardinality %d is not more than %d, dictionary encoded keys fit lookup table",
+			cardinality, SmallCardinality)
+	default:
+		plan.Strategy = TwoLevel
+		plan.Reason = fmt.Sprintf("cardinality %d is more than %d, two level table is merged in parallel by buckets",
+			cardinality, SmallCardinality)
+	}
+	return plan
+}
+
+// Execute runs strategy on records (with csv caption)
+func Execute(strategy Strategy, records [][]string, options scheduler.Options) map[string]int {
+	groups := make(map[string]int)
+	switch strategy {
+	case OneCore:
+		for _, cell := range onecore.GroupBy(records).Cells {
+			if cell.Key != "" {
+				groups[cell.Key] += cell.Value
+			}
+		}
+	case LookupTable:
+		osDictionary := dictionary.New[uint16]()
+		table, err := multicore_lookup_table.GroupBy(records, options, onecore_lookup_table.OsKey(osDictionary))
+		if err != nil {
+			log.Printf("Fallback to %s: %s", ThreadLocal, err)
+			return Execute(ThreadLocal, records, options)
+		}
+		table.Range(func(key uint16, value int) {
+			groups[osDictionary.Decode(key)] += value
+		})
+	case ThreadLocal:
+		for _, cell := range baseline_hashmap.GroupBy(records, options).Cells {
+			if cell.Key != "" {
+				groups[cell.Key] += cell.Value
+			}
+		}
+	case GlobalLocal:
+		globalHashMap, overflowHashMap := global_local_hashmap.GroupBy(records, options)
+		globalHashMap.Range(func(key string, value int) {
+			groups[key] += value
+		})
+		for _, cell := range overflowHashMap.Cells {
+			if cell.Key != "" {
+				groups[cell.Key] += cell.Value
+			}
+		}
+	case TwoLevel:
+		for _, hashMap := range two_level_hashmap.GroupBy(records, options).Buckets {
+			if hashMap == nil {
+				continue
+			}
+			for _, cell := range hashMap.Cells {
+				if cell.Key != "" {
+					groups[cell.Key] += cell.Value
+				}
+			}
+		}
+	default:
+		log.Fatalf("unknown strategy %s", strategy)
+	}
+	return groups
+}
@@ -0,0 +1,150 @@
+package auto
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/scheduler"
+	"group/base/skew"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// skewed makes half of rows (chosen randomly) to have the same key
+func skewed(records [][]string) [][]string {
+	random := rand.New(rand.NewSource(1))
+	for _, record := range records[1:] {
+		if random.Intn(2) == 0 {
+			record[3] = "hot"
+		}
+	}
+	return records
+}
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	GroupByOsAndSumByPopularity()
+}
+
+func TestGroupByChoosesStrategy(t *testing.T) {
+	options := scheduler.Options{Parallelism: 4}
+	for _, test := range []struct {
+		name     string
+		records  [][]string
+		strategy Strategy
+	}{
+		{"phones", base.Data(), OneCore},
+		{"small-cardinality", base.SyntheticData(100000, 100), LookupTable},
+		{"big-cardinality", base.SyntheticData(100000, 50000), TwoLevel},
+		{"small-cardinality-skewed", skewed(base.SyntheticData(100000, 500)), GlobalLocal},
+		{"big-cardinality-skewed", skewed(base.SyntheticData(100000, 50000)), TwoLevel},
+	} {
+		groups, plans := GroupBy(test.records, options)
+		require.Len(t, plans, 1, test.name)
+		require.Equal(t, test.strategy, plans[0].Strategy, test.name)
+		require.Equal(t, base.SumPopularityByOs(test.records), groups, test.name)
+	}
+
+	_, plans := GroupBy(base.SyntheticData(100000, 100), scheduler.Options{Parallelism: 1})
+	require.Equal(t, OneCore, plans[0].Strategy)
+}
+
+func TestGroupByReplansOnWrongEstimation(t *testing.T) {
+	// sampled rows and rows after probe have 10 keys, not sampled rows of probe have unique keys,
+	// so estimation from sample is small cardinality and probe proves it wrong
+	records := base.SyntheticData(100000, 10)
+	rows := len(records) - 1
+	sampled := make(map[int]bool)
+	for _, row := range skew.SampleRows(1, len(records), sampleSize) {
+		sampled[row] = true
+	}
+	for row := 1; row <= rows/probeFraction; row++ {
+		if !sampled[row] {
+			records[row][3] = "unique-" + strconv.Itoa(row)
+		}
+	}
+
+	groups, plans := GroupBy(records, scheduler.Options{Parallelism: 4})
+	require.Len(t, plans, 2)
+	require.Equal(t, LookupTable, plans[0].Strategy)
+	require.Equal(t, TwoLevel, plans[1].Strategy)
+	require.Greater(t, plans[1].Cardinality, plans[0].Cardinality)
+	require.Equal(t, base.SumPopularityByOs(records), groups)
+}
+
+func TestExecuteSkipsRowsWithoutKey(t *testing.T) {
+	// phones_data.csv has rows without os, no strategy makes group of empty key
+	records := base.Data()
+	for _, strategy := range []Strategy{OneCore, ThreadLocal, LookupTable, GlobalLocal, TwoLevel} {
+		groups := Execute(strategy, records, scheduler.Options{Parallelism: 4})
+		require.NotContains(t, groups, "", strategy)
+		require.Equal(t, base.SumPopularityByOs(records), groups, strategy)
+	}
+}
+
+func TestExecuteFallsBackIfDictionaryOverflows(t *testing.T) {
+	records := base.SyntheticData(100000, 70000)
+	require.Equal(t, base.SumPopularityByOs(records), Execute(LookupTable, records, scheduler.Options{Parallelism: 4}))
+}
+
+func TestCheckEstimation(t *testing.T) {
+	// underestimated
+	cardinality, wrong := checkEstimation(10, 1000, 10000, 80000)
+	require.True(t, wrong)
+	require.Equal(t, 8000, cardinality)
+	// overestimated, probe saw every group many times
+	cardinality, wrong = checkEstimation(20000, 10, 100000, 800000)
+	require.True(t, wrong)
+	require.Equal(t, 10, cardinality)
+	// probe of high cardinality data sees only part of groups
+	_, wrong = checkEstimation(20000, 5000, 10000, 80000)
+	require.False(t, wrong)
+}
+
+func TestEstimateCardinality(t *testing.T) {
+	sample := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		sample = append(sample, strconv.Itoa(i%10))
+	}
+	require.Equal(t, 10, estimateCardinality(sample, 100000))
+
+	for i := range sample {
+		sample[i] = strconv.Itoa(i)
+	}
+	require.Equal(t, 10000, estimateCardinality(sample, 100000))
+	require.Equal(t, 1000, estimateCardinality(sample, 1000))
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularity()
+	}
+}
+
+// automatically chosen strategy versus every strategy on different data sets
+func BenchmarkAutoVsStrategies(b *testing.B) {
+	options := scheduler.Options{Parallelism: 4}
+	for _, dataset := range []struct {
+		name    string
+		records [][]string
+	}{
+		{"small-cardinality", base.SyntheticData(200000, 100)},
+		{"small-cardinality-skewed", skewed(base.SyntheticData(200000, 500))},
+		{"big-cardinality", base.SyntheticData(200000, 50000)},
+		{"big-cardinality-skewed", skewed(base.SyntheticData(200000, 50000))},
+	} {
+		b.Run(fmt.Sprintf("%s/auto", dataset.name), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupBy(dataset.records, options)
+			}
+		})
+		for _, strategy := range []Strategy{ThreadLocal, LookupTable, GlobalLocal, TwoLevel} {
+			b.Run(fmt.Sprintf("%s/%s", dataset.name, strategy), func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					Execute(strategy, dataset.records, options)
+				}
+			})
+		}
+	}
+}
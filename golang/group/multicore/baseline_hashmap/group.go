@@ -1,28 +1,29 @@
 package baseline_hashmap
 
 import (
+	"fmt"
 	"group/base"
-	"group/base/buffer"
 	"group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/memory"
+	"group/base/scheduler"
 	"log"
 	"runtime"
-	"sync"
+	"sync/atomic"
 )
 
 func GroupByOsAndSumByPopularity() {
 	// use all cores on your machine
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	GroupByWorkingPool(GroupByOsAndSumByPopularityWorkerFn)
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
 }
 
-func GroupByOsAndSumByPopularityWorkerFn(job [][]string) *v1.HashTableWithLinearProbing {
-	hashMap := new(v1.HashTableWithLinearProbing).New()
-	for idx, record := range job {
-		// pass csv caption
-		if idx == 0 {
-			continue
-		}
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	GroupByWorkingPool(options, GroupByOsAndSumByPopularityWorkerFn)
+}
+
+func GroupByOsAndSumByPopularityWorkerFn(hashMap *v1.HashTableWithLinearProbing, job scheduler.Morsel) {
+	for _, record := range job {
 		phone := base.MapPhone(record)
 
 		if hashMap.ContainsKey(phone.Os) {
@@ -32,107 +33,68 @@ func GroupByOsAndSumByPopularityWorkerFn(job [][]string) *v1.HashTableWithLinear
 			hashMap.Put(phone.Os, phone.Popularity)
 		}
 	}
-	return hashMap
 }
 
-func workerPool(
-	jobs <-chan [][]string,
-	results chan<- v1.HashTableWithLinearProbing,
-	fnAggregate func(job [][]string) *v1.HashTableWithLinearProbing) {
-	var wg sync.WaitGroup
-
-	var jobNumber = 0
-	for j := range jobs {
-		wg.Add(1)
-		// we start a goroutine to run the job
-		go func(job [][]string, jobNumber int) {
-			hashMap := fnAggregate(job)
-
-			// for tracing purpose
-			/*
-				log.Printf("job number %d\n", jobNumber)
-				for _, cell := range hashMap.Cells {
-					if cell.Value > 0 {
-						log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
-					}
-				}
-				log.Println()
-			*/
-
-			wg.Done()
-
-			results <- *hashMap
-		}(j, jobNumber)
-		jobNumber++
-	}
-
-	wg.Wait()
-}
-
-func GroupByWorkingPool(fnAggregate func(job [][]string) *v1.HashTableWithLinearProbing) {
-	runtime.GOMAXPROCS(runtime.NumCPU())
-
+func GroupByWorkingPool(
+	options scheduler.Options,
+	fnAggregate func(hashMap *v1.HashTableWithLinearProbing, job scheduler.Morsel)) {
 	results := base.Data()
-	var numbJobs = runtime.NumCPU() / 2
-	ratio := len(results) / numbJobs
+	hashTable := groupBy(results, options, fnAggregate)
 
-	buf := buffer.DataBuffer{}
-
-	// fill up buffer with data
-	for _, result := range results {
-		err := buf.WriteLine(result)
-		if err != nil {
-			return
+	// print out result
+	for _, cell := range hashTable.Cells {
+		if cell.Key != "" {
+			log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
 		}
 	}
+	log.Println()
+}
 
-	jobs := make(chan [][]string, numbJobs)
-	hashTableAsResult := make(chan v1.HashTableWithLinearProbing)
-	hashTables := make([]v1.HashTableWithLinearProbing, 0)
+// GroupBy groups records by os and sums popularity, result is thread-local tables merged to one
+func GroupBy(records [][]string, options scheduler.Options) *v1.HashTableWithLinearProbing {
+	return groupBy(records, options, GroupByOsAndSumByPopularityWorkerFn)
+}
 
-	// define jobs
-	for j := 0; j < numbJobs; j++ {
-		if j == numbJobs-1 {
-			reminder := len(results) - ratio*numbJobs
-			ratio = ratio + reminder
+// GroupByWithTracker is GroupBy reporting memory of thread-local tables to child trackers of tracker (one per worker),
+// memory.ErrMemoryLimitExceeded is returned if any limit is exceeded
+func GroupByWithTracker(records [][]string, options scheduler.Options,
+	tracker *memory.Tracker) (*v1.HashTableWithLinearProbing, error) {
+	var workers atomic.Int32
+	hashTables := aggregate(records, options, func() *v1.HashTableWithLinearProbing {
+		workerTracker := tracker.Child(fmt.Sprintf("worker-%d", workers.Add(1)-1), 0)
+		return new(v1.HashTableWithLinearProbing).NewWithTracker(workerTracker)
+	}, GroupByOsAndSumByPopularityWorkerFn)
+	for _, hashTable := range hashTables {
+		if err := hashTable.Err(); err != nil {
+			return nil, err
 		}
-		resultSlice := buf.Next(ratio)
-		jobs <- resultSlice
 	}
 
-	go workerPool(jobs, hashTableAsResult, fnAggregate)
-
-	close(jobs)
-
-	// aggregation phase
-	for r := 0; r < numbJobs; r++ {
-		hashTables = append(hashTables, <-hashTableAsResult)
+	result := v1.ParallelMerge(hashTables, options.Workers())
+	for _, hashTable := range hashTables {
+		hashTable.Release()
 	}
+	return result, nil
+}
 
-	// merge phase
-	for idx, table := range hashTables {
-		// merge with first table
-		if idx == 0 {
-			continue
-		}
-		primaryTable := hashTables[0]
-		for _, cell := range table.Cells {
-			primaryValue := cell.Value
-			if cell.Key != "" && primaryTable.ContainsKey(cell.Key) {
-				primaryCell := primaryTable.Get(cell.Key)
-				primaryValue = primaryCell.Value + cell.Value
-			}
-			primaryTable.Put(cell.Key, primaryValue)
-		}
-	}
+func groupBy(records [][]string, options scheduler.Options,
+	fnAggregate func(hashMap *v1.HashTableWithLinearProbing, job scheduler.Morsel)) *v1.HashTableWithLinearProbing {
+	hashTables := aggregate(records, options, func() *v1.HashTableWithLinearProbing {
+		return new(v1.HashTableWithLinearProbing).New()
+	}, fnAggregate)
 
-	close(hashTableAsResult)
+	// merge phase - tables are split by ranges of cells and merged in parallel
+	return v1.ParallelMerge(hashTables, options.Workers())
+}
 
-	// print out result
-	for _, cell := range hashTables[0].Cells {
-		if cell.Key != "" {
-			log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
-		}
+// aggregate is aggregation phase - every worker of the pool aggregates morsels to its thread-local table
+func aggregate(records [][]string, options scheduler.Options, newTable func() *v1.HashTableWithLinearProbing,
+	fnAggregate func(hashMap *v1.HashTableWithLinearProbing, job scheduler.Morsel)) []*v1.HashTableWithLinearProbing {
+	morsels, err := scheduler.MakeMorsels(records, options)
+	if err != nil {
+		log.Fatalln(err)
 	}
-	log.Println()
+
+	hashTables, _ := scheduler.Run(morsels, options, newTable, fnAggregate)
+	return hashTables
 }
@@ -1,6 +1,10 @@
 package baseline_hashmap
 
-import "testing"
+import (
+	"fmt"
+	"group/base/scheduler"
+	"testing"
+)
 
 func TestGroupByOsAndSumByPopularity(t *testing.T) {
 	GroupByOsAndSumByPopularity()
@@ -11,3 +15,13 @@ func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
 		GroupByOsAndSumByPopularity()
 	}
 }
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
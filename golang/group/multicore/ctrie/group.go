@@ -0,0 +1,136 @@
+package ctrie
+
+import (
+	"group/base"
+	"group/base/batch"
+	"group/base/hamt"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/scheduler"
+	"log"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+All workers aggregate into one shared Ctrie (lock-free HAMT, see golang/group/base/hamt), there is no merge phase.
+Worker combines rows of morsel (columnar batch) in small local table first and adds partial sums of morsel to Ctrie,
+so the same key is updated by CAS once per morsel, not once per row. While workers go on, reporter takes read-only
+snapshots of Ctrie in O(1) to report consistent partial results.
+*/
+
+// DefaultReportInterval is interval between progress reports
+const DefaultReportInterval = 10 * time.Millisecond
+
+type Progress struct {
+	// Morsels is number of morsels fully added to snapshot at least, other morsels may be added partially
+	Morsels int
+	// Total is number of all morsels
+	Total int
+	// Snapshot is consistent read-only view of partial results
+	Snapshot *hamt.Ctrie[string, int]
+}
+
+func GroupByOsAndSumByPopularity() {
+	// use all cores on your machine
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	// prepare data
+	records := base.Data()
+	result := GroupByWithProgress(records, options, DefaultReportInterval, func(progress Progress) {
+		log.Printf("Processed %d of %d morsels, %d groups", progress.Morsels, progress.Total, progress.Snapshot.Size())
+	})
+
+	// print out result
+	result.Range(func(key string, value int) bool {
+		log.Printf("Popularity %d for group %s", value, key)
+		return true
+	})
+	log.Println()
+}
+
+// GroupBy groups records by os and sums popularity in shared Ctrie
+func GroupBy(records [][]string, options scheduler.Options) *hamt.Ctrie[string, int] {
+	return GroupByWithProgress(records, options, 0, nil)
+}
+
+// GroupByWithProgress is GroupBy reporting snapshot of partial results to fn every interval (if fn is set)
+func GroupByWithProgress(records [][]string, options scheduler.Options, interval time.Duration,
+	fn func(progress Progress)) *hamt.Ctrie[string, int] {
+	batches, err := scheduler.MakeBatches(records, base.PhonesSchema, options)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	result := hamt.NewCtrieWithStringKeys[int]()
+	var processed atomic.Int64
+
+	// reporter takes snapshots while workers aggregate
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	if fn != nil && interval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					// counter is read before snapshot, so snapshot has all these morsels
+					count := int(processed.Load())
+					fn(Progress{Morsels: count, Total: len(batches), Snapshot: result.ReadOnlySnapshot()})
+				}
+			}
+		}()
+	}
+
+	scheduler.Run(batches, options, func() struct{} {
+		return struct{}{}
+	}, func(_ struct{}, dataBatch *batch.Batch) {
+		osColumn, err := dataBatch.String(base.OsColumn)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		// combine rows of batch locally
+		localHashMap := new(v1.HashTableWithLinearProbing).New()
+		for idx := 0; idx < dataBatch.Rows; idx++ {
+			key := osColumn.Value(idx)
+			if key == "" {
+				continue
+			}
+			value := int(popularityColumn.Values[idx])
+			if cell := localHashMap.Get(key); cell != nil {
+				value += cell.Value
+			}
+			localHashMap.Put(key, value)
+		}
+
+		for _, cell := range localHashMap.Cells {
+			if cell.Key == "" {
+				continue
+			}
+			popularity := cell.Value
+			result.Update(cell.Key, func(value int, ok bool) int {
+				return value + popularity
+			})
+		}
+		processed.Add(1)
+	})
+	close(done)
+	wg.Wait()
+
+	return result
+}
@@ -0,0 +1,97 @@
+package ctrie
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/scheduler"
+	"group/multicore/global_local_hashmap"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	GroupByOsAndSumByPopularity()
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularity()
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
+
+func groups(result interface {
+	Range(fn func(key string, value int) bool)
+}) map[string]int {
+	actual := make(map[string]int)
+	result.Range(func(key string, value int) bool {
+		actual[key] = value
+		return true
+	})
+	return actual
+}
+
+func TestGroupBy(t *testing.T) {
+	for _, records := range [][][]string{base.Data(), base.SyntheticData(50000, 10000)} {
+		for _, parallelism := range []int{1, 8} {
+			result := GroupBy(records, scheduler.Options{Parallelism: parallelism})
+			require.Equal(t, base.SumPopularityByOs(records), groups(result))
+		}
+	}
+}
+
+func TestGroupByWithProgress(t *testing.T) {
+	records := base.SyntheticData(200000, 1000)
+	final := base.SumPopularityByOs(records)
+
+	var previous Progress
+	previousGroups := make(map[string]int)
+	reports := 0
+	result := GroupByWithProgress(records, scheduler.Options{Parallelism: 4, MorselSize: 256}, time.Millisecond,
+		func(progress Progress) {
+			reports++
+			require.True(t, progress.Snapshot.ReadOnly())
+			require.GreaterOrEqual(t, progress.Morsels, previous.Morsels)
+			require.LessOrEqual(t, progress.Morsels, progress.Total)
+
+			// partial sums grow up to final ones
+			snapshotGroups := groups(progress.Snapshot)
+			for key, value := range snapshotGroups {
+				require.GreaterOrEqual(t, value, previousGroups[key])
+				require.LessOrEqual(t, value, final[key])
+			}
+			previous, previousGroups = progress, snapshotGroups
+		})
+	require.Equal(t, final, groups(result))
+	t.Logf("%d progress reports", reports)
+}
+
+func BenchmarkCtrieVsGlobalLocal(b *testing.B) {
+	for _, cardinality := range []int{8, 1000, 100000} {
+		records := base.SyntheticData(200000, cardinality)
+		for _, parallelism := range []int{1, 2, 4, 8} {
+			options := scheduler.Options{Parallelism: parallelism}
+			b.Run(fmt.Sprintf("ctrie %d keys parallelism-%d", cardinality, parallelism), func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					GroupBy(records, options)
+				}
+			})
+			b.Run(fmt.Sprintf("global_local %d keys parallelism-%d", cardinality, parallelism), func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					global_local_hashmap.GroupBy(records, options)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package dictionary_encoded
+
+import (
+	"group/base"
+	"group/base/dictionary"
+	"group/base/scheduler"
+	"log"
+	"runtime"
+)
+
+const (
+	BrandNameColumn = 1
+	OsColumn        = 3
+)
+
+type Mode int
+
+const (
+	// PerBlock - every morsel is encoded by its own dictionary, codes are remapped on merge
+	PerBlock Mode = iota
+	// Global - all morsels are encoded by one shared dictionary
+	Global
+)
+
+// Result is aggregate of every code of dictionary
+type Result struct {
+	Dictionary *dictionary.Dictionary[uint32]
+	Sums       []int
+}
+
+// Range calls fn for every non-empty key, keys are decoded only here
+func (result *Result) Range(fn func(key string, value int)) {
+	for code, value := range result.Sums {
+		if key := result.Dictionary.Decode(uint32(code)); key != "" {
+			fn(key, value)
+		}
+	}
+}
+
+func GroupByOsAndSumByPopularity() {
+	// use all cores on your machine
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	// prepare data
+	records := base.Data()
+	result := GroupBy(records, options, OsColumn, PerBlock)
+
+	// print out result
+	result.Range(func(key string, value int) {
+		log.Printf("Popularity %d for group %s", value, key)
+	})
+	log.Println()
+}
+
+// addTo adds value to code of sums growing sums if needed
+func addTo(sums []int, code uint32, value int) []int {
+	for int(code) >= len(sums) {
+		sums = append(sums, 0)
+	}
+	sums[code] += value
+	return sums
+}
+
+// merge returns remapping of codes of other dictionary to codes of dictionary
+func merge(to *dictionary.Dictionary[uint32], other *dictionary.Dictionary[uint32]) []uint32 {
+	remapping, ok := to.Merge(other)
+	if !ok {
+		log.Fatalln(dictionary.ErrFull)
+	}
+	return remapping
+}
+
+type perBlockState struct {
+	dictionary *dictionary.Dictionary[uint32]
+	sums       []int
+}
+
+type globalState struct {
+	sums []int
+}
+
+// GroupBy groups records by string column and sums popularity, column is dictionary encoded and aggregated by codes
+func GroupBy(records [][]string, options scheduler.Options, column int, mode Mode) *Result {
+	morsels, err := scheduler.MakeMorsels(records, options)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if mode == Global {
+		return groupByGlobal(morsels, options, column)
+	}
+	return groupByPerBlock(morsels, options, column)
+}
+
+func groupByPerBlock(morsels []scheduler.Morsel, options scheduler.Options, column int) *Result {
+	states, _ := scheduler.Run(morsels, options, func() *perBlockState {
+		return &perBlockState{dictionary: dictionary.New[uint32]()}
+	}, func(state *perBlockState, morsel scheduler.Morsel) {
+		// encode and aggregate block by its own dictionary
+		encoded, err := dictionary.EncodeColumn(morsel, column)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		blockSums := make([]int, encoded.Dictionary.Size())
+		for idx, code := range encoded.Codes {
+			blockSums[code] += base.MapPhone(morsel[idx]).Popularity
+		}
+
+		// remap codes of block to codes of worker
+		remapping := merge(state.dictionary, encoded.Dictionary)
+		for code, value := range blockSums {
+			state.sums = addTo(state.sums, remapping[code], value)
+		}
+	})
+
+	// remap codes of workers to codes of result
+	result := &Result{Dictionary: dictionary.New[uint32]()}
+	for _, state := range states {
+		remapping := merge(result.Dictionary, state.dictionary)
+		for code, value := range state.sums {
+			result.Sums = addTo(result.Sums, remapping[code], value)
+		}
+	}
+	return result
+}
+
+func groupByGlobal(morsels []scheduler.Morsel, options scheduler.Options, column int) *Result {
+	global := dictionary.New[uint32]()
+	states, _ := scheduler.Run(morsels, options, func() *globalState {
+		return &globalState{}
+	}, func(state *globalState, morsel scheduler.Morsel) {
+		encoded, err := dictionary.EncodeColumnWith(morsel, column, global)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for idx, code := range encoded.Codes {
+			state.sums = addTo(state.sums, code, base.MapPhone(morsel[idx]).Popularity)
+		}
+	})
+
+	// codes are the same for all workers, merge is vector addition
+	result := &Result{Dictionary: global, Sums: make([]int, global.Size())}
+	for _, state := range states {
+		for code, value := range state.sums {
+			result.Sums[code] += value
+		}
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+package dictionary_encoded
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/scheduler"
+	"group/multicore/baseline_hashmap"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func expected(records [][]string, column int) map[string]int {
+	groups := make(map[string]int)
+	for _, record := range records[1:] {
+		if record[column] != "" {
+			groups[record[column]] += base.MapPhone(record).Popularity
+		}
+	}
+	return groups
+}
+
+func toMap(result *Result) map[string]int {
+	groups := make(map[string]int)
+	result.Range(func(key string, value int) {
+		groups[key] = value
+	})
+	return groups
+}
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	GroupByOsAndSumByPopularity()
+}
+
+func TestGroupBy(t *testing.T) {
+	options := scheduler.Options{Parallelism: 4, MorselSize: 100}
+	for _, records := range [][][]string{base.Data(), base.SyntheticData(20000, 500)} {
+		for _, column := range []int{OsColumn, BrandNameColumn} {
+			for _, mode := range []Mode{PerBlock, Global} {
+				require.Equal(t, expected(records, column), toMap(GroupBy(records, options, column, mode)))
+			}
+		}
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularity()
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
+
+// aggregation by dictionary codes versus thread-local hash tables of strings
+func BenchmarkDictionaryEncodedVsHashTable(b *testing.B) {
+	for _, cardinality := range []int{16, 1000} {
+		records := base.SyntheticData(200000, cardinality)
+		options := scheduler.Options{Parallelism: 4}
+		b.Run(fmt.Sprintf("cardinality-%d/per-block", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupBy(records, options, OsColumn, PerBlock)
+			}
+		})
+		b.Run(fmt.Sprintf("cardinality-%d/global", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupBy(records, options, OsColumn, Global)
+			}
+		})
+		b.Run(fmt.Sprintf("cardinality-%d/hash-table", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				baseline_hashmap.GroupBy(records, options)
+			}
+		})
+	}
+}
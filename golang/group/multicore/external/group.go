@@ -0,0 +1,125 @@
+package external
+
+import (
+	"errors"
+	"fmt"
+	"group/base"
+	"group/base/batch"
+	"group/base/hashmap/two_level"
+	"group/base/memory"
+	"group/base/scheduler"
+	"group/base/spill"
+	"log"
+	"runtime"
+	"sync/atomic"
+)
+
+// DefaultMemoryBudget is budget of aggregation state of all workers in bytes
+const DefaultMemoryBudget = 1 << 20
+
+func GroupByOsAndSumByPopularity() {
+	// use all cores on your machine
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	// prepare data
+	records := base.Data()
+	tracker := new(memory.Tracker).New("query", DefaultMemoryBudget)
+	stats, err := GroupBy(records, options, tracker, func(key string, popularity int) {
+		log.Printf("Popularity %d for group %s", popularity, key)
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Spilled %d times to %d runs, %d bytes", stats.Spills, stats.Runs, stats.SpilledBytes)
+	log.Printf("Memory of aggregation state:\n%s", tracker.Report())
+	log.Println()
+}
+
+// state is thread-local state of worker: two level table reporting its memory to tracker of worker
+type state struct {
+	tracker *memory.Tracker
+	table   *two_level.TwoLevelHashMap
+	err     error
+}
+
+// GroupBy groups records by os and sums popularity keeping aggregation state under limit of tracker (not limited
+// if limit is not positive): every worker has tracker with its share of limit for thread-local two level table,
+// when table exceeds it buckets of table are spilled to sorted run files. In the end runs are merged bucket by bucket
+// and fn is called for every key, keys of bucket come in sorted order.
+func GroupBy(records [][]string, options scheduler.Options, tracker *memory.Tracker,
+	fn func(key string, value int)) (spill.Stats, error) {
+	batches, err := scheduler.MakeBatches(records, base.PhonesSchema, options)
+	if err != nil {
+		return spill.Stats{}, err
+	}
+	spiller, err := new(spill.Spiller).New("")
+	if err != nil {
+		return spill.Stats{}, err
+	}
+	defer func() {
+		_ = spiller.Close()
+	}()
+	workerLimit := tracker.Limit() / int64(options.Workers())
+
+	var workers atomic.Int32
+	states, _ := scheduler.Run(batches, options, func() *state {
+		workerTracker := tracker.Child(fmt.Sprintf("worker-%d", workers.Add(1)-1), workerLimit)
+		return &state{tracker: workerTracker, table: new(two_level.TwoLevelHashMap).NewWithTracker(workerTracker)}
+	}, func(state *state, dataBatch *batch.Batch) {
+		if state.err != nil {
+			return
+		}
+		osColumn, err := dataBatch.String(base.OsColumn)
+		if err != nil {
+			state.err = err
+			return
+		}
+		popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+		if err != nil {
+			state.err = err
+			return
+		}
+
+		for idx := 0; idx < dataBatch.Rows; idx++ {
+			key := osColumn.Value(idx)
+			if key == "" {
+				continue
+			}
+			popularity := int(popularityColumn.Values[idx])
+			if cell := state.table.Get(key); cell != nil {
+				popularity += cell.Value
+			}
+			state.table.Put(key, popularity)
+		}
+
+		// spill whole table when it exceeds limit of worker
+		if errors.Is(state.table.Err(), memory.ErrMemoryLimitExceeded) {
+			state.err = spiller.Spill(state.table)
+			state.table.Release()
+			state.table = new(two_level.TwoLevelHashMap).NewWithTracker(state.tracker)
+		}
+	})
+
+	tables := make([]*two_level.TwoLevelHashMap, 0, len(states))
+	for _, state := range states {
+		if state.err != nil {
+			return spiller.Stats(), state.err
+		}
+		tables = append(tables, state.table)
+	}
+
+	// merge phase - runs and rest of tables in memory are merged bucket by bucket
+	for bucketId := 0; bucketId < two_level.NumBuckets; bucketId++ {
+		if err = spiller.Merge(bucketId, tables, fn); err != nil {
+			return spiller.Stats(), err
+		}
+	}
+	for _, table := range tables {
+		table.Release()
+	}
+	return spiller.Stats(), nil
+}
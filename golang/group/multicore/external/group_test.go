@@ -0,0 +1,85 @@
+package external
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/memory"
+	"group/base/scheduler"
+	"group/multicore/two_level_hashmap"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	GroupByOsAndSumByPopularity()
+}
+
+func TestGroupBy(t *testing.T) {
+	records := base.SyntheticData(100000, 20000)
+	expected := make(map[string]int)
+	for _, bucket := range two_level_hashmap.GroupBy(records, scheduler.Options{Parallelism: 1}).Buckets {
+		if bucket == nil {
+			continue
+		}
+		for _, cell := range bucket.Cells {
+			if cell.Key != "" {
+				expected[cell.Key] = cell.Value
+			}
+		}
+	}
+
+	for _, memoryBudget := range []int64{0, 1 << 30, 512 << 10} {
+		actual := make(map[string]int)
+		tracker := new(memory.Tracker).New("query", memoryBudget)
+		stats, err := GroupBy(records, scheduler.Options{Parallelism: 4, MorselSize: 1000}, tracker,
+			func(key string, value int) {
+				_, duplicate := actual[key]
+				require.False(t, duplicate)
+				actual[key] = value
+			})
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+		require.Zero(t, tracker.Used())
+		if memoryBudget == 512<<10 {
+			require.Greater(t, stats.Spills, 0)
+			// limit is exceeded by one resize of bucket at most before spill
+			require.Less(t, tracker.Peak(), 2*memoryBudget)
+		} else {
+			require.Zero(t, stats.Spills)
+		}
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularity()
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
+
+// cost of spilling with decreasing memory budget
+func BenchmarkMemoryBudget(b *testing.B) {
+	records := base.SyntheticData(200000, 50000)
+	for _, memoryBudget := range []int64{0, 4 << 20, 1 << 20, 512 << 10} {
+		b.Run(fmt.Sprintf("budget-%d", memoryBudget), func(b *testing.B) {
+			var stats float64
+			for n := 0; n < b.N; n++ {
+				tracker := new(memory.Tracker).New("query", memoryBudget)
+				result, err := GroupBy(records, scheduler.Options{Parallelism: 4}, tracker, func(string, int) {})
+				require.NoError(b, err)
+				stats += float64(result.Runs)
+			}
+			b.ReportMetric(stats/float64(b.N), "runs")
+		})
+	}
+}
@@ -2,9 +2,9 @@ package global_local_hashmap
 
 import (
 	"group/base"
-	"group/base/buffer"
 	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
 	v2 "group/base/hashmap/open_addressing/linear_probing/v2"
+	"group/base/scheduler"
 	"log"
 	"runtime"
 )
@@ -13,88 +13,77 @@ func GroupByOsAndSumByPopularity() {
 	// use all cores on your machine
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	GroupByThreads()
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
 }
 
-func GroupByThreads() {
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	GroupByThreads(options)
+}
+
+func GroupByThreads(options scheduler.Options) {
 	// prepare data
 	records := base.Data()
-	dataBlocks, err := buffer.MakePartitioning(records)
+	globalHashMap, overflowHashMap := GroupBy(records, options)
+
+	// print out result
+	globalHashMap.Range(func(key string, value int) {
+		log.Printf("Popularity %d for group %s", value, key)
+	})
+	for _, cell := range overflowHashMap.Cells {
+		if cell.Key != "" {
+			log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
+		}
+	}
+	log.Println()
+}
+
+// GroupBy groups records by os and sums popularity. Result is shared global table and table of keys which did not
+// fit in global table, sets of keys in both tables are distinct.
+func GroupBy(records [][]string, options scheduler.Options) (*v2.HashTableWithLinearProbing, *v1.HashTableWithLinearProbing) {
+	morsels, err := scheduler.MakeMorsels(records, options)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	hashTableAsResult := make(chan v1.HashTableWithLinearProbing)
 	globalHashMap := new(v2.HashTableWithLinearProbing).New()
 
-	for _, block := range dataBlocks {
-		blockToRead := block.Read()
-		// start thread-local table
-		go func() {
-			localHashMap := new(v1.HashTableWithLinearProbing).New()
-			for _, record := range blockToRead {
+	// every worker of the pool owns thread-local table
+	hashTables, _ := scheduler.Run(morsels, options, func() *v1.HashTableWithLinearProbing {
+		return new(v1.HashTableWithLinearProbing).New()
+	}, func(localHashMap *v1.HashTableWithLinearProbing, morsel scheduler.Morsel) {
+		for _, record := range morsel {
 
-				phone := base.MapPhone(record)
-				if phone.Os == "" {
-					continue
-				}
-
-				if localHashMap.ContainsKey(phone.Os) {
-					localHashMap.Put(phone.Os, localHashMap.Get(phone.Os).Value+phone.Popularity)
-				} else {
-					globalPopularity := phone.Popularity
-					if globalHashMap.Get(phone.Os) != nil {
-						globalPopularity += globalHashMap.Get(phone.Os).Value
-					}
-					if globalHashMap.Put(phone.Os, globalPopularity) == v2.BreakerOpened {
-						localPopularity := phone.Popularity
-						if localHashMap.Get(phone.Os) != nil {
-							localPopularity += localHashMap.Get(phone.Os).Value
-						}
-						localHashMap.Put(phone.Os, localPopularity)
-					}
-				}
+			phone := base.MapPhone(record)
+			if phone.Os == "" {
+				continue
 			}
-			hashTableAsResult <- *localHashMap
-		}()
-	}
 
-	hashTables := make([]v1.HashTableWithLinearProbing, 0)
-	for taskId := 0; taskId < len(dataBlocks); taskId++ {
-		hashTables = append(hashTables, <-hashTableAsResult)
-		// for debugging purpose
-		/*
-			select {
-			case msg := <-hashTableAsResult:
-				fmt.Printf("got %v from worker channel\n", msg)
+			// look first into local table for key, then try global one
+			if localCell := localHashMap.Get(phone.Os); localCell != nil {
+				localCell.Value += phone.Popularity
+			} else if globalHashMap.Add(phone.Os, phone.Popularity) != v2.BreakerClosed {
+				// cell is locked by other worker or collision resolution chain is too long
+				localHashMap.Put(phone.Os, phone.Popularity)
 			}
-		*/
-	}
-
-	// merge phase
-	for idx, table := range hashTables {
-		// merge with first table
-		if idx == 0 {
-			continue
 		}
-		primaryTable := globalHashMap
+	})
+
+	// merge phase - local tables are small, since only hot keys get there
+	overflowHashMap := new(v1.HashTableWithLinearProbing).New()
+	for _, table := range hashTables {
 		for _, cell := range table.Cells {
-			primaryValue := cell.Value
-			if cell.Key != "" && primaryTable.ContainsKey(cell.Key) {
-				primaryCell := primaryTable.Get(cell.Key)
-				primaryValue = primaryCell.Value + cell.Value
+			if cell.Key == "" {
+				continue
+			}
+			if globalHashMap.AddSpin(cell.Key, cell.Value) == v2.TableFull {
+				value := cell.Value
+				if overflowCell := overflowHashMap.Get(cell.Key); overflowCell != nil {
+					value += overflowCell.Value
+				}
+				overflowHashMap.Put(cell.Key, value)
 			}
-			primaryTable.Put(cell.Key, primaryValue)
 		}
 	}
 
-	close(hashTableAsResult)
-
-	// print out result
-	for _, cell := range globalHashMap.Cells {
-		if cell.Key != "" {
-			log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
-		}
-	}
-	log.Println()
+	return globalHashMap, overflowHashMap
 }
@@ -1,6 +1,13 @@
 package global_local_hashmap
 
-import "testing"
+import (
+	"fmt"
+	"group/base"
+	"group/base/scheduler"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 func TestGroupByOsAndSumByPopularity(t *testing.T) {
 	GroupByOsAndSumByPopularity()
@@ -11,3 +18,39 @@ func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
 		GroupByOsAndSumByPopularity()
 	}
 }
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	for _, records := range [][][]string{base.Data(), base.SyntheticData(50000, 10000)} {
+		expected := make(map[string]int)
+		for _, record := range records[1:] {
+			phone := base.MapPhone(record)
+			if phone.Os != "" {
+				expected[phone.Os] += phone.Popularity
+			}
+		}
+
+		globalHashMap, overflowHashMap := GroupBy(records, scheduler.Options{Parallelism: 8})
+		groups := make(map[string]int)
+		globalHashMap.Range(func(key string, value int) {
+			groups[key] = value
+		})
+		for _, cell := range overflowHashMap.Cells {
+			if cell.Key != "" {
+				_, duplicate := groups[cell.Key]
+				require.False(t, duplicate)
+				groups[cell.Key] = cell.Value
+			}
+		}
+		require.Equal(t, expected, groups)
+	}
+}
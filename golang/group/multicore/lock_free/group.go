@@ -0,0 +1,52 @@
+package lock_free
+
+import (
+	"group/base"
+	"group/base/hashmap/open_addressing/lock_free"
+	"group/base/scheduler"
+	"log"
+	"runtime"
+)
+
+func GroupByOsAndSumByPopularity() {
+	// use all cores on your machine
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	// prepare data
+	records := base.Data()
+	hashTable := GroupBy(records, options)
+
+	// print out result
+	hashTable.Range(func(key string, value int64) {
+		log.Printf("Popularity %d for group %s", value, key)
+	})
+	log.Printf("Table resized %d times", hashTable.Resizes())
+	log.Println()
+}
+
+// GroupBy groups records by os and sums popularity, all workers aggregate into one shared lock-free table
+func GroupBy(records [][]string, options scheduler.Options) *lock_free.LockFreeHashTable {
+	morsels, err := scheduler.MakeMorsels(records, options)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	hashTable := new(lock_free.LockFreeHashTable).New()
+	scheduler.Run(morsels, options, func() struct{} {
+		return struct{}{}
+	}, func(_ struct{}, morsel scheduler.Morsel) {
+		for _, record := range morsel {
+			phone := base.MapPhone(record)
+			if phone.Os == "" {
+				continue
+			}
+			hashTable.Add(phone.Os, int64(phone.Popularity))
+		}
+	})
+
+	return hashTable
+}
@@ -0,0 +1,88 @@
+package lock_free
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/scheduler"
+	"group/multicore/striped_mutex"
+	"group/multicore/two_level_hashmap"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	GroupByOsAndSumByPopularity()
+}
+
+func TestGroupBy(t *testing.T) {
+	hashTable := GroupBy(base.Data(), scheduler.Options{Parallelism: 4})
+
+	android, ok := hashTable.Get("Android")
+	require.True(t, ok)
+	require.Equal(t, int64(575172), android)
+	ios, ok := hashTable.Get("iOS")
+	require.True(t, ok)
+	require.Equal(t, int64(89433), ios)
+	require.Equal(t, 6, hashTable.Size())
+}
+
+func TestGroupByHighCardinality(t *testing.T) {
+	records := base.SyntheticData(50000, 10000)
+	expected := map[string]int64{}
+	for _, record := range records[1:] {
+		phone := base.MapPhone(record)
+		expected[phone.Os] += int64(phone.Popularity)
+	}
+
+	hashTable := GroupBy(records, scheduler.Options{Parallelism: 8, MorselSize: 512})
+	actual := map[string]int64{}
+	hashTable.Range(func(key string, value int64) {
+		actual[key] = value
+	})
+	require.Equal(t, expected, actual)
+	require.Positive(t, hashTable.Resizes())
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularity()
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
+
+// lock-free shared table versus shared table with striped mutexes and thread-local two level tables
+func BenchmarkLockFreeVsMutexVsThreadLocal(b *testing.B) {
+	for _, cardinality := range []int{16, 1000, 100000} {
+		records := base.SyntheticData(200000, cardinality)
+		for _, parallelism := range []int{2, 8} {
+			options := scheduler.Options{Parallelism: parallelism}
+			name := "cardinality-" + strconv.Itoa(cardinality) + "/parallelism-" + strconv.Itoa(parallelism)
+			b.Run(name+"/lock-free", func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					GroupBy(records, options)
+				}
+			})
+			b.Run(name+"/striped-mutex", func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					striped_mutex.GroupBy(records, options)
+				}
+			})
+			b.Run(name+"/thread-local", func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					two_level_hashmap.GroupBy(records, options)
+				}
+			})
+		}
+	}
+}
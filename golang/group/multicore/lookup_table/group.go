@@ -0,0 +1,66 @@
+package lookup_table
+
+import (
+	"group/base"
+	"group/base/dictionary"
+	"group/base/lookup_table"
+	"group/base/scheduler"
+	onecore "group/onecore/lookup_table"
+	"log"
+	"runtime"
+	"sync/atomic"
+)
+
+func GroupByOsAndSumByPopularity() {
+	// use all cores on your machine
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	// prepare data
+	records := base.Data()
+	osDictionary := dictionary.New[uint16]()
+	table, err := GroupBy(records, options, onecore.OsKey(osDictionary))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// print out result
+	table.Range(func(key uint16, value int) {
+		log.Printf("Popularity %d for group %s", value, osDictionary.Decode(key))
+	})
+	log.Println()
+}
+
+// GroupBy groups records by key and sums popularity, every worker aggregates to thread-local lookup table and
+// tables are merged by vector addition
+func GroupBy(records [][]string, options scheduler.Options,
+	keyFn onecore.KeyFn) (*lookup_table.LookupTable[uint16], error) {
+	morsels, err := scheduler.MakeMorsels(records, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var outOfRange atomic.Bool
+	tables, _ := scheduler.Run(morsels, options, lookup_table.New[uint16],
+		func(table *lookup_table.LookupTable[uint16], morsel scheduler.Morsel) {
+			for _, record := range morsel {
+				phone := base.MapPhone(record)
+				key, ok, err := keyFn(phone)
+				if err != nil {
+					outOfRange.Store(true)
+					return
+				}
+				if ok {
+					table.Add(key, phone.Popularity)
+				}
+			}
+		})
+	if outOfRange.Load() {
+		return nil, onecore.ErrKeyOutOfRange
+	}
+
+	return lookup_table.ParallelMerge(tables, options), nil
+}
@@ -0,0 +1,70 @@
+package lookup_table
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/dictionary"
+	"group/base/scheduler"
+	"group/multicore/baseline_hashmap"
+	onecore "group/onecore/lookup_table"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	GroupByOsAndSumByPopularity()
+}
+
+func TestGroupByMatchesOneCore(t *testing.T) {
+	records := base.SyntheticData(50000, 1000)
+	for _, keyFn := range []onecore.KeyFn{onecore.SellersAmountKey, onecore.ReleaseYearKey,
+		onecore.OsKey(dictionary.New[uint16]())} {
+		expected, err := onecore.GroupBy(records, keyFn)
+		require.NoError(t, err)
+		table, err := GroupBy(records, scheduler.Options{Parallelism: 4, MorselSize: 1000}, keyFn)
+		require.NoError(t, err)
+		require.Equal(t, expected.Values, table.Values)
+		require.Equal(t, expected.Counts, table.Counts)
+		require.Equal(t, expected.Size(), table.Size())
+	}
+
+	_, err := GroupBy(records, scheduler.Options{Parallelism: 4}, func(phone base.Phone) (uint16, bool, error) {
+		return 0, false, onecore.ErrKeyOutOfRange
+	})
+	require.ErrorIs(t, err, onecore.ErrKeyOutOfRange)
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularity()
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
+
+// dictionary encoded lookup tables versus thread-local hash tables
+func BenchmarkLookupTableVsHashTable(b *testing.B) {
+	for _, cardinality := range []int{16, 1000} {
+		records := base.SyntheticData(200000, cardinality)
+		options := scheduler.Options{Parallelism: 4}
+		b.Run(fmt.Sprintf("cardinality-%d/lookup-table", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				_, _ = GroupBy(records, options, onecore.OsKey(dictionary.New[uint16]()))
+			}
+		})
+		b.Run(fmt.Sprintf("cardinality-%d/hash-table", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				baseline_hashmap.GroupBy(records, options)
+			}
+		})
+	}
+}
@@ -2,13 +2,12 @@ package parititioning
 
 import (
 	"group/base"
-	"group/base/buffer"
-	"group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/batch"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/scheduler"
+	"group/base/skew"
 	"log"
 	"runtime"
-	"strconv"
-	"sync"
-	"unsafe"
 )
 
 func GroupByOsAndSumByPopularity() {
@@ -18,131 +17,227 @@ func GroupByOsAndSumByPopularity() {
 	// very simple explanation of bucket placement algorithm
 	// simpleExampleOfTasksToBucket
 
-	GroupByDataBlocks()
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
 }
 
-func GroupByDataBlocks() {
-	// define bucket size, please see explanation inside
-	var numBuckets = buffer.DefineBucketSize()
-
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
 	// prepare data
 	records := base.Data()
-	dataBlocks, err := buffer.MakePartitioning(records)
-	if err != nil {
-		log.Fatalln(err)
+	partitions, report := GroupBySkewAware(records, options, skew.DefaultOptions())
+
+	// print out detected skew
+	log.Printf("Sampled %d keys, skew %.2f, skew with salting %.2f", report.Sampled, report.Skew, report.SaltedSkew)
+	for _, heavyHitter := range report.HeavyHitters {
+		log.Printf("Heavy hitter %s with share %.2f salted over %d partitions",
+			heavyHitter.Key, heavyHitter.Share, heavyHitter.Salts)
 	}
 
-	/*
-		Phase 1 - mark data blocks to buckets in parallel
-	*/
-	var wg sync.WaitGroup
-
-	var bucketToGroupMap, bucketToTaskMap sync.Map
-	taskNumber := 0
-	for _, block := range dataBlocks {
-		blockToRead := block.Read()
-		wg.Add(1)
-		// mark buckets in blocks
-		go func() {
-			for _, record := range blockToRead {
-				key := record[3]
-				if key == "" {
-					continue
-				}
+	// print out result - partitions have distinct keys, so result is just concatenation of them
+	for _, hashMap := range partitions {
+		for _, cell := range hashMap.Cells {
+			if cell.Key != "" {
+				log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
+			}
+		}
+	}
+	log.Println()
+}
+
+func add(hashMap *v1.HashTableWithLinearProbing, key string, value int) {
+	if cell := hashMap.Get(key); cell != nil {
+		value += cell.Value
+	}
+	hashMap.Put(key, value)
+}
 
-				// simple hash to make bucket as `hash: key -> bucket_num`
-				data := []byte(key)
-				toHash := *(*uint64)(unsafe.Pointer(&data[0]))
-				bucketId := hash(toHash, numBuckets)
+// columns returns os and popularity columns of batch
+func columns(dataBatch *batch.Batch) (*batch.StringColumn, *batch.Int64Column) {
+	osColumn, err := dataBatch.String(base.OsColumn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return osColumn, popularityColumn
+}
 
-				// mark bucket number in records
-				record[13] = strconv.Itoa(bucketId)
+// noPartition marks row without key
+const noPartition = -1
 
-				_, ok := bucketToGroupMap.Load(bucketId)
-				if !ok {
-					bucketToGroupMap.Store(bucketId, key)
-				}
+// partitionOf maps key to partition by hash function independent of one used in the hash table
+// (otherwise all keys of the partition would share the same low bits of hash and cluster in the table)
+func partitionOf(key string, partitions int) int {
+	return hash(v1.HashStringKey(key), partitions-1)
+}
 
-				_, ok = bucketToTaskMap.Load(bucketId)
-				if !ok {
-					bucketToTaskMap.Store(bucketId, taskNumber)
-					taskNumber++
-				}
-			}
-			wg.Done()
-		}()
+// GroupBy groups records by os and sums popularity in two phases, result is table per partition with disjoint keys
+func GroupBy(records [][]string, options scheduler.Options) []*v1.HashTableWithLinearProbing {
+	batches, err := scheduler.MakeBatches(records, base.PhonesSchema, options)
+	if err != nil {
+		log.Fatalln(err)
 	}
-
-	wg.Wait()
+	numPartitions := options.Workers()
 
 	/*
-		Phase 2 - aggregate by bucket number in parallel
+		Phase 1 - one scan over data blocks in parallel: hash every key once and remember partition of the row
+		in side array of the block, rows are not touched
 	*/
-	aggregateChannel := make(chan int)
-	tasksToAggregation := new(v1.HashTableWithLinearProbing).New()
-
-	for taskId := 0; taskId < lenSyncMap(&bucketToGroupMap); taskId++ {
-		go func(task int) {
-			for _, block := range dataBlocks {
-				blockToRead := block.Read()
+	rowPartitions := make([][]int32, len(batches))
+	scheduler.ForEach(len(batches), options, func(blockId int) {
+		osColumn, _ := columns(batches[blockId])
+		blockPartitions := make([]int32, batches[blockId].Rows)
+		for idx := range blockPartitions {
+			key := osColumn.Value(idx)
+			if key == "" {
+				blockPartitions[idx] = noPartition
+				continue
+			}
+			blockPartitions[idx] = int32(partitionOf(key, numPartitions))
+		}
+		rowPartitions[blockId] = blockPartitions
+	})
 
-				for _, record := range blockToRead {
+	/*
+		Phase 2 - every worker owns one partition and its own table, it reads side arrays and columns of rows
+		of its partition
+	*/
+	partitions := make([]*v1.HashTableWithLinearProbing, numPartitions)
+	scheduler.ForEach(numPartitions, options, func(partition int) {
+		hashMap := new(v1.HashTableWithLinearProbing).New()
+		for blockId, dataBatch := range batches {
+			osColumn, popularityColumn := columns(dataBatch)
+			for idx, rowPartition := range rowPartitions[blockId] {
+				if int(rowPartition) != partition {
+					continue
+				}
+				add(hashMap, osColumn.Value(idx), int(popularityColumn.Values[idx]))
+			}
+		}
+		partitions[partition] = hashMap
+	})
 
-					phone := base.MapPhone(record)
+	return partitions
+}
 
-					if phone.Os == "" || phone.BucketId == 0 {
-						continue
-					}
+// GroupByOnePhase is one phase variant of GroupBy - there is no side array, every worker scans all rows and
+// hashes every key again to take rows of its partition. Works if hashing is cheaper than memory bandwidth of side array.
+func GroupByOnePhase(records [][]string, options scheduler.Options) []*v1.HashTableWithLinearProbing {
+	batches, err := scheduler.MakeBatches(records, base.PhonesSchema, options)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	numPartitions := options.Workers()
+
+	partitions := make([]*v1.HashTableWithLinearProbing, numPartitions)
+	scheduler.ForEach(numPartitions, options, func(partition int) {
+		hashMap := new(v1.HashTableWithLinearProbing).New()
+		for _, dataBatch := range batches {
+			osColumn, popularityColumn := columns(dataBatch)
+			for idx := 0; idx < dataBatch.Rows; idx++ {
+				key := osColumn.Value(idx)
+				if key == "" || partitionOf(key, numPartitions) != partition {
+					continue
+				}
+				add(hashMap, key, int(popularityColumn.Values[idx]))
+			}
+		}
+		partitions[partition] = hashMap
+	})
 
-					jobNumber, _ := bucketToTaskMap.Load(phone.BucketId)
-					if task != jobNumber {
-						continue
-					}
+	return partitions
+}
 
-					group, ok := bucketToGroupMap.Load(phone.BucketId)
-					if ok {
-						value := phone.Popularity
-						if tasksToAggregation.ContainsKey(group.(string)) {
-							value = value + tasksToAggregation.Get(group.(string)).Value
-						}
+// GroupBySkewAware is GroupBy which splits hot keys across workers: sample of keys is taken to detect heavy hitters,
+// rows of heavy hitter are salted over several partitions and partial aggregates of heavy hitter are combined
+// in its home partition in the end. Report of detected skew is returned together with partitions.
+func GroupBySkewAware(records [][]string, options scheduler.Options,
+	skewOptions skew.Options) ([]*v1.HashTableWithLinearProbing, skew.Report) {
+	batches, err := scheduler.MakeBatches(records, base.PhonesSchema, options)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	numPartitions := options.Workers()
+	homeOf := func(key string) int {
+		return partitionOf(key, numPartitions)
+	}
 
-						tasksToAggregation.Put(group.(string), value)
-					}
-				}
-			}
-			aggregateChannel <- task
-		}(taskId)
+	/*
+		Phase 0 - detect heavy hitters on sample of keys
+	*/
+	sample := make([]string, 0, skewOptions.SampleSize)
+	for _, row := range skew.SampleRows(1, len(records), skewOptions.SampleSize) {
+		if key := records[row][base.OsColumn]; key != "" {
+			sample = append(sample, key)
+		}
 	}
+	report := skew.Detect(sample, numPartitions, homeOf, skewOptions)
 
-	for taskId := 0; taskId < lenSyncMap(&bucketToGroupMap); taskId++ {
-		<-aggregateChannel
-		// for debugging purpose
-		/*
-			select {
-			case msg := <-aggregateChannel:
-				fmt.Printf("got %v from worker channel\n", msg)
+	/*
+		Phase 1 - same as in GroupBy, side array keeps partition of row and index of heavy hitter (if key is heavy)
+		as partition + numPartitions * (heavyHitter + 1)
+	*/
+	rowPartitions := make([][]int32, len(batches))
+	scheduler.ForEach(len(batches), options, func(blockId int) {
+		osColumn, _ := columns(batches[blockId])
+		blockPartitions := make([]int32, batches[blockId].Rows)
+		for idx := range blockPartitions {
+			key := osColumn.Value(idx)
+			if key == "" {
+				blockPartitions[idx] = noPartition
+				continue
 			}
-		*/
-	}
+			home := homeOf(key)
+			heavyHitter, heavy := report.HeavyHitter(key)
+			if !heavy {
+				blockPartitions[idx] = int32(home)
+				continue
+			}
+			partition := report.Partition(key, home, idx, numPartitions)
+			blockPartitions[idx] = int32(partition + numPartitions*(heavyHitter+1))
+		}
+		rowPartitions[blockId] = blockPartitions
+	})
 
-	close(aggregateChannel)
+	/*
+		Phase 2 - every worker aggregates its partition, partial aggregates of heavy hitters are kept aside
+	*/
+	partitions := make([]*v1.HashTableWithLinearProbing, numPartitions)
+	heavyPartials := make([][]int, numPartitions)
+	scheduler.ForEach(numPartitions, options, func(partition int) {
+		hashMap := new(v1.HashTableWithLinearProbing).New()
+		partials := make([]int, len(report.HeavyHitters))
+		for blockId, dataBatch := range batches {
+			osColumn, popularityColumn := columns(dataBatch)
+			for idx, rowPartition := range rowPartitions[blockId] {
+				if rowPartition == noPartition || int(rowPartition)%numPartitions != partition {
+					continue
+				}
+				popularity := int(popularityColumn.Values[idx])
+				if heavyHitter := int(rowPartition)/numPartitions - 1; heavyHitter >= 0 {
+					partials[heavyHitter] += popularity
+					continue
+				}
+				add(hashMap, osColumn.Value(idx), popularity)
+			}
+		}
+		partitions[partition] = hashMap
+		heavyPartials[partition] = partials
+	})
 
-	// print out result
-	for _, cell := range tasksToAggregation.Cells {
-		if cell.Key != "" {
-			log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
+	/*
+		Combine - partial aggregates of heavy hitter go to its home partition, so partitions keep disjoint keys
+	*/
+	for heavyHitter, hitter := range report.HeavyHitters {
+		total := 0
+		for _, partials := range heavyPartials {
+			total += partials[heavyHitter]
 		}
+		add(partitions[homeOf(hitter.Key)], hitter.Key, total)
 	}
-	log.Println()
-}
 
-func lenSyncMap(m *sync.Map) int {
-	var i int
-	m.Range(func(k, v interface{}) bool {
-		i++
-		return true
-	})
-	return i
+	return partitions, report
 }
 
 func simpleExampleOfTasksToBucket() {
@@ -1,13 +1,117 @@
 package parititioning
 
-import "testing"
+import (
+	"fmt"
+	"group/base"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/scheduler"
+	"group/base/skew"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 func TestGroupByOsAndSumByPopularity(t *testing.T) {
 	GroupByOsAndSumByPopularity()
 }
 
+func TestGroupByMatchesSequentialAggregation(t *testing.T) {
+	for name, groupBy := range map[string]func([][]string, scheduler.Options) []*v1.HashTableWithLinearProbing{
+		"two-phase": GroupBy,
+		"one-phase": GroupByOnePhase,
+		"skew-aware": func(records [][]string, options scheduler.Options) []*v1.HashTableWithLinearProbing {
+			partitions, _ := GroupBySkewAware(records, options, skew.DefaultOptions())
+			return partitions
+		},
+	} {
+		for _, records := range [][][]string{base.Data(), base.SyntheticData(20000, 5000)} {
+			options := scheduler.Options{Parallelism: 4}
+			partitions := groupBy(records, options)
+			require.Len(t, partitions, options.Workers(), name)
+
+			groups := make(map[string]int)
+			for partition, hashMap := range partitions {
+				for _, cell := range hashMap.Cells {
+					if cell.Key == "" {
+						continue
+					}
+					require.Equal(t, partition, partitionOf(cell.Key, len(partitions)), name)
+					_, duplicate := groups[cell.Key]
+					require.False(t, duplicate, name)
+					groups[cell.Key] = cell.Value
+				}
+			}
+			require.Equal(t, base.SumPopularityByOs(records), groups, name)
+		}
+	}
+}
+
+func TestGroupBySkewAwareSplitsHotKey(t *testing.T) {
+	options := scheduler.Options{Parallelism: 4}
+	_, report := GroupBySkewAware(base.Data(), options, skew.DefaultOptions())
+
+	// Android dominates phones_data.csv
+	require.NotEmpty(t, report.HeavyHitters)
+	require.Equal(t, "Android", report.HeavyHitters[0].Key)
+	require.Greater(t, report.HeavyHitters[0].Salts, 1)
+	require.Less(t, report.SaltedSkew, report.Skew)
+}
+
 func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		GroupByOsAndSumByPopularity()
 	}
 }
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
+
+// two phases with side array of partitions versus one phase where every worker hashes all keys again
+func BenchmarkTwoPhaseVsOnePhase(b *testing.B) {
+	records := base.SyntheticData(200000, 10000)
+	for _, parallelism := range []int{2, 8} {
+		options := scheduler.Options{Parallelism: parallelism}
+		b.Run(fmt.Sprintf("two-phase/parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupBy(records, options)
+			}
+		})
+		b.Run(fmt.Sprintf("one-phase/parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOnePhase(records, options)
+			}
+		})
+	}
+}
+
+// phones_data.csv is dominated by one key, so without salting one worker aggregates most of rows
+func BenchmarkSkewAware(b *testing.B) {
+	phones := base.Data()
+	skewed := [][]string{phones[0]}
+	for i := 0; i < 100; i++ {
+		skewed = append(skewed, phones[1:]...)
+	}
+	for _, parallelism := range []int{2, 8} {
+		options := scheduler.Options{Parallelism: parallelism}
+		b.Run(fmt.Sprintf("plain/parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupBy(skewed, options)
+			}
+		})
+		b.Run(fmt.Sprintf("skew-aware/parallelism-%d", parallelism), func(b *testing.B) {
+			var report skew.Report
+			for n := 0; n < b.N; n++ {
+				_, report = GroupBySkewAware(skewed, options, skew.DefaultOptions())
+			}
+			b.ReportMetric(report.Skew, "skew")
+			b.ReportMetric(report.SaltedSkew, "salted-skew")
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package radix_partitioned
+
+import (
+	"group/base"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/scheduler"
+	"log"
+	"runtime"
+)
+
+/*
+Two-phase parallel aggregation with radix partitioning - https://15721.courses.cs.cmu.edu/spring2016/papers/p743-leis.pdf
+
+Phase 1 (pre-aggregation): every worker aggregates its morsels into small thread-local table which fits in cache.
+When table is full, worker spills all its cells into partitions chosen by radix (top bits) of the key hash,
+and starts over with empty table. Hot keys are aggregated in cache, rare keys just pass through.
+
+Phase 2 (final aggregation): every partition is aggregated independently in parallel - partition has distinct set
+of keys, so output is just concatenation of partition tables.
+*/
+
+const BitsForPartition = 6
+const NumPartitions = 1 << BitsForPartition
+
+// let's keep pre-aggregation table in L1/L2 cache
+const preAggregationCapacity = 1 << 10
+const preAggregationMaxFill = preAggregationCapacity / 2
+
+type entry struct {
+	key   string
+	value int
+	hash  uint64
+	used  bool
+}
+
+type preAggregationTable struct {
+	cells      []entry
+	size       int
+	partitions [NumPartitions][]entry
+	spills     int
+}
+
+func newPreAggregationTable() *preAggregationTable {
+	return &preAggregationTable{cells: make([]entry, preAggregationCapacity)}
+}
+
+func getPartitionFromHash(hash uint64) int {
+	return int(hash >> (64 - BitsForPartition))
+}
+
+func (table *preAggregationTable) add(key string, value int) {
+	hash := v1.HashStringKey(key)
+	cell := hash & (preAggregationCapacity - 1)
+	for table.cells[cell].used {
+		if table.cells[cell].hash == hash && table.cells[cell].key == key {
+			table.cells[cell].value += value
+			return
+		}
+		cell = (cell + 1) & (preAggregationCapacity - 1)
+	}
+
+	table.cells[cell] = entry{key: key, value: value, hash: hash, used: true}
+	table.size++
+	if table.size >= preAggregationMaxFill {
+		table.spill()
+	}
+}
+
+// spill moves all cells to radix partitions and clears table
+func (table *preAggregationTable) spill() {
+	if table.size == 0 {
+		return
+	}
+	for idx, cell := range table.cells {
+		if !cell.used {
+			continue
+		}
+		partition := getPartitionFromHash(cell.hash)
+		table.partitions[partition] = append(table.partitions[partition], cell)
+		table.cells[idx] = entry{}
+	}
+	table.size = 0
+	table.spills++
+}
+
+func GroupByOsAndSumByPopularity() {
+	// use all cores on your machine
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	// prepare data
+	records := base.Data()
+	partitions := GroupBy(records, options)
+
+	// print out result
+	for _, hashMap := range partitions {
+		if hashMap == nil {
+			continue
+		}
+
+		for _, cell := range hashMap.Cells {
+			if cell.Key != "" {
+				log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
+			}
+		}
+	}
+	log.Println()
+}
+
+// GroupBy groups records by os and sums popularity, result is table per radix partition (nil if partition is empty)
+func GroupBy(records [][]string, options scheduler.Options) []*v1.HashTableWithLinearProbing {
+	morsels, err := scheduler.MakeMorsels(records, options)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// phase 1 - thread-local pre-aggregation with spilling to partitions
+	tables, _ := scheduler.Run(morsels, options, newPreAggregationTable,
+		func(table *preAggregationTable, morsel scheduler.Morsel) {
+			for _, record := range morsel {
+				phone := base.MapPhone(record)
+				if phone.Os == "" {
+					continue
+				}
+				table.add(phone.Os, phone.Popularity)
+			}
+		})
+	for _, table := range tables {
+		table.spill()
+	}
+
+	// phase 2 - final aggregation, partitions are merged in parallel
+	partitions := make([]*v1.HashTableWithLinearProbing, NumPartitions)
+	scheduler.ForEach(NumPartitions, options, func(partition int) {
+		var hashMap *v1.HashTableWithLinearProbing
+		for _, table := range tables {
+			for _, cell := range table.partitions[partition] {
+				if hashMap == nil {
+					hashMap = new(v1.HashTableWithLinearProbing).New()
+				}
+
+				value := cell.value
+				if hashTableCell := hashMap.Get(cell.key); hashTableCell != nil {
+					value += hashTableCell.Value
+				}
+				hashMap.Put(cell.key, value)
+			}
+		}
+		partitions[partition] = hashMap
+	})
+
+	return partitions
+}
@@ -0,0 +1,79 @@
+package radix_partitioned
+
+import (
+	"fmt"
+	"group/base"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/scheduler"
+	"group/multicore/two_level_hashmap"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	GroupByOsAndSumByPopularity()
+}
+
+func TestGroupByMatchesSequentialAggregation(t *testing.T) {
+	for _, records := range [][][]string{base.Data(), base.SyntheticData(20000, 5000)} {
+		groups := make(map[string]int)
+		for partition, hashMap := range GroupBy(records, scheduler.Options{Parallelism: 4}) {
+			if hashMap == nil {
+				continue
+			}
+			for _, cell := range hashMap.Cells {
+				if cell.Key == "" {
+					continue
+				}
+				require.Equal(t, partition, getPartitionFromHash(v1.HashStringKey(cell.Key)))
+				_, duplicate := groups[cell.Key]
+				require.False(t, duplicate)
+				groups[cell.Key] = cell.Value
+			}
+		}
+		require.Equal(t, base.SumPopularityByOs(records), groups)
+	}
+}
+
+func TestPreAggregationTableSpills(t *testing.T) {
+	table := newPreAggregationTable()
+	for i := 0; i < preAggregationMaxFill*3; i++ {
+		table.add(fmt.Sprintf("key-%d", i), 1)
+	}
+	table.add("key-0", 1)
+	table.spill()
+	require.Equal(t, 4, table.spills)
+
+	total := 0
+	for _, partition := range table.partitions {
+		for _, cell := range partition {
+			total += cell.value
+		}
+	}
+	require.Equal(t, preAggregationMaxFill*3+1, total)
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularity()
+	}
+}
+
+// compare with two level hash table on low and high cardinality of group by
+func BenchmarkRadixPartitionedVsTwoLevel(b *testing.B) {
+	options := scheduler.DefaultOptions()
+	for _, cardinality := range []int{16, 1000, 20000} {
+		records := base.SyntheticData(100000, cardinality)
+		b.Run(fmt.Sprintf("radix_partitioned/cardinality-%d", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupBy(records, options)
+			}
+		})
+		b.Run(fmt.Sprintf("two_level/cardinality-%d", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				two_level_hashmap.GroupBy(records, options)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package striped_mutex
+
+import (
+	"group/base"
+	"group/base/hashmap"
+	"group/base/scheduler"
+	"log"
+	"runtime"
+)
+
+func GroupByOsAndSumByPopularity() {
+	// use all cores on your machine
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	// prepare data
+	records := base.Data()
+	hashTable := GroupBy(records, options)
+
+	// print out result
+	hashTable.Range(func(key string, value int) {
+		log.Printf("Popularity %d for group %s", value, key)
+	})
+
+	// print out contention on shards
+	for shardId, stats := range hashTable.Stats() {
+		if stats.Acquisitions > 0 {
+			log.Printf("Shard %d locked %d times, contended %d times", shardId, stats.Acquisitions, stats.Contended)
+		}
+	}
+	log.Println()
+}
+
+// GroupBy groups records by os and sums popularity, all workers aggregate into one shared sharded table
+func GroupBy(records [][]string, options scheduler.Options) *hashmap.ShardedHashTable {
+	morsels, err := scheduler.MakeMorsels(records, options)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	hashTable := new(hashmap.ShardedHashTable).New()
+	scheduler.Run(morsels, options, func() struct{} {
+		return struct{}{}
+	}, func(_ struct{}, morsel scheduler.Morsel) {
+		for _, record := range morsel {
+			phone := base.MapPhone(record)
+			hashTable.Add(phone.Os, phone.Popularity)
+		}
+	})
+
+	return hashTable
+}
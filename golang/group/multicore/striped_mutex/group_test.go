@@ -0,0 +1,82 @@
+package striped_mutex
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/hashmap"
+	"group/base/scheduler"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	GroupByOsAndSumByPopularity()
+}
+
+func TestGroupBy(t *testing.T) {
+	hashTable := GroupBy(base.Data(), scheduler.Options{Parallelism: 4})
+
+	android, ok := hashTable.Get("Android")
+	require.True(t, ok)
+	require.Equal(t, 575172, android)
+	ios, ok := hashTable.Get("iOS")
+	require.True(t, ok)
+	require.Equal(t, 89433, ios)
+	require.Equal(t, 6, hashTable.Size())
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularity()
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
+
+func reportContention(b *testing.B, hashTable *hashmap.ShardedHashTable) {
+	acquisitions, contended, hottest := 0, 0, 0
+	for _, stats := range hashTable.Stats() {
+		acquisitions += stats.Acquisitions
+		contended += stats.Contended
+		if stats.Acquisitions > hottest {
+			hottest = stats.Acquisitions
+		}
+	}
+	b.ReportMetric(100*float64(contended)/float64(acquisitions), "%contended")
+	b.ReportMetric(100*float64(hottest)/float64(acquisitions), "%hottest-shard")
+}
+
+// hot bucket contention on phones_data.csv (Android dominates) versus uniformly distributed keys
+func BenchmarkContention(b *testing.B) {
+	phones := base.Data()
+	skewed := [][]string{phones[0]}
+	for i := 0; i < 100; i++ {
+		skewed = append(skewed, phones[1:]...)
+	}
+	uniform := base.SyntheticData(len(skewed)-1, 10000)
+
+	for _, parallelism := range []int{2, 8} {
+		options := scheduler.Options{Parallelism: parallelism}
+		for _, dataset := range []struct {
+			name    string
+			records [][]string
+		}{{"phones", skewed}, {"uniform", uniform}} {
+			b.Run(fmt.Sprintf("%s/parallelism-%d", dataset.name, parallelism), func(b *testing.B) {
+				var hashTable *hashmap.ShardedHashTable
+				for n := 0; n < b.N; n++ {
+					hashTable = GroupBy(dataset.records, options)
+				}
+				reportContention(b, hashTable)
+			})
+		}
+	}
+}
@@ -1,120 +1,132 @@
 package two_level_hashmap
 
 import (
+	"fmt"
 	"group/base"
-	"group/base/buffer"
+	"group/base/batch"
 	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
 	"group/base/hashmap/two_level"
+	"group/base/memory"
+	"group/base/scheduler"
 	"log"
 	"runtime"
+	"sync/atomic"
 )
 
 func GroupByOsAndSumByPopularity() {
 	// use all cores on your machine
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
 	// prepare data
 	records := base.Data()
-	dataBlocks, err := buffer.MakePartitioning(records)
+	twoLevelHashTableOut := GroupBy(records, options)
+
+	for _, hashMap := range twoLevelHashTableOut.Buckets {
+		if hashMap == nil {
+			continue
+		}
+
+		for _, cell := range hashMap.Cells {
+			if cell.Key != "" {
+				log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
+			}
+		}
+	}
+	log.Println()
+}
+
+// GroupBy groups records by os and sums popularity, result is two level table merged by buckets
+func GroupBy(records [][]string, options scheduler.Options) *two_level.TwoLevelHashMap {
+	result, err := GroupByWithTracker(records, options, nil)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	return result
+}
 
-	hashTableAsResult := make(chan two_level.TwoLevelHashMap)
-
-	for _, block := range dataBlocks {
-		blockToRead := block.Read()
-		go func() {
-			twoLevelHashTable := new(two_level.TwoLevelHashMap).New()
-			for _, record := range blockToRead {
-				phone := base.MapPhone(record)
-				if phone.Os == "" {
-					continue
-				}
+// GroupByWithTracker is GroupBy reporting memory of thread-local tables to child trackers of tracker (one per worker),
+// memory.ErrMemoryLimitExceeded is returned if any limit is exceeded. Memory of result stays accounted in tracker.
+func GroupByWithTracker(records [][]string, options scheduler.Options,
+	tracker *memory.Tracker) (*two_level.TwoLevelHashMap, error) {
+	batches, err := scheduler.MakeBatches(records, base.PhonesSchema, options)
+	if err != nil {
+		return nil, err
+	}
 
-				popularity := phone.Popularity
-				hashTableCell := twoLevelHashTable.Get(phone.Os)
-				if hashTableCell != nil {
-					popularity += hashTableCell.Value
-				}
-				twoLevelHashTable.Put(phone.Os, popularity)
+	// distribute phase - every worker of the pool aggregates columns of batches to its thread-local two level table
+	var workers atomic.Int32
+	twoLevelHashMaps, _ := scheduler.Run(batches, options, func() *two_level.TwoLevelHashMap {
+		workerTracker := tracker.Child(fmt.Sprintf("worker-%d", workers.Add(1)-1), 0)
+		return new(two_level.TwoLevelHashMap).NewWithTracker(workerTracker)
+	}, func(twoLevelHashTable *two_level.TwoLevelHashMap, dataBatch *batch.Batch) {
+		osColumn, err := dataBatch.String(base.OsColumn)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for idx := 0; idx < dataBatch.Rows; idx++ {
+			key := osColumn.Value(idx)
+			if key == "" {
+				continue
 			}
-			hashTableAsResult <- *twoLevelHashTable
-		}()
-	}
 
-	twoLevelHashMaps := make([]two_level.TwoLevelHashMap, 0)
-	for taskId := 0; taskId < len(dataBlocks); taskId++ {
-		twoLevelHashMaps = append(twoLevelHashMaps, <-hashTableAsResult)
-		// for debugging purpose
-		/*
-			select {
-			case msg := <-hashTableAsResult:
-				fmt.Printf("got %v from worker channel\n", msg)
+			popularity := int(popularityColumn.Values[idx])
+			hashTableCell := twoLevelHashTable.Get(key)
+			if hashTableCell != nil {
+				popularity += hashTableCell.Value
 			}
-		*/
+			twoLevelHashTable.Put(key, popularity)
+		}
+	})
+
+	for _, twoLevelHashTable := range twoLevelHashMaps {
+		if err = twoLevelHashTable.Err(); err != nil {
+			return nil, err
+		}
 	}
 
 	// merge phase - we shift data between buckets to aggregate in parallel
-	result := make(chan bool)
 	twoLevelHashTableOut := new(two_level.TwoLevelHashMap).New()
 
-	for i := 0; i < two_level.NumBuckets; i++ {
-		go func(bucketId int) {
-			hashTables := make([]v1.HashTableWithLinearProbing, 0)
-			for _, twoLevelHashTable := range twoLevelHashMaps {
-				if twoLevelHashTable.Buckets[bucketId] == nil {
-					continue
-				}
-				hashTables = append(hashTables, *twoLevelHashTable.Buckets[bucketId])
-			}
-
-			// internal merge phase of bucket hash maps
-			for idx, table := range hashTables {
-				// merge with first table
-				if idx == 0 {
-					continue
-				}
-				primaryTable := hashTables[0]
-				for _, cell := range table.Cells {
-					primaryValue := cell.Value
-					if cell.Key != "" && primaryTable.ContainsKey(cell.Key) {
-						primaryCell := primaryTable.Get(cell.Key)
-						primaryValue = primaryCell.Value + cell.Value
-					}
-					primaryTable.Put(cell.Key, primaryValue)
-				}
+	scheduler.ForEach(two_level.NumBuckets, options, func(bucketId int) {
+		hashTables := make([]*v1.HashTableWithLinearProbing, 0)
+		for _, twoLevelHashTable := range twoLevelHashMaps {
+			if twoLevelHashTable.Buckets[bucketId] == nil {
+				continue
 			}
+			hashTables = append(hashTables, twoLevelHashTable.Buckets[bucketId])
+		}
 
-			if len(hashTables) > 0 && &hashTables[0] != nil {
-				twoLevelHashTableOut.Buckets[bucketId] = &hashTables[0]
+		// internal merge phase of bucket hash maps
+		for idx, table := range hashTables {
+			// merge with first table
+			if idx == 0 {
+				continue
 			}
-
-			result <- true
-		}(i)
-	}
-
-	for i := 0; i < two_level.NumBuckets; i++ {
-		<-result
-		// for debugging purpose
-		/*
-			select {
-			case msg := <-result:
-				fmt.Printf("got %v from worker channel\n", msg)
+			primaryTable := hashTables[0]
+			for _, cell := range table.Cells {
+				primaryValue := cell.Value
+				if cell.Key != "" && primaryTable.ContainsKey(cell.Key) {
+					primaryCell := primaryTable.Get(cell.Key)
+					primaryValue = primaryCell.Value + cell.Value
+				}
+				primaryTable.Put(cell.Key, primaryValue)
 			}
-		*/
-	}
-
-	for _, hashMap := range twoLevelHashTableOut.Buckets {
-		if hashMap == nil {
-			continue
+			// merged table is not needed anymore
+			table.Release()
 		}
 
-		for _, cell := range hashMap.Cells {
-			if cell.Key != "" {
-				log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
-			}
+		if len(hashTables) > 0 {
+			twoLevelHashTableOut.Buckets[bucketId] = hashTables[0]
 		}
-	}
-	log.Println()
+	})
+
+	return twoLevelHashTableOut, nil
 }
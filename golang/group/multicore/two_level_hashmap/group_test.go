@@ -1,13 +1,60 @@
 package two_level_hashmap
 
-import "testing"
+import (
+	"fmt"
+	"group/base"
+	"group/base/memory"
+	"group/base/scheduler"
+	"group/multicore/baseline_hashmap"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 func TestGroupByOsAndSumByPopularity(t *testing.T) {
 	GroupByOsAndSumByPopularity()
 }
 
+// memory trade-off of two level table versus thread-local tables with parallel merge: buckets are allocated
+// lazily, so with small cardinality two level table takes only touched buckets and peak memory is not bigger
+func TestMemoryTradeOff(t *testing.T) {
+	options := scheduler.Options{Parallelism: 4}
+	for _, cardinality := range []int{8, 1000, 100000} {
+		records := base.SyntheticData(100000, cardinality)
+
+		twoLevelTracker := new(memory.Tracker).New("two-level", 0)
+		_, err := GroupByWithTracker(records, options, twoLevelTracker)
+		require.NoError(t, err)
+		threadLocalTracker := new(memory.Tracker).New("thread-local", 0)
+		_, err = baseline_hashmap.GroupByWithTracker(records, options, threadLocalTracker)
+		require.NoError(t, err)
+
+		t.Logf("cardinality %d: peak of two level tables %d bytes, thread-local tables %d bytes",
+			cardinality, twoLevelTracker.Peak(), threadLocalTracker.Peak())
+		require.LessOrEqual(t, twoLevelTracker.Peak(), threadLocalTracker.Peak())
+		require.Zero(t, threadLocalTracker.Used())
+	}
+}
+
+func TestGroupByWithTrackerLimit(t *testing.T) {
+	records := base.SyntheticData(100000, 10000)
+	tracker := new(memory.Tracker).New("query", 64<<10)
+	_, err := GroupByWithTracker(records, scheduler.Options{Parallelism: 4}, tracker)
+	require.ErrorIs(t, err, memory.ErrMemoryLimitExceeded)
+}
+
 func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		GroupByOsAndSumByPopularity()
 	}
 }
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package vectorized
+
+import (
+	"group/base"
+	"group/base/batch"
+	"group/base/scheduler"
+	"group/base/vectorized"
+	"log"
+	"runtime"
+)
+
+func GroupByOsAndSumByPopularity() {
+	// use all cores on your machine
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	GroupByOsAndSumByPopularityWithOptions(scheduler.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options scheduler.Options) {
+	// prepare data
+	records := base.Data()
+	result := GroupBy(records, options)
+
+	// print out result
+	for key, popularity := range result {
+		log.Printf("Popularity %d for group %s", popularity, key)
+	}
+	log.Println()
+}
+
+// state is thread-local state of worker: group table with sums and scratch vector of group indexes
+type state struct {
+	table  *vectorized.GroupTable
+	sums   []int64
+	groups []int32
+}
+
+// GroupBy groups records by os and sums popularity, every worker aggregates batches with vectorized kernels
+// to its thread-local group table, thread-local tables are merged in the end
+func GroupBy(records [][]string, options scheduler.Options) map[string]int {
+	if options.MorselSize <= 0 {
+		// vectorized kernels need batches much larger than default data block to amortize per batch work
+		options.MorselSize = batch.DefaultBatchSize
+	}
+	batches, err := scheduler.MakeBatches(records, base.PhonesSchema, options)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	states, _ := scheduler.Run(batches, options, func() *state {
+		return &state{table: new(vectorized.GroupTable).New()}
+	}, func(state *state, dataBatch *batch.Batch) {
+		osColumn, err := dataBatch.String(base.OsColumn)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		state.groups = vectorized.Grow(state.groups[:0], dataBatch.Rows, vectorized.NoGroup)
+		state.table.FindOrInsert(osColumn, state.groups)
+		state.sums = vectorized.Grow(state.sums, state.table.Size(), 0)
+		vectorized.Sum(state.groups, popularityColumn.Values, popularityColumn.Validity, state.sums)
+	})
+
+	// merge phase - groups of every thread-local table are inserted to the first one
+	primary := states[0]
+	for _, other := range states[1:] {
+		for group, key := range other.table.Keys {
+			primaryGroup := primary.table.Insert(key)
+			primary.sums = vectorized.Grow(primary.sums, primary.table.Size(), 0)
+			primary.sums[primaryGroup] += other.sums[group]
+		}
+	}
+
+	result := make(map[string]int, primary.table.Size())
+	for group, key := range primary.table.Keys {
+		result[key] = int(primary.sums[group])
+	}
+	return result
+}
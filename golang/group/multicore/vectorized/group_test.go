@@ -0,0 +1,69 @@
+package vectorized
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/scheduler"
+	"group/multicore/baseline_hashmap"
+	"group/multicore/two_level_hashmap"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	GroupByOsAndSumByPopularity()
+}
+
+func TestGroupBy(t *testing.T) {
+	for _, records := range [][][]string{base.Data(), base.SyntheticData(100000, 20000)} {
+		expected := make(map[string]int)
+		for _, cell := range baseline_hashmap.GroupBy(records, scheduler.Options{Parallelism: 1}).Cells {
+			if cell.Key != "" {
+				expected[cell.Key] = cell.Value
+			}
+		}
+		for _, parallelism := range []int{1, 4} {
+			require.Equal(t, expected, GroupBy(records, scheduler.Options{Parallelism: parallelism}))
+		}
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularity()
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(scheduler.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
+
+// vectorized workers versus row-at-a-time workers (decoding included in both)
+func BenchmarkVectorizedVsRowAtATime(b *testing.B) {
+	for _, cardinality := range []int{16, 10000} {
+		records := base.SyntheticData(200000, cardinality)
+		options := scheduler.Options{Parallelism: 4}
+		b.Run(fmt.Sprintf("cardinality-%d/vectorized", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupBy(records, options)
+			}
+		})
+		b.Run(fmt.Sprintf("cardinality-%d/thread-local", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				baseline_hashmap.GroupBy(records, options)
+			}
+		})
+		b.Run(fmt.Sprintf("cardinality-%d/two-level", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				two_level_hashmap.GroupBy(records, options)
+			}
+		})
+	}
+}
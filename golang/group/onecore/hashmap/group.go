@@ -2,35 +2,129 @@ package hashmap
 
 import (
 	"group/base"
+	"group/base/batch"
+	"group/base/hashmap/open_addressing/arena_keys"
 	"group/base/hashmap/open_addressing/linear_probing/v1"
+	"group/base/vectorized"
 	"log"
 )
 
 func GroupByOsAndSumByPopularity() {
 	records := base.Data()
+	hashTable := GroupBy(records)
+
+	// print out result
+	for _, cell := range hashTable.Cells {
+		if cell.Key != "" {
+			log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
+		}
+	}
+	log.Println()
+}
+
+// GroupBy groups records (with csv caption) by os and sums popularity
+func GroupBy(records [][]string) *v1.HashTableWithLinearProbing {
 	hashTable := new(v1.HashTableWithLinearProbing).New()
+	if len(records) == 0 {
+		return hashTable
+	}
+
+	// decode rows (passing csv caption) to columns once, aggregation reads columns directly
+	dataBatch, err := batch.FromRecords(records[1:], base.PhonesSchema)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	osColumn, err := dataBatch.String(base.OsColumn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	for idx, record := range records {
-		// pass csv caption
-		if idx == 0 {
-			continue
+	for idx := 0; idx < dataBatch.Rows; idx++ {
+		key := osColumn.Value(idx)
+		popularity := int(popularityColumn.Values[idx])
+
+		if hashTable.ContainsKey(key) {
+			popularity += hashTable.Get(key).Value
 		}
+		hashTable.Put(key, popularity)
+	}
+
+	return hashTable
+}
 
-		phone := base.MapPhone(record)
+// GroupByVectorized groups records (with csv caption) by os and sums popularity batch at a time:
+// key column of batch is hashed and probed at once, then sum kernel runs over popularity column
+func GroupByVectorized(records [][]string) map[string]int {
+	result := make(map[string]int)
+	if len(records) == 0 {
+		return result
+	}
+
+	batches, err := batch.FromRecordsWithBatchSize(records[1:], base.PhonesSchema, batch.DefaultBatchSize)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	table, sums := AggregateBatches(batches)
+
+	for group, key := range table.Keys {
+		result[key] = int(sums[group])
+	}
+	return result
+}
 
-		if hashTable.ContainsKey(phone.Os) {
-			popularity := hashTable.Get(phone.Os).Value + phone.Popularity
-			hashTable.Put(phone.Os, popularity)
-		} else {
-			hashTable.Put(phone.Os, phone.Popularity)
+// AggregateBatches sums popularity by os over decoded batches with vectorized kernels
+func AggregateBatches(batches []*batch.Batch) (*vectorized.GroupTable, []int64) {
+	table := new(vectorized.GroupTable).New()
+	var sums []int64
+	var groups []int32
+	for _, dataBatch := range batches {
+		osColumn, err := dataBatch.String(base.OsColumn)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+		if err != nil {
+			log.Fatalln(err)
 		}
+
+		groups = vectorized.Grow(groups[:0], dataBatch.Rows, vectorized.NoGroup)
+		table.FindOrInsert(osColumn, groups)
+		sums = vectorized.Grow(sums, table.Size(), 0)
+		vectorized.Sum(groups, popularityColumn.Values, popularityColumn.Validity, sums)
 	}
+	return table, sums
+}
 
-	// print out result
-	for _, cell := range hashTable.Cells {
-		if cell.Key != "" {
-			log.Printf("Popularity %d for group %s", cell.Value, cell.Key)
+// GroupByWithKeyMode groups records (with csv caption) by os and sums popularity in table keeping keys out of Go heap,
+// short keys are kept inline in cells with arena_keys.InlineKeys mode
+func GroupByWithKeyMode(records [][]string, mode arena_keys.KeyMode) *arena_keys.HashTableWithArenaKeys {
+	hashTable := new(arena_keys.HashTableWithArenaKeys).NewWithKeyMode(0, mode)
+	if len(records) == 0 {
+		return hashTable
+	}
+
+	batches, err := batch.FromRecordsWithBatchSize(records[1:], base.PhonesSchema, batch.DefaultBatchSize)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, dataBatch := range batches {
+		osColumn, err := dataBatch.String(base.OsColumn)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for idx := 0; idx < dataBatch.Rows; idx++ {
+			if key := osColumn.Value(idx); key != "" {
+				hashTable.Add(key, int(popularityColumn.Values[idx]))
+			}
 		}
 	}
-	log.Println()
+	return hashTable
 }
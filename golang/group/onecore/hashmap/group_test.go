@@ -1,13 +1,132 @@
 package hashmap
 
-import "testing"
+import (
+	"fmt"
+	"group/base"
+	"group/base/batch"
+	"group/base/hashmap/open_addressing/arena_keys"
+	"group/base/hashmap/open_addressing/linear_probing/v1"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 func TestGroupByOsAndSumByPopularity(t *testing.T) {
 	GroupByOsAndSumByPopularity()
 }
 
+func TestGroupByVectorized(t *testing.T) {
+	for _, records := range [][][]string{base.Data(), base.SyntheticData(50000, 5000)} {
+		expected := make(map[string]int)
+		for _, cell := range GroupBy(records).Cells {
+			if cell.Key != "" {
+				expected[cell.Key] = cell.Value
+			}
+		}
+		require.Equal(t, expected, GroupByVectorized(records))
+	}
+}
+
+func TestGroupByWithKeyMode(t *testing.T) {
+	records := base.Data()
+	expected := GroupByVectorized(records)
+	for _, mode := range []arena_keys.KeyMode{arena_keys.ArenaKeys, arena_keys.InlineKeys} {
+		actual := make(map[string]int)
+		GroupByWithKeyMode(records, mode).Range(func(key string, value int) {
+			actual[key] = value
+		})
+		require.Equal(t, expected, actual)
+	}
+}
+
 func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		GroupByOsAndSumByPopularity()
 	}
 }
+
+// rowAtATime is current path over decoded batches: hash, probe and update per row
+func rowAtATime(batches []*batch.Batch) *v1.HashTableWithLinearProbing {
+	hashTable := new(v1.HashTableWithLinearProbing).New()
+	for _, dataBatch := range batches {
+		osColumn, _ := dataBatch.String(base.OsColumn)
+		popularityColumn, _ := dataBatch.Int64(base.PopularityColumn)
+		for idx := 0; idx < dataBatch.Rows; idx++ {
+			key := osColumn.Value(idx)
+			popularity := int(popularityColumn.Values[idx])
+			if cell := hashTable.Get(key); cell != nil {
+				popularity += cell.Value
+			}
+			hashTable.Put(key, popularity)
+		}
+	}
+	return hashTable
+}
+
+// per row cost of aggregation (decoding excluded) of row-at-a-time path versus vectorized kernels
+func BenchmarkRowAtATimeVsVectorized(b *testing.B) {
+	const rows = 200000
+	for _, cardinality := range []int{8, 1000, 100000} {
+		records := base.SyntheticData(rows, cardinality)
+		batches, err := batch.FromRecordsWithBatchSize(records[1:], base.PhonesSchema, batch.DefaultBatchSize)
+		require.NoError(b, err)
+
+		b.Run(fmt.Sprintf("cardinality-%d/row-at-a-time", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				rowAtATime(batches)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*rows), "ns/row")
+		})
+		b.Run(fmt.Sprintf("cardinality-%d/vectorized", cardinality), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				AggregateBatches(batches)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*rows), "ns/row")
+		})
+	}
+}
+
+// string keys of v1 table versus keys copied to arena versus short keys inline in cells on phones dataset,
+// keys of os and brand name are mostly shorter than 16 bytes
+func BenchmarkKeyModes(b *testing.B) {
+	records := base.Data()
+	batches, err := batch.FromRecordsWithBatchSize(records[1:], base.PhonesSchema, batch.DefaultBatchSize)
+	require.NoError(b, err)
+
+	for _, column := range []int{base.OsColumn, base.BrandNameColumn} {
+		aggregate := func(add func(key string, value int)) {
+			for _, dataBatch := range batches {
+				keyColumn, _ := dataBatch.String(column)
+				popularityColumn, _ := dataBatch.Int64(base.PopularityColumn)
+				for idx := 0; idx < dataBatch.Rows; idx++ {
+					if key := keyColumn.Value(idx); key != "" {
+						add(key, int(popularityColumn.Values[idx]))
+					}
+				}
+			}
+		}
+		name := base.PhonesSchema[column].Name
+
+		b.Run(name+"/string-keys", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				hashTable := new(v1.HashTableWithLinearProbing).New()
+				aggregate(func(key string, value int) {
+					if cell := hashTable.Get(key); cell != nil {
+						value += cell.Value
+					}
+					hashTable.Put(key, value)
+				})
+			}
+		})
+		b.Run(name+"/arena-keys", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				aggregate(new(arena_keys.HashTableWithArenaKeys).NewWithKeyMode(0, arena_keys.ArenaKeys).Add)
+			}
+		})
+		b.Run(name+"/inline-keys", func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				aggregate(new(arena_keys.HashTableWithArenaKeys).NewWithKeyMode(0, arena_keys.InlineKeys).Add)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package lookup_table
+
+import (
+	"errors"
+	"group/base"
+	"group/base/dictionary"
+	"group/base/lookup_table"
+	"log"
+	"math"
+)
+
+var ErrKeyOutOfRange = errors.New("key does not fit lookup table")
+
+// KeyFn maps phone to key of lookup table, returns false if phone has no key (row is skipped) and
+// ErrKeyOutOfRange if key does not fit lookup table
+type KeyFn func(phone base.Phone) (uint16, bool, error)
+
+func SellersAmountKey(phone base.Phone) (uint16, bool, error) {
+	return toKey(phone.SellersAmount())
+}
+
+func ReleaseYearKey(phone base.Phone) (uint16, bool, error) {
+	return toKey(phone.ReleaseYear())
+}
+
+// OsKey encodes os by dictionary, so os can be aggregated in lookup table while its cardinality is small,
+// phones without os are skipped as by every other strategy
+func OsKey(osDictionary *dictionary.Dictionary[uint16]) KeyFn {
+	return func(phone base.Phone) (uint16, bool, error) {
+		if phone.Os == "" {
+			return 0, false, nil
+		}
+		key, ok := osDictionary.Encode(phone.Os)
+		if !ok {
+			return 0, false, ErrKeyOutOfRange
+		}
+		return key, true, nil
+	}
+}
+
+func toKey(value int) (uint16, bool, error) {
+	if value < 0 || value > math.MaxUint16 {
+		return 0, false, ErrKeyOutOfRange
+	}
+	return uint16(value), true, nil
+}
+
+func GroupBySellersAmountAndSumByPopularity() {
+	printOut(GroupBy(base.Data(), SellersAmountKey))
+}
+
+func GroupByReleaseYearAndSumByPopularity() {
+	printOut(GroupBy(base.Data(), ReleaseYearKey))
+}
+
+func GroupByOsAndSumByPopularity() {
+	osDictionary := dictionary.New[uint16]()
+	table, err := GroupBy(base.Data(), OsKey(osDictionary))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// print out result
+	table.Range(func(key uint16, value int) {
+		log.Printf("Popularity %d for group %s", value, osDictionary.Decode(key))
+	})
+	log.Println()
+}
+
+func printOut(table *lookup_table.LookupTable[uint16], err error) {
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	table.Range(func(key uint16, value int) {
+		log.Printf("Popularity %d for group %d", value, key)
+	})
+	log.Println()
+}
+
+// GroupBy groups records (with csv caption) by key and sums popularity
+func GroupBy(records [][]string, keyFn KeyFn) (*lookup_table.LookupTable[uint16], error) {
+	table := lookup_table.New[uint16]()
+
+	for idx, record := range records {
+		// pass csv caption
+		if idx == 0 {
+			continue
+		}
+
+		phone := base.MapPhone(record)
+		key, ok, err := keyFn(phone)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			table.Add(key, phone.Popularity)
+		}
+	}
+
+	return table, nil
+}
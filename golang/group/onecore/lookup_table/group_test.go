@@ -0,0 +1,87 @@
+package lookup_table
+
+import (
+	"group/base"
+	"group/base/dictionary"
+	"group/base/lookup_table"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func expected(keyOf func(phone base.Phone) int) map[int]int {
+	groups := make(map[int]int)
+	for _, record := range base.Data()[1:] {
+		phone := base.MapPhone(record)
+		groups[keyOf(phone)] += phone.Popularity
+	}
+	return groups
+}
+
+func toMap(table *lookup_table.LookupTable[uint16]) map[int]int {
+	groups := make(map[int]int)
+	table.Range(func(key uint16, value int) {
+		groups[int(key)] = value
+	})
+	return groups
+}
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	GroupByOsAndSumByPopularity()
+}
+
+func TestGroupBySellersAmountAndSumByPopularity(t *testing.T) {
+	GroupBySellersAmountAndSumByPopularity()
+}
+
+func TestGroupByReleaseYearAndSumByPopularity(t *testing.T) {
+	GroupByReleaseYearAndSumByPopularity()
+}
+
+func TestGroupBy(t *testing.T) {
+	table, err := GroupBy(base.Data(), SellersAmountKey)
+	require.NoError(t, err)
+	require.Equal(t, expected(base.Phone.SellersAmount), toMap(table))
+
+	table, err = GroupBy(base.Data(), ReleaseYearKey)
+	require.NoError(t, err)
+	require.Equal(t, expected(base.Phone.ReleaseYear), toMap(table))
+	_, ok := table.Get(2020)
+	require.True(t, ok)
+
+	osDictionary := dictionary.New[uint16]()
+	table, err = GroupBy(base.Data(), OsKey(osDictionary))
+	require.NoError(t, err)
+	android, _ := osDictionary.Encode("Android")
+	value, ok := table.Get(android)
+	require.True(t, ok)
+	require.Equal(t, 575172, value)
+	// phones without os make no group
+	groups := make(map[string]int)
+	table.Range(func(key uint16, value int) {
+		groups[osDictionary.Decode(key)] = value
+	})
+	require.NotContains(t, groups, "")
+	require.Equal(t, osDictionary.Size(), table.Size())
+	for _, record := range base.Data()[1:] {
+		if phone := base.MapPhone(record); phone.Os != "" {
+			groups[phone.Os] -= phone.Popularity
+		}
+	}
+	for os, value := range groups {
+		require.Zero(t, value, os)
+	}
+}
+
+func TestGroupByKeyOutOfRange(t *testing.T) {
+	_, err := GroupBy(base.Data(), func(phone base.Phone) (uint16, bool, error) {
+		return toKey(phone.SellersAmount() * 1000)
+	})
+	require.ErrorIs(t, err, ErrKeyOutOfRange)
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularity()
+	}
+}
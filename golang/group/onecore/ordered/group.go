@@ -0,0 +1,112 @@
+package ordered
+
+import (
+	"group/base"
+	"group/base/art"
+	"group/base/batch"
+	"group/base/btree"
+	"group/base/skip_list"
+	"log"
+)
+
+/*
+Aggregation in ordered associative arrays - groups come out in ascending order of key without sort and range of keys
+can be queried, but every key lookup walks several nodes instead of one probe of hash table.
+*/
+
+// Structure is ordered associative array used as aggregation table
+type Structure int
+
+const (
+	// SkipList is concurrent skip list (golang/group/base/skip_list)
+	SkipList Structure = iota
+	// BTree is in-memory B-tree (golang/group/base/btree)
+	BTree
+	// ART is adaptive radix tree (golang/group/base/art), it also gives groups of key prefix (see RangePrefix)
+	ART
+)
+
+func (structure Structure) String() string {
+	switch structure {
+	case SkipList:
+		return "skip list"
+	case BTree:
+		return "B-tree"
+	case ART:
+		return "ART"
+	default:
+		return "unknown"
+	}
+}
+
+// Table is ordered aggregation table
+type Table interface {
+	Add(key string, value int)
+	Get(key string) (int, bool)
+	Size() int
+	// Range calls fn for every key in ascending order
+	Range(fn func(key string, value int))
+	// RangeBetween calls fn for every key in [from, to) in ascending order
+	RangeBetween(from string, to string, fn func(key string, value int))
+}
+
+func NewTable(structure Structure) Table {
+	switch structure {
+	case SkipList:
+		return new(skip_list.SkipList).New()
+	case BTree:
+		return new(btree.BTree).New()
+	case ART:
+		return new(art.Tree).New()
+	default:
+		log.Fatalf("unknown structure %d", structure)
+		return nil
+	}
+}
+
+func GroupByOsAndSumByPopularity() {
+	GroupByOsAndSumByPopularityWithStructure(BTree)
+}
+
+func GroupByOsAndSumByPopularityWithStructure(structure Structure) {
+	records := base.Data()
+	table := GroupBy(records, structure)
+
+	// print out result - groups are ordered by os
+	table.Range(func(key string, value int) {
+		log.Printf("Popularity %d for group %s", value, key)
+	})
+	log.Println()
+}
+
+// GroupBy groups records (with csv caption) by os and sums popularity in ordered table of structure
+func GroupBy(records [][]string, structure Structure) Table {
+	table := NewTable(structure)
+	if len(records) == 0 {
+		return table
+	}
+
+	dataBatch, err := batch.FromRecords(records[1:], base.PhonesSchema)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	osColumn, err := dataBatch.String(base.OsColumn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	Aggregate(table, osColumn, popularityColumn)
+	return table
+}
+
+// Aggregate sums popularity by os of columns in table, rows without os are skipped
+func Aggregate(table Table, osColumn *batch.StringColumn, popularityColumn *batch.Int64Column) {
+	for idx := 0; idx < len(popularityColumn.Values); idx++ {
+		if key := osColumn.Value(idx); key != "" {
+			table.Add(key, int(popularityColumn.Values[idx]))
+		}
+	}
+}
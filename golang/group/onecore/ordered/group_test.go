@@ -0,0 +1,169 @@
+package ordered
+
+import (
+	"fmt"
+	"group/base"
+	"group/base/art"
+	"group/base/batch"
+	v1 "group/base/hashmap/open_addressing/linear_probing/v1"
+	v2 "group/base/hashmap/open_addressing/linear_probing/v2"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var structures = []Structure{SkipList, BTree, ART}
+
+func TestGroupByOsAndSumByPopularity(t *testing.T) {
+	for _, structure := range structures {
+		GroupByOsAndSumByPopularityWithStructure(structure)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	for _, records := range [][][]string{base.Data(), base.SyntheticData(50000, 10000)} {
+		for _, structure := range structures {
+			table := GroupBy(records, structure)
+			keys := make([]string, 0)
+			actual := make(map[string]int)
+			table.Range(func(key string, value int) {
+				keys = append(keys, key)
+				actual[key] = value
+			})
+			require.True(t, sort.StringsAreSorted(keys), structure.String())
+			require.Equal(t, base.SumPopularityByOs(records), actual, structure.String())
+			require.Equal(t, len(actual), table.Size())
+
+			// range query - groups of os-1000 ... os-1999 and os-2
+			between := make(map[string]int)
+			table.RangeBetween("os-1000", "os-3", func(key string, value int) {
+				between[key] = value
+			})
+			for key, value := range actual {
+				if key >= "os-1000" && key < "os-3" {
+					require.Equal(t, value, between[key])
+					delete(between, key)
+				}
+			}
+			require.Empty(t, between)
+		}
+	}
+}
+
+func TestGroupByPrefix(t *testing.T) {
+	records := base.Data()
+	table := GroupBy(records, ART).(*art.Tree)
+	actual := make(map[string]int)
+	table.RangePrefix("Android", func(key string, value int) {
+		actual[key] = value
+	})
+	require.NotEmpty(t, actual)
+	for key, value := range base.SumPopularityByOs(records) {
+		if strings.HasPrefix(key, "Android") {
+			require.Equal(t, value, actual[key], key)
+			delete(actual, key)
+		}
+	}
+	require.Empty(t, actual)
+}
+
+func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
+	for _, structure := range structures {
+		b.Run(structure.String(), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithStructure(structure)
+			}
+		})
+	}
+}
+
+// columns decodes os and popularity columns of records once
+func columns(b *testing.B, records [][]string) (*batch.StringColumn, *batch.Int64Column) {
+	dataBatch, err := batch.FromRecords(records[1:], base.PhonesSchema)
+	require.NoError(b, err)
+	osColumn, err := dataBatch.String(base.OsColumn)
+	require.NoError(b, err)
+	popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+	require.NoError(b, err)
+	return osColumn, popularityColumn
+}
+
+func BenchmarkOrderedVsHashTables(b *testing.B) {
+	for _, cardinality := range []int{8, 1000, 100000} {
+		osColumn, popularityColumn := columns(b, base.SyntheticData(200000, cardinality))
+		rows := float64(len(popularityColumn.Values))
+
+		for _, structure := range structures {
+			b.Run(fmt.Sprintf("%s %d keys", structure, cardinality), func(b *testing.B) {
+				b.ReportAllocs()
+				for n := 0; n < b.N; n++ {
+					Aggregate(NewTable(structure), osColumn, popularityColumn)
+				}
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/rows, "ns/row")
+			})
+		}
+		b.Run(fmt.Sprintf("linear probing v1 %d keys", cardinality), func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				hashMap := new(v1.HashTableWithLinearProbing).New()
+				for idx := range popularityColumn.Values {
+					key := osColumn.Value(idx)
+					value := int(popularityColumn.Values[idx])
+					if cell := hashMap.Get(key); cell != nil {
+						value += cell.Value
+					}
+					hashMap.Put(key, value)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/rows, "ns/row")
+		})
+		b.Run(fmt.Sprintf("linear probing v2 %d keys", cardinality), func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				hashMap := new(v2.HashTableWithLinearProbing).NewWithCapacity(4*cardinality, 4*cardinality)
+				for idx := range popularityColumn.Values {
+					hashMap.Add(osColumn.Value(idx), int(popularityColumn.Values[idx]))
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/rows, "ns/row")
+		})
+	}
+}
+
+func BenchmarkRandomLookup(b *testing.B) {
+	const keys = 100000
+	tables := make(map[string]Table)
+	for _, structure := range structures {
+		tables[structure.String()] = NewTable(structure)
+	}
+	hashMap := new(v1.HashTableWithLinearProbing).New()
+	lookups := make([]string, keys)
+	for idx := range lookups {
+		lookups[idx] = "os-" + strconv.Itoa(idx)
+		for _, table := range tables {
+			table.Add(lookups[idx], idx)
+		}
+		hashMap.Put(lookups[idx], idx)
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(lookups), func(i, j int) {
+		lookups[i], lookups[j] = lookups[j], lookups[i]
+	})
+
+	for _, structure := range structures {
+		table := tables[structure.String()]
+		b.Run(structure.String(), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				table.Get(lookups[n%keys])
+			}
+		})
+	}
+	b.Run("linear probing v1", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			hashMap.Get(lookups[n%keys])
+		}
+	})
+}
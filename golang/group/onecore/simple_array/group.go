@@ -1,52 +1,55 @@
 package simple_array
 
 import (
+	"fmt"
 	"group/base"
+	"group/base/batch"
+	"group/base/parallel_sort"
+	"group/base/spill"
+	"io"
 	"log"
-	"sort"
+	"os"
+	"path/filepath"
 )
 
 func GroupByOsAndSumByPopularity() {
+	GroupByOsAndSumByPopularityWithOptions(parallel_sort.DefaultOptions())
+}
+
+func GroupByOsAndSumByPopularityWithOptions(options parallel_sort.Options) {
 	phones := PrepareData()
 
-	// group by Brand name
-	sort.Slice(phones, func(i, j int) bool {
-		return phones[i].Os > phones[j].Os
-	})
+	// print out result
+	for _, group := range GroupBy(phones, options) {
+		log.Printf("Popularity %d for group %s", group.Popularity, group.Os)
+	}
+	log.Println()
+}
+
+// GroupBy sorts phones in place by os with parallel MSD radix sort and sums popularity of every group,
+// groups come in ascending order of os
+func GroupBy(phones []base.Phone, options parallel_sort.Options) []base.GroupByOsPhone {
+	parallel_sort.RadixSortStrings(phones, func(phone base.Phone) string {
+		return phone.Os
+	}, options)
 
 	var groupByOsAndSumByPopularity []base.GroupByOsPhone
-	var currentOsName string
-	var currentPopularity int
-	for idx, record := range phones {
-		if idx == 0 {
-			currentOsName = record.Os
+	parallel_sort.Groups(phones, func(a, b base.Phone) bool {
+		return a.Os == b.Os
+	}, func(group []base.Phone) {
+		if group[0].Os == "" {
+			return
 		}
-
-		if record.Os == "" {
-			continue
+		popularity := 0
+		for _, phone := range group {
+			popularity += phone.Popularity
 		}
-
-		if currentOsName != record.Os {
-			groupByOsAndSumByPopularity = append(groupByOsAndSumByPopularity, base.GroupByOsPhone{
-				Os:         currentOsName,
-				Popularity: currentPopularity,
-			})
-			currentPopularity = 0
-		}
-		currentOsName = record.Os
-		currentPopularity += record.Popularity
-	}
-	// insert last group
-	groupByOsAndSumByPopularity = append(groupByOsAndSumByPopularity, base.GroupByOsPhone{
-		Os:         currentOsName,
-		Popularity: currentPopularity,
+		groupByOsAndSumByPopularity = append(groupByOsAndSumByPopularity, base.GroupByOsPhone{
+			Os:         group[0].Os,
+			Popularity: popularity,
+		})
 	})
-
-	// print out result
-	for _, group := range groupByOsAndSumByPopularity {
-		log.Printf("Popularity %d for group %s", group.Popularity, group.Os)
-	}
-	log.Println()
+	return groupByOsAndSumByPopularity
 }
 
 func PrepareData() []base.Phone {
@@ -63,3 +66,125 @@ func PrepareData() []base.Phone {
 	}
 	return phones
 }
+
+// DefaultRunSize is default number of rows of sorted run
+const DefaultRunSize = 4096
+
+type Stats struct {
+	// Rows is number of rows sorted
+	Rows int
+	// Runs is number of sorted runs written to disk
+	Runs int
+}
+
+func GroupByOsAndSumByPopularityWithExternalSort() {
+	file, err := os.Open(base.DataPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	stats, err := GroupByExternalSort(file, DefaultRunSize, "", func(key string, popularity int) {
+		log.Printf("Popularity %d for group %s", popularity, key)
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Sorted %d rows in %d runs", stats.Rows, stats.Runs)
+	log.Println()
+}
+
+/*
+GroupByExternalSort groups csv stream (with caption) by os and sums popularity by external merge sort, so data does
+not have to fit in memory:
+  - stream is decoded batch by batch, (os, popularity) of rows are collected to run of runSize rows;
+  - full run is sorted by key and written to temporary file under dir (default temporary directory if empty);
+  - in the end runs (and last run in memory) are merged by k-way heap merge, rows of group come one after another,
+    so aggregate of group is emitted to fn as soon as next group begins.
+
+Memory is bounded by run size and number of runs, groups come in ascending order of key.
+*/
+func GroupByExternalSort(reader io.Reader, runSize int, dir string,
+	fn func(os string, popularity int)) (Stats, error) {
+	if runSize <= 0 {
+		runSize = DefaultRunSize
+	}
+	decoder, err := batch.NewDecoder(reader, base.PhonesSchema, batch.DefaultBatchSize)
+	if err != nil {
+		return Stats{}, err
+	}
+	runsDir, err := os.MkdirTemp(dir, "sort-")
+	if err != nil {
+		return Stats{}, err
+	}
+	defer func() {
+		_ = os.RemoveAll(runsDir)
+	}()
+
+	var stats Stats
+	run := make([]spill.Record, 0, runSize)
+	sortRun := func() {
+		parallel_sort.RadixSortStrings(run, func(record spill.Record) string {
+			return record.Key
+		}, parallel_sort.DefaultOptions())
+	}
+	var paths []string
+	for {
+		dataBatch, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		osColumn, err := dataBatch.String(base.OsColumn)
+		if err != nil {
+			return stats, err
+		}
+		popularityColumn, err := dataBatch.Int64(base.PopularityColumn)
+		if err != nil {
+			return stats, err
+		}
+
+		for idx := 0; idx < dataBatch.Rows; idx++ {
+			key := osColumn.Value(idx)
+			if key == "" {
+				continue
+			}
+			run = append(run, spill.Record{Key: key, Value: int(popularityColumn.Values[idx])})
+			stats.Rows++
+			if len(run) < runSize {
+				continue
+			}
+
+			// run is full - sort it and write to disk
+			sortRun()
+			path := filepath.Join(runsDir, fmt.Sprintf("run-%d", len(paths)))
+			if _, err = spill.WriteRun(path, run); err != nil {
+				return stats, err
+			}
+			paths = append(paths, path)
+			run = run[:0]
+		}
+	}
+	stats.Runs = len(paths)
+
+	// merge phase - runs are merged by heap, group boundaries are detected on the fly
+	sources := make([]spill.Source, 0, len(paths)+1)
+	for _, path := range paths {
+		source, err := spill.OpenRun(path)
+		if err != nil {
+			return stats, err
+		}
+		defer func(source *spill.FileSource) {
+			_ = source.Close()
+		}(source)
+		sources = append(sources, source)
+	}
+	sortRun()
+	sources = append(sources, spill.NewMemorySource(run))
+
+	return stats, spill.MergeSources(sources, fn)
+}
@@ -1,13 +1,106 @@
 package simple_array
 
-import "testing"
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"group/base"
+	"group/base/parallel_sort"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 func TestGroupByOsAndSumByPopularity(t *testing.T) {
 	GroupByOsAndSumByPopularity()
 }
 
+func TestGroupByOsAndSumByPopularityWithExternalSort(t *testing.T) {
+	GroupByOsAndSumByPopularityWithExternalSort()
+}
+
+func TestGroupBy(t *testing.T) {
+	records := base.SyntheticData(50000, 3000)
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		phones := make([]base.Phone, 0, len(records)-1)
+		for _, record := range records[1:] {
+			phones = append(phones, base.MapPhone(record))
+		}
+
+		keys := make([]string, 0)
+		actual := make(map[string]int)
+		for _, group := range GroupBy(phones, parallel_sort.Options{Parallelism: parallelism}) {
+			keys = append(keys, group.Os)
+			actual[group.Os] = group.Popularity
+		}
+		require.True(t, sort.StringsAreSorted(keys))
+		require.Len(t, keys, len(actual))
+		require.Equal(t, base.SumPopularityByOs(records), actual)
+	}
+}
+
+func TestGroupByExternalSort(t *testing.T) {
+	records := base.SyntheticData(50000, 3000)
+	var data bytes.Buffer
+	require.NoError(t, csv.NewWriter(&data).WriteAll(records))
+
+	for _, runSize := range []int{100, 7000, 100000} {
+		dir := t.TempDir()
+		keys := make([]string, 0)
+		actual := make(map[string]int)
+		stats, err := GroupByExternalSort(bytes.NewReader(data.Bytes()), runSize, dir, func(key string, popularity int) {
+			keys = append(keys, key)
+			actual[key] = popularity
+		})
+		require.NoError(t, err)
+		require.Equal(t, 50000, stats.Rows)
+		require.Equal(t, 50000/runSize, stats.Runs)
+		require.True(t, sort.StringsAreSorted(keys))
+		require.Len(t, keys, len(actual))
+		require.Equal(t, base.SumPopularityByOs(records), actual)
+
+		// runs are removed
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	}
+}
+
+func TestGroupByExternalSortOfPhones(t *testing.T) {
+	file, err := os.Open(base.DataPath)
+	require.NoError(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+
+	actual := make(map[string]int)
+	_, err = GroupByExternalSort(file, 500, "", func(key string, popularity int) {
+		actual[key] = popularity
+	})
+	require.NoError(t, err)
+	require.Equal(t, base.SumPopularityByOs(base.Data()), actual)
+}
+
 func BenchmarkGroupByOsAndSumByPopularity(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		GroupByOsAndSumByPopularity()
 	}
 }
+
+func BenchmarkGroupByOsAndSumByPopularityWithParallelism(b *testing.B) {
+	for _, parallelism := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("parallelism %d", parallelism), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				GroupByOsAndSumByPopularityWithOptions(parallel_sort.Options{Parallelism: parallelism})
+			}
+		})
+	}
+}
+
+func BenchmarkGroupByOsAndSumByPopularityWithExternalSort(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		GroupByOsAndSumByPopularityWithExternalSort()
+	}
+}